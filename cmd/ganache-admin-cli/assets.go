@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ganache-admin-ui/internal/config"
+	"ganache-admin-ui/internal/export"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/revisions"
+	"ganache-admin-ui/internal/scan"
+	"ganache-admin-ui/internal/tagpolicy"
+	"ganache-admin-ui/internal/trash"
+)
+
+// env is what the asset subcommands share, loaded from the same .env as
+// the UI. With several backends configured, GANACHE_BACKEND picks the one
+// to work on; dataDir is where the UI keeps that backend's trash and
+// history.
+type env struct {
+	cfg     *config.Config
+	backend config.GanacheConfig
+	dataDir string
+	client  *ganache.Client
+	policy  *tagpolicy.Policy
+	scanner scan.Scanner
+}
+
+func loadEnv() env {
+	cfg, err := config.Load()
+	if err != nil {
+		fatal(err)
+	}
+	backend, err := cfg.Backend(os.Getenv("GANACHE_BACKEND"))
+	if err != nil {
+		fatal(err)
+	}
+	policy, err := tagpolicy.Load(cfg.TagPolicyFile)
+	if err != nil {
+		fatal(err)
+	}
+	e := env{
+		cfg:     cfg,
+		backend: backend,
+		dataDir: cfg.BackendDataDir(backend.Name),
+		client:  ganache.NewClient(backend.BaseURL, backend.APIKey, backend.Timeout),
+		policy:  policy,
+	}
+	if cfg.Scan.ClamdAddr != "" {
+		if e.scanner, err = scan.NewClamdScanner(cfg.Scan.ClamdAddr, cfg.Scan.Timeout); err != nil {
+			fatal(err)
+		}
+	}
+	return e
+}
+
+// on returns the environment for another configured backend.
+func (e env) on(name string) env {
+	backend, err := e.cfg.Backend(name)
+	if err != nil {
+		fatal(err)
+	}
+	e.backend = backend
+	e.dataDir = e.cfg.BackendDataDir(backend.Name)
+	e.client = ganache.NewClient(backend.BaseURL, backend.APIKey, backend.Timeout)
+	return e
+}
+
+// checkUpload runs the checks the UI applies to an upload: validation,
+// then malware scanning when clamd is configured. f is rewound after.
+func (e env) checkUpload(ctx context.Context, f *os.File) (media.Info, error) {
+	info, err := media.Validate(f, e.uploadLimits())
+	if err != nil || e.scanner == nil {
+		return info, err
+	}
+	res, err := e.scanner.Scan(ctx, f)
+	if _, serr := f.Seek(0, io.SeekStart); serr != nil {
+		return info, serr
+	}
+	if err != nil && !e.cfg.Scan.FailOpen {
+		return info, fmt.Errorf("malware scanner unavailable: %w", err)
+	}
+	if res.Infected {
+		return info, fmt.Errorf("malware detected (%s)", res.Signature)
+	}
+	return info, nil
+}
+
+// uploadLimits are the image checks the UI applies to uploads.
+func (e env) uploadLimits() media.Limits {
+	return media.Limits{
+		AllowedTypes: e.cfg.Upload.AllowedTypes,
+		MaxWidth:     e.cfg.Upload.MaxWidth,
+		MaxHeight:    e.cfg.Upload.MaxHeight,
+		MaxPixels:    e.cfg.Upload.MaxPixels,
+	}
+}
+
+// createAsset uploads f with u's metadata, after checking its tags against
+// the tag policy.
+func (e env) createAsset(ctx context.Context, f io.Reader, filename string, u ganache.AssetUpdate) (ganache.Asset, error) {
+	tags, err := e.policy.Check(u.Tags)
+	if err != nil {
+		return ganache.Asset{}, err
+	}
+	return e.client.CreateAssetMultipart(ctx, f, filename, map[string]string{
+		"title":      u.Title,
+		"caption":    u.Caption,
+		"credit":     u.Credit,
+		"source":     u.Source,
+		"usageNotes": u.UsageNotes,
+	}, tags)
+}
+
+// trashed returns the assets in the UI's trash, which searches hide.
+func (e env) trashed() map[string]bool {
+	st, err := trash.NewStore(filepath.Join(e.dataDir, "trash.json"))
+	if err != nil {
+		fatal(err)
+	}
+	return st.IDs()
+}
+
+// parseArgs parses flags wherever they appear, so "get 42 --json" works as
+// well as "get --json 42", and returns the other arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// listFlag collects a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// metadataFlags are the asset fields upload and edit accept.
+type metadataFlags struct {
+	title, caption, credit, source, usageNotes, tags *string
+}
+
+func addMetadataFlags(fs *flag.FlagSet) metadataFlags {
+	return metadataFlags{
+		title:      fs.String("title", "", "title"),
+		caption:    fs.String("caption", "", "caption"),
+		credit:     fs.String("credit", "", "credit"),
+		source:     fs.String("source", "", "source"),
+		usageNotes: fs.String("usage-notes", "", "usage notes"),
+		tags:       fs.String("tags", "", "comma-separated tags"),
+	}
+}
+
+// apply copies the flags that were given onto u, so an edit only touches
+// the fields named on the command line.
+func (m metadataFlags) apply(fs *flag.FlagSet, u *ganache.AssetUpdate) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			u.Title = *m.title
+		case "caption":
+			u.Caption = *m.caption
+		case "credit":
+			u.Credit = *m.credit
+		case "source":
+			u.Source = *m.source
+		case "usage-notes":
+			u.UsageNotes = *m.usageNotes
+		case "tags":
+			u.Tags = tagpolicy.Split(*m.tags)
+		}
+	})
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatal(err)
+	}
+}
+
+func printAsset(a ganache.Asset) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range [][2]string{
+		{"ID", string(a.ID)},
+		{"Title", a.Title},
+		{"Caption", a.Caption},
+		{"Credit", a.Credit},
+		{"Source", a.Source},
+		{"Usage notes", a.UsageNotes},
+		{"Tags", strings.Join(a.Tags, ", ")},
+		{"Created", formatTime(a.CreatedAt)},
+		{"Thumbnail", a.Variants.Thumb},
+		{"Content", a.Variants.Content},
+		{"Original", a.Variants.Original},
+	} {
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+	tw.Flush()
+}
+
+func printChanges(changes []revisions.Change) {
+	for _, c := range changes {
+		if c.Field == "tags" {
+			fmt.Printf("\t%s: +%v -%v\n", c.Label, c.Added, c.Removed)
+			continue
+		}
+		fmt.Printf("\t%s: %q -> %q\n", c.Label, c.Before, c.After)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+var errLimit = errors.New("limit reached")
+
+// searchAssets prints the assets matching the query, using the UI's
+// search syntax.
+func searchAssets(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	var tags listFlag
+	fs.Var(&tags, "tag", "only assets with this tag (repeatable)")
+	sort := fs.String("sort", "", "sort order, as in the UI")
+	limit := fs.Int("limit", 50, "stop after this many assets; 0 for all")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest := parseArgs(fs, args)
+
+	q, err := query.Parse(strings.Join(rest, " "))
+	if err != nil {
+		fatal(err)
+	}
+	e := loadEnv()
+	q.Tags = append(q.Tags, tags...)
+	if q.Sort == "" {
+		q.Sort = *sort
+	}
+	q.ExcludeIDs = e.trashed()
+
+	var assets []ganache.Asset
+	err = query.Each(context.Background(), e.client, q, func(a ganache.Asset) error {
+		if *limit > 0 && len(assets) == *limit {
+			return errLimit
+		}
+		assets = append(assets, a)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		fatal(err)
+	}
+	if *asJSON {
+		if assets == nil {
+			assets = []ganache.Asset{}
+		}
+		printJSON(assets)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tCREDIT\tTAGS\tCREATED")
+	for _, a := range assets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.ID, a.Title, a.Credit, strings.Join(a.Tags, ", "), formatTime(a.CreatedAt))
+	}
+	tw.Flush()
+	if errors.Is(err, errLimit) {
+		fmt.Fprintf(os.Stderr, "showing the first %d; use -limit 0 for all\n", *limit)
+	}
+}
+
+func getAsset(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("usage: ganache-admin-cli get [-json] <id>")
+		os.Exit(1)
+	}
+	e := loadEnv()
+	a, err := e.client.GetAsset(context.Background(), rest[0])
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		printJSON(a)
+		return
+	}
+	printAsset(a)
+}
+
+// uploadAsset validates a file like the UI does and uploads it. With
+// -dry-run it stops after validation.
+func uploadAsset(args []string) {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	meta := addMetadataFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "validate without uploading")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("usage: ganache-admin-cli upload [-title t] [-caption c] [-credit c] [-source s] [-usage-notes n] [-tags a,b] [-dry-run] [-json] <file>")
+		os.Exit(1)
+	}
+	e := loadEnv()
+	f, err := os.Open(rest[0])
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+
+	var u ganache.AssetUpdate
+	meta.apply(fs, &u)
+	if u.Title == "" {
+		base := filepath.Base(rest[0])
+		u.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	info, err := e.checkUpload(context.Background(), f)
+	if err != nil {
+		fatal(fmt.Errorf("%s: %w", rest[0], err))
+	}
+	if u.Tags, err = e.policy.Check(u.Tags); err != nil {
+		fatal(err)
+	}
+	if *dryRun {
+		if *asJSON {
+			printJSON(map[string]any{"dryRun": true, "file": rest[0], "contentType": info.ContentType, "width": info.Width, "height": info.Height, "metadata": u})
+			return
+		}
+		fmt.Printf("would upload %s (%s, %dx%d) as %q\n", rest[0], info.ContentType, info.Width, info.Height, u.Title)
+		return
+	}
+	a, err := e.createAsset(context.Background(), f, filepath.Base(rest[0]), u)
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		printJSON(a)
+		return
+	}
+	fmt.Printf("uploaded %s as asset %s\n", rest[0], a.ID)
+}
+
+// editAsset changes the fields given as flags and records the change in
+// the revision history under -user, like an edit in the UI.
+func editAsset(args []string) {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	meta := addMetadataFlags(fs)
+	addTags := fs.String("add-tags", "", "comma-separated tags to add")
+	removeTags := fs.String("remove-tags", "", "comma-separated tags to remove")
+	user := fs.String("user", os.Getenv("USER"), "name recorded in the revision history")
+	dryRun := fs.Bool("dry-run", false, "show the changes without saving them")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("usage: ganache-admin-cli edit [-title t] [-caption c] [-credit c] [-source s] [-usage-notes n] [-tags a,b] [-add-tags a] [-remove-tags b] [-user name] [-dry-run] [-json] <id>")
+		os.Exit(1)
+	}
+	e := loadEnv()
+	ctx := context.Background()
+	before, err := e.client.GetAsset(ctx, rest[0])
+	if err != nil {
+		fatal(err)
+	}
+	after := before.AsUpdate()
+	meta.apply(fs, &after)
+	after.Tags = tagpolicy.Edit(after.Tags, tagpolicy.Split(*addTags), tagpolicy.Split(*removeTags))
+	if after.Tags, err = e.policy.Check(after.Tags); err != nil {
+		fatal(err)
+	}
+	changes := revisions.Diff(before.AsUpdate(), after)
+
+	if *dryRun || len(changes) == 0 {
+		if *asJSON {
+			printJSON(map[string]any{"dryRun": *dryRun, "id": before.ID, "changes": changes})
+			return
+		}
+		if len(changes) == 0 {
+			fmt.Printf("%s: no changes\n", before.ID)
+			return
+		}
+		fmt.Printf("%s: would change\n", before.ID)
+		printChanges(changes)
+		return
+	}
+	updated, err := e.client.UpdateAsset(ctx, string(before.ID), after)
+	if err != nil {
+		fatal(err)
+	}
+	history := revisions.NewStore(filepath.Join(e.dataDir, "revisions"))
+	if _, err := history.Record(revisions.Revision{
+		AssetID: string(before.ID),
+		User:    *user,
+		Note:    "Edited with ganache-admin-cli",
+		Before:  before.AsUpdate(),
+		After:   after,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "record revision of %s: %v\n", before.ID, err)
+	}
+	if *asJSON {
+		printJSON(updated)
+		return
+	}
+	fmt.Printf("%s: updated\n", before.ID)
+	printChanges(changes)
+}
+
+// deleteAssets deletes assets from Ganache for good. Unlike a delete in
+// the UI it does not go through the trash.
+func deleteAssets(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "show what would be deleted")
+	asJSON := fs.Bool("json", false, "print JSON")
+	ids := parseArgs(fs, args)
+	if len(ids) == 0 {
+		fmt.Println("usage: ganache-admin-cli delete [-dry-run] [-json] <id>...")
+		os.Exit(1)
+	}
+	e := loadEnv()
+	ctx := context.Background()
+	type result struct {
+		ID     string `json:"id"`
+		Title  string `json:"title,omitempty"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+	var results []result
+	failed := false
+	for _, id := range ids {
+		res := result{ID: id, Status: "deleted"}
+		a, err := e.client.GetAsset(ctx, id)
+		if err == nil {
+			res.Title = a.Title
+			if *dryRun {
+				res.Status = "would delete"
+			} else {
+				err = e.client.DeleteAsset(ctx, id)
+			}
+		}
+		if err != nil {
+			res.Status, res.Error = "failed", err.Error()
+			failed = true
+		}
+		results = append(results, res)
+	}
+	if *asJSON {
+		printJSON(results)
+	} else {
+		for _, res := range results {
+			fmt.Printf("%s\t%s\t%s", res.ID, res.Status, res.Title)
+			if res.Error != "" {
+				fmt.Printf("\t%s", res.Error)
+			}
+			fmt.Println()
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func listTags(args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "only tags starting with this")
+	limit := fs.Int("limit", 100, "number of tags to list")
+	asJSON := fs.Bool("json", false, "print JSON")
+	parseArgs(fs, args)
+	e := loadEnv()
+	resp, err := e.client.ListTags(context.Background(), *prefix, 1, *limit)
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		if resp.Tags == nil {
+			resp.Tags = []ganache.Tag{}
+		}
+		printJSON(resp.Tags)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tASSETS")
+	for _, t := range resp.Tags {
+		fmt.Fprintf(tw, "%s\t%d\n", t.Name, t.Count)
+	}
+	tw.Flush()
+}
+
+// exportAssets writes every matching asset as CSV or XLSX, like the
+// library's export menu.
+func exportAssets(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "csv or xlsx")
+	var cols, tags listFlag
+	fs.Var(&cols, "col", "column to include (repeatable); all when omitted")
+	fs.Var(&tags, "tag", "only assets with this tag (repeatable)")
+	sort := fs.String("sort", "", "sort order, as in the UI")
+	out := fs.String("o", "", "output file; standard output when omitted")
+	rest := parseArgs(fs, args)
+
+	columns, err := export.Select(cols)
+	if err != nil {
+		fatal(err)
+	}
+	q, err := query.Parse(strings.Join(rest, " "))
+	if err != nil {
+		fatal(err)
+	}
+	e := loadEnv()
+	q.Tags = append(q.Tags, tags...)
+	if q.Sort == "" {
+		q.Sort = *sort
+	}
+	q.ExcludeIDs = e.trashed()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	ew, err := export.NewWriter(*format, w)
+	if err != nil {
+		fatal(err)
+	}
+	exp, err := export.New(ew, columns)
+	if err != nil {
+		fatal(err)
+	}
+	count := 0
+	if err := query.Each(context.Background(), e.client, q, func(a ganache.Asset) error {
+		count++
+		return exp.Asset(a)
+	}); err != nil {
+		fatal(err)
+	}
+	if err := exp.Close(); err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d assets\n", count)
+}
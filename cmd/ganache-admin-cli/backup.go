@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/backup"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/metaimport"
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/revisions"
+)
+
+// backupAssets writes a snapshot of every asset's metadata to a directory.
+// Snapshots after the first only hold the assets created since the last
+// one, unless -full is given.
+func backupAssets(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	full := fs.Bool("full", false, "snapshot every asset, not only those created since the last snapshot")
+	originals := fs.Bool("originals", false, "also download original files")
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fmt.Println("usage: ganache-admin-cli backup [-full] [-originals] <dir>")
+		os.Exit(1)
+	}
+
+	e := loadEnv()
+	res, err := backup.Run(context.Background(), e.client, rest[0], backup.Options{
+		Full:      *full,
+		Originals: *originals,
+		Logf:      log.Printf,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	kind := "incremental"
+	if res.Snapshot.Full {
+		kind = "full"
+	}
+	fmt.Printf("%s: %s snapshot of %d assets", res.Snapshot.Path, kind, res.Assets)
+	if *originals {
+		fmt.Printf(", %d originals downloaded, %d failed", res.Downloaded, res.Failed)
+	}
+	fmt.Println()
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// restoreAssets compares a backup with the live metadata and writes back
+// the assets that differ. Changes are recorded in the revision history.
+func restoreAssets(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	at := fs.String("at", "", "restore the state as of this time (2006-01-02 or RFC 3339); the newest snapshot when empty")
+	var ids, tags listFlag
+	fs.Var(&ids, "id", "only this asset (repeatable)")
+	fs.Var(&tags, "tag", "only assets with this tag (repeatable)")
+	user := fs.String("user", os.Getenv("USER"), "name recorded in the revision history")
+	dryRun := fs.Bool("dry-run", false, "show the changes without saving them")
+	rest := parseArgs(fs, args)
+	if len(rest) < 1 {
+		fmt.Println("usage: ganache-admin-cli restore [-at time] [-id id] [-tag tag] [-dry-run] <dir> [query]")
+		os.Exit(1)
+	}
+	dir := rest[0]
+	q, err := query.Parse(strings.Join(rest[1:], " "))
+	if err != nil {
+		fatal(err)
+	}
+	q.Tags = append(q.Tags, tags...)
+	filter := backup.Filter{Query: q}
+	if len(ids) > 0 {
+		filter.IDs = map[string]bool{}
+		for _, id := range ids {
+			filter.IDs[id] = true
+		}
+	}
+	var asOf time.Time
+	if *at != "" {
+		if asOf, err = parseTime(*at); err != nil {
+			fatal(err)
+		}
+	}
+
+	st, err := backup.Load(dir, asOf)
+	if err != nil {
+		fatal(err)
+	}
+	if st.Taken.IsZero() {
+		fatal(fmt.Errorf("%s: no snapshot to restore from", dir))
+	}
+	rows := st.Rows(filter)
+
+	e := loadEnv()
+	ctx := context.Background()
+	// The backup is restored as it was taken, without the tag policy.
+	results := metaimport.Plan(ctx, e.client.GetAsset, nil, rows)
+	if !*dryRun {
+		history := revisions.NewStore(filepath.Join(e.dataDir, "revisions"))
+		note := "Restored from the backup of " + st.Taken.Format(time.RFC3339)
+		results = metaimport.Apply(ctx, e.client.GetAsset, func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+			if _, err := e.client.UpdateAsset(ctx, string(before.ID), after); err != nil {
+				return err
+			}
+			if _, err := history.Record(revisions.Revision{
+				AssetID: string(before.ID),
+				User:    *user,
+				Note:    note,
+				Before:  before.AsUpdate(),
+				After:   after,
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "record revision of %s: %v\n", before.ID, err)
+			}
+			return nil
+		}, results)
+	}
+
+	for _, res := range results {
+		if res.Status == metaimport.Unchanged {
+			continue
+		}
+		fmt.Printf("%s\t%s", res.Row.ID, res.Status)
+		if res.Err != "" {
+			fmt.Printf("\t%s", res.Err)
+		}
+		fmt.Println()
+		printChanges(res.Changes())
+	}
+	sum := metaimport.Summarize(results)
+	if *dryRun {
+		fmt.Printf("%d to restore, %d unchanged, %d failed (dry run)\n", sum.Changed, sum.Unchanged, sum.Failed)
+	} else {
+		fmt.Printf("%d restored, %d unchanged, %d failed\n", sum.Applied, sum.Unchanged, sum.Failed)
+	}
+	if sum.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseTime accepts a date, read as the end of that day in UTC, or an
+// RFC 3339 time.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q; use 2006-01-02 or RFC 3339", v)
+	}
+	return t, nil
+}
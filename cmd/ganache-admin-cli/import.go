@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/metaimport"
+	"ganache-admin-ui/internal/revisions"
+)
+
+// importCSV previews a metadata CSV against Ganache and, with -apply,
+// writes the changes. Changes are recorded in the admin UI's revision
+// history under -user.
+func importCSV(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "write the changes; without it the import is a dry run")
+	user := fs.String("user", os.Getenv("USER"), "name recorded in the revision history")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("usage: ganache-admin-cli import [-apply] [-user name] <file.csv>")
+		os.Exit(1)
+	}
+
+	e := loadEnv()
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	rows, err := metaimport.Parse(f)
+	f.Close()
+	if err != nil {
+		fatal(fmt.Errorf("%s: %w", fs.Arg(0), err))
+	}
+
+	ctx := context.Background()
+	results := metaimport.Plan(ctx, e.client.GetAsset, e.policy.Check, rows)
+	if *apply {
+		history := revisions.NewStore(filepath.Join(e.dataDir, "revisions"))
+		note := "CSV import of " + filepath.Base(fs.Arg(0))
+		results = metaimport.Apply(ctx, e.client.GetAsset, func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+			if _, err := e.client.UpdateAsset(ctx, string(before.ID), after); err != nil {
+				return err
+			}
+			if _, err := history.Record(revisions.Revision{
+				AssetID: string(before.ID),
+				User:    *user,
+				Note:    note,
+				Before:  before.AsUpdate(),
+				After:   after,
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "record revision of %s: %v\n", before.ID, err)
+			}
+			return nil
+		}, results)
+	}
+
+	for _, res := range results {
+		id := res.Row.ID
+		if id == "" {
+			id = "-"
+		}
+		fmt.Printf("line %d\t%s\t%s", res.Row.Line, id, res.Status)
+		if res.Err != "" {
+			fmt.Printf("\t%s", res.Err)
+		}
+		fmt.Println()
+		for _, c := range res.Changes() {
+			fmt.Printf("\t%s: %q -> %q\n", c.Label, c.Before, c.After)
+		}
+	}
+	sum := metaimport.Summarize(results)
+	if *apply {
+		fmt.Printf("%d applied, %d unchanged, %d failed\n", sum.Applied, sum.Unchanged, sum.Failed)
+	} else {
+		fmt.Printf("%d to change, %d unchanged, %d failed (dry run; use -apply to write)\n", sum.Changed, sum.Unchanged, sum.Failed)
+	}
+	if sum.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -11,10 +11,34 @@ import (
 
 var version = "dev"
 
+const usage = `usage: ganache-admin-cli <command> [flags] [args]
+
+  hashpw                  read a password on stdin and print its bcrypt hash
+  verify <hash>           check a password on stdin against a hash
+  search [query]          search assets using the UI's search syntax
+  get <id>                show one asset
+  upload <file>           validate and upload an image
+  edit <id>               change an asset's metadata
+  delete <id>...          delete assets from Ganache
+  tags                    list tags with their usage counts
+  export [query]          export matching assets as CSV or XLSX
+  import <file.csv>       apply a metadata CSV
+  watch <dir>             upload files dropped into a directory
+  user <command>          list, add, remove and change users in users.yaml
+  backup <dir>            snapshot asset metadata, and optionally originals
+  restore <dir> [query]   write metadata from a backup back to Ganache
+  migrate -to <backend>   copy assets to another Ganache backend
+
+Run a command with -h for its flags. search, get, upload, edit, delete and
+tags print JSON with --json; upload, edit, delete, restore and migrate
+accept --dry-run. GANACHE_BACKEND picks the backend to work on when
+several are configured.`
+
 func main() {
-	fmt.Printf("ganache-admin-cli %s\n", version)
+	// The banner goes to stderr so --json output can be piped.
+	fmt.Fprintf(os.Stderr, "ganache-admin-cli %s\n", version)
 	if len(os.Args) < 2 {
-		fmt.Println("usage: ganache-admin-cli [hashpw|verify <hash>]")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 	switch os.Args[1] {
@@ -26,8 +50,35 @@ func main() {
 			os.Exit(1)
 		}
 		verify(os.Args[2])
+	case "search":
+		searchAssets(os.Args[2:])
+	case "get":
+		getAsset(os.Args[2:])
+	case "upload":
+		uploadAsset(os.Args[2:])
+	case "edit":
+		editAsset(os.Args[2:])
+	case "delete":
+		deleteAssets(os.Args[2:])
+	case "tags":
+		listTags(os.Args[2:])
+	case "export":
+		exportAssets(os.Args[2:])
+	case "import":
+		importCSV(os.Args[2:])
+	case "watch":
+		watch(os.Args[2:])
+	case "user":
+		users(os.Args[2:])
+	case "backup":
+		backupAssets(os.Args[2:])
+	case "restore":
+		restoreAssets(os.Args[2:])
+	case "migrate":
+		migrateAssets(os.Args[2:])
 	default:
 		fmt.Println("unknown command")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/migrate"
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/revisions"
+)
+
+// migrateAssets copies assets from one backend to another and records
+// the mapping, in the same place as the UI's "Copy to…", so assets copied
+// by either are skipped by both. With -sync, assets copied before have
+// their metadata brought up to date instead.
+func migrateAssets(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", os.Getenv("GANACHE_BACKEND"), "backend to copy from; the default backend when empty")
+	to := fs.String("to", "", "backend to copy to")
+	var ids, tags listFlag
+	fs.Var(&ids, "id", "copy this asset (repeatable)")
+	fs.Var(&tags, "tag", "only assets with this tag (repeatable)")
+	sync := fs.Bool("sync", false, "update the metadata of assets copied before; with no query or -id, of every one")
+	user := fs.String("user", os.Getenv("USER"), "name recorded in the mapping and revision history")
+	dryRun := fs.Bool("dry-run", false, "show what would be copied or synced without writing")
+	asJSON := fs.Bool("json", false, "print JSON")
+	rest := parseArgs(fs, args)
+	if *to == "" {
+		fmt.Println("usage: ganache-admin-cli migrate -to <backend> [-from backend] [-id id] [-tag tag] [-sync] [-dry-run] [query]")
+		os.Exit(1)
+	}
+	q, err := query.Parse(strings.Join(rest, " "))
+	if err != nil {
+		fatal(err)
+	}
+	q.Tags = append(q.Tags, tags...)
+
+	base := loadEnv()
+	src, dst := base.on(*from), base.on(*to)
+	if src.backend.Name == dst.backend.Name {
+		fatal(errors.New("-from and -to name the same backend"))
+	}
+	st, err := migrate.NewStore(migrate.Path(base.cfg.DataDir, src.backend.Name, dst.backend.Name))
+	if err != nil {
+		fatal(err)
+	}
+
+	ctx := context.Background()
+	selected := []string(ids)
+	switch {
+	case len(ids) > 0:
+	case len(rest) > 0 || len(tags) > 0:
+		q.ExcludeIDs = src.trashed()
+		err := query.Each(ctx, src.client, q, func(a ganache.Asset) error {
+			selected = append(selected, string(a.ID))
+			return nil
+		})
+		if err != nil {
+			fatal(err)
+		}
+	case *sync:
+		for _, m := range st.List() {
+			selected = append(selected, m.SourceID)
+		}
+	default:
+		fatal(errors.New("choose the assets to copy with a query, -tag or -id, or use -sync"))
+	}
+
+	history := revisions.NewStore(filepath.Join(dst.dataDir, "revisions"))
+	note := "Synced from " + src.backend.Label
+	c := &migrate.Copier{
+		Get:       src.client.GetAsset,
+		Download:  src.client.Download,
+		GetTarget: dst.client.GetAsset,
+		Create: func(ctx context.Context, file io.Reader, filename string, meta ganache.AssetUpdate) (ganache.Asset, error) {
+			return dst.createAsset(ctx, file, filename, meta)
+		},
+		Update: func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+			if _, err := dst.client.UpdateAsset(ctx, string(before.ID), after); err != nil {
+				return err
+			}
+			if _, err := history.Record(revisions.Revision{
+				AssetID: string(before.ID),
+				User:    *user,
+				Note:    note,
+				Before:  before.AsUpdate(),
+				After:   after,
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "record revision of %s: %v\n", before.ID, err)
+			}
+			return nil
+		},
+		Check: dst.policy.Check,
+		Map:   st,
+		User:  *user,
+	}
+
+	results := make([]migrate.Result, 0, len(selected))
+	for _, id := range selected {
+		res := c.Copy(ctx, id, migrate.Options{Sync: *sync, DryRun: *dryRun})
+		results = append(results, res)
+		if *asJSON {
+			continue
+		}
+		fmt.Printf("%s\t%s", res.SourceID, res.Status)
+		if res.TargetID != "" {
+			fmt.Printf("\t%s", res.TargetID)
+		}
+		if res.Err != "" {
+			fmt.Printf("\t%s", res.Err)
+		}
+		fmt.Println()
+		printChanges(res.Changes)
+	}
+	sum := migrate.Summarize(results)
+	if *asJSON {
+		printJSON(results)
+	} else {
+		fmt.Printf("%d copied, %d already copied, %d synced, %d unchanged, %d failed", sum.Copied, sum.AlreadyCopied, sum.Synced, sum.Unchanged, sum.Failed)
+		if *dryRun {
+			fmt.Print(" (dry run)")
+		}
+		fmt.Println()
+	}
+	if sum.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"ganache-admin-ui/internal/auth"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const usersUsage = `usage: ganache-admin-cli user <command> [flags]
+
+  list                    list users and their roles
+  add <name>              add a user; prompts for the password
+  remove <name>           remove a user
+  passwd <name>           change a user's password
+  set-role <name> <role>  set a user's role: admin, editor or viewer, or
+                          none to remove it
+
+set-role -backend <backend> sets the role on one Ganache backend instead:
+admin, editor, viewer or none for no access, or default to remove it.
+Every command takes -file, which defaults to UI_USERS_FILE or ./users.yaml.`
+
+// users edits users.yaml in place. The file keeps its comments and order,
+// is checked the way the server loads it before being saved, and the
+// previous version is kept as users.yaml.bak.
+func users(args []string) {
+	if len(args) == 0 {
+		fmt.Println(usersUsage)
+		os.Exit(1)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet("user "+cmd, flag.ExitOnError)
+	_ = godotenv.Load()
+	file := fs.String("file", valueOrDefault("UI_USERS_FILE", "./users.yaml"), "users file")
+	var role, backend *string
+	var asJSON *bool
+	switch cmd {
+	case "add":
+		role = fs.String("role", "", "role: admin, editor or viewer; empty for the editor default")
+	case "list":
+		asJSON = fs.Bool("json", false, "print JSON")
+	case "set-role":
+		backend = fs.String("backend", "", "set the role on this Ganache backend only")
+	}
+	rest := parseArgs(fs, args)
+	want := map[string]int{"list": 0, "add": 1, "remove": 1, "passwd": 1, "set-role": 2}
+	n, ok := want[cmd]
+	if !ok {
+		fmt.Println("unknown user command")
+		fmt.Println(usersUsage)
+		os.Exit(1)
+	}
+	if len(rest) != n {
+		fmt.Println(usersUsage)
+		os.Exit(1)
+	}
+
+	doc, err := auth.OpenUsersFile(*file)
+	if err != nil {
+		fatal(err)
+	}
+	switch cmd {
+	case "list":
+		list, err := doc.Users()
+		if err != nil {
+			fatal(err)
+		}
+		printUsers(list, *asJSON)
+		return
+	case "add":
+		if err := checkRole(*role); err != nil {
+			fatal(err)
+		}
+		if doc.Has(rest[0]) {
+			fatal(fmt.Errorf("user %s already exists", rest[0]))
+		}
+		err = doc.Add(auth.User{Username: rest[0], PasswordHash: newPasswordHash(), Role: *role})
+	case "remove":
+		err = doc.Remove(rest[0])
+	case "passwd":
+		if !doc.Has(rest[0]) {
+			fatal(fmt.Errorf("no user %s", rest[0]))
+		}
+		err = doc.SetPassword(rest[0], newPasswordHash())
+	case "set-role":
+		r := rest[1]
+		if *backend != "" {
+			switch r {
+			case "default":
+				r = ""
+			case auth.RoleNone:
+			default:
+				if err := checkRole(r); err != nil {
+					fatal(err)
+				}
+			}
+			err = doc.SetBackendRole(rest[0], *backend, r)
+			break
+		}
+		if r == "none" {
+			r = ""
+		}
+		if err := checkRole(r); err != nil {
+			fatal(err)
+		}
+		err = doc.SetRole(rest[0], r)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if err := doc.Save(); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%s: %s %s\n", *file, cmd, rest[0])
+}
+
+func checkRole(role string) error {
+	switch role {
+	case "", auth.RoleAdmin, auth.RoleEditor, auth.RoleViewer:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q; use %s, %s, %s or none", role, auth.RoleAdmin, auth.RoleEditor, auth.RoleViewer)
+}
+
+func printUsers(list []auth.User, asJSON bool) {
+	if asJSON {
+		type entry struct {
+			Username string            `json:"username"`
+			Role     string            `json:"role"`
+			Backends map[string]string `json:"backends,omitempty"`
+		}
+		out := []entry{}
+		for _, u := range list {
+			out = append(out, entry{u.Username, u.Role, u.Backends})
+		}
+		printJSON(out)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tROLE\tBACKENDS")
+	for _, u := range list {
+		var overrides []string
+		for b, r := range u.Backends {
+			overrides = append(overrides, b+"="+r)
+		}
+		sort.Strings(overrides)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Username, u.Role, strings.Join(overrides, ", "))
+	}
+	tw.Flush()
+}
+
+// newPasswordHash prompts for a new password twice without echoing it and
+// returns its bcrypt hash. When stdin is not a terminal the password is
+// read from its first line, for scripts.
+func newPasswordHash() string {
+	password, err := promptNewPassword()
+	if err != nil {
+		fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fatal(err)
+	}
+	return string(hash)
+}
+
+func promptNewPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line == "" {
+			if err == nil {
+				err = errors.New("empty password")
+			}
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return line, nil
+	}
+	fmt.Fprint(os.Stderr, "New password: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(first) == 0 {
+		return "", errors.New("empty password")
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(first), nil
+}
+
+func valueOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/hotfolder"
+)
+
+// watch uploads the files dropped into a directory, applying the same
+// checks as uploads through the admin UI: validation, malware scanning and
+// the tag policy.
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	stable := fs.Duration("stable", 10*time.Second, "how long a file must stay unchanged before it is uploaded")
+	interval := fs.Duration("interval", 5*time.Second, "how often to scan the directory")
+	once := fs.Bool("once", false, "upload the files that are stable now, then exit")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("usage: ganache-admin-cli watch [-stable 10s] [-interval 5s] [-once] <dir>")
+		os.Exit(1)
+	}
+
+	e := loadEnv()
+	upload := func(ctx context.Context, f *os.File, filename string, meta ganache.AssetUpdate) (ganache.Asset, error) {
+		if _, err := e.checkUpload(ctx, f); err != nil {
+			return ganache.Asset{}, err
+		}
+		return e.createAsset(ctx, f, filename, meta)
+	}
+
+	w, err := hotfolder.New(fs.Arg(0), upload, hotfolder.Options{StableFor: *stable, Logf: log.Printf})
+	if err != nil {
+		fatal(err)
+	}
+	defer w.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !*once {
+		log.Printf("watch: watching %s", fs.Arg(0))
+		if err := w.Run(ctx, *interval); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	// A file has to be seen twice, StableFor apart, before it is uploaded.
+	if _, err := w.Scan(ctx); err != nil {
+		fatal(err)
+	}
+	time.Sleep(*stable)
+	res, err := w.Scan(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%d uploaded, %d failed\n", res.Uploaded, res.Failed)
+	if res.Failed > 0 {
+		w.Close()
+		os.Exit(1)
+	}
+}
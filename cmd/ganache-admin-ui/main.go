@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"ganache-admin-ui/internal/auth"
 	"ganache-admin-ui/internal/config"
-	"ganache-admin-ui/internal/ganache"
 	"ganache-admin-ui/internal/httpui"
+	"ganache-admin-ui/internal/logging"
+	"ganache-admin-ui/internal/tracing"
 )
 
 var version = "dev"
@@ -18,22 +25,69 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.LogLevel))
 
 	users, err := auth.LoadUsers(cfg.UsersFile)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Headers:     cfg.Tracing.Headers,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		Version:     version,
+	})
+	if err != nil {
+		fatal(err)
 	}
 
 	sessions := auth.NewSessionStore(12 * time.Hour)
-	client := ganache.NewClient(cfg.Ganache.BaseURL, cfg.Ganache.APIKey, cfg.Ganache.Timeout)
 
-	srv, err := httpui.NewServer(cfg, users, sessions, client)
+	srv, err := httpui.NewServer(cfg, users, sessions)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	log.Printf("ganache-admin-ui %s listening on %s", version, cfg.ListenAddr)
-	if err := http.ListenAndServe(cfg.ListenAddr, srv.Router()); err != nil {
-		log.Fatal(err)
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.MetricsHandler())
+		go func() {
+			slog.Info("metrics listening", "addr", cfg.Metrics.Addr)
+			fatal(http.ListenAndServe(cfg.Metrics.Addr, mux))
+		}()
 	}
+
+	// On SIGINT or SIGTERM, finish the requests in flight and flush the
+	// spans not yet exported before exiting.
+	server := &http.Server{Addr: cfg.ListenAddr, Handler: srv.Router()}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("listening", "addr", cfg.ListenAddr, "version", version)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal(err)
+	}
+	<-stopped
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("flush traces", "error", err)
+	}
+}
+
+// fatal logs err and exits. Once slog is the default logger, log.Fatal
+// would log at the info level.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
 }
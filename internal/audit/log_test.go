@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLogRecentFiltersNewestFirst(t *testing.T) {
+	log := NewLog(filepath.Join(t.TempDir(), "nested", "audit.jsonl"))
+	if entries, err := log.Recent("", 10); err != nil || len(entries) != 0 {
+		t.Fatalf("expected empty log, got %v %v", entries, err)
+	}
+	for _, e := range []Entry{
+		{User: "alice", Action: "tag.rename", Detail: "first"},
+		{User: "bob", Action: "asset.revert", Detail: "other"},
+		{User: "alice", Action: "tag.delete", Detail: "second", Assets: 3},
+		{User: "alice", Action: "tag.merge", Detail: "third"},
+	} {
+		if err := log.Record(e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	entries, err := log.Recent("tag.", 2)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Detail != "third" || entries[1].Assets != 3 || entries[1].Time.IsZero() {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
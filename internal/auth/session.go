@@ -12,6 +12,9 @@ type Session struct {
 	Username  string
 	ExpiresAt time.Time
 	CSRFToken string
+	// Backend is the Ganache backend the user last switched to; empty
+	// for the default.
+	Backend string
 }
 
 type SessionStore struct {
@@ -59,12 +62,39 @@ func (s *SessionStore) Get(id string) (Session, bool) {
 	return sess, true
 }
 
+// SetBackend records the backend the session switched to.
+func (s *SessionStore) SetBackend(id, backend string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	sess.Backend = backend
+	s.sessions[id] = sess
+	return true
+}
+
 func (s *SessionStore) Delete(id string) {
 	s.mu.Lock()
 	delete(s.sessions, id)
 	s.mu.Unlock()
 }
 
+// Count returns the number of sessions that have not expired.
+func (s *SessionStore) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, sess := range s.sessions {
+		if !now.After(sess.ExpiresAt) {
+			n++
+		}
+	}
+	return n
+}
+
 func (s *SessionStore) CleanupExpired() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
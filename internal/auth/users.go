@@ -2,15 +2,30 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
 )
 
+// Roles. RoleAdmin grants access to administrative pages such as the
+// trash; editors, the default, can use everything else. Viewers can browse,
+// search and download but not change assets. RoleNone is only used per
+// backend, to hide a backend from a user.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+	RoleNone   = "none"
+)
+
 type User struct {
 	Username     string `yaml:"username"`
 	PasswordHash string `yaml:"passwordHash"`
+	Role         string `yaml:"role,omitempty"`
+	// Backends overrides Role on individual Ganache backends, by name.
+	Backends map[string]string `yaml:"backends,omitempty"`
 }
 
 type UsersFile struct {
@@ -39,6 +54,14 @@ func NewUserStore(list []User) (*UserStore, error) {
 		if u.Username == "" || u.PasswordHash == "" {
 			return nil, errors.New("username and passwordHash required")
 		}
+		if u.Role != "" && !validRole(u.Role) {
+			return nil, fmt.Errorf("user %s: unknown role %q", u.Username, u.Role)
+		}
+		for backend, role := range u.Backends {
+			if role != RoleNone && !validRole(role) {
+				return nil, fmt.Errorf("user %s: unknown role %q for backend %s", u.Username, role, backend)
+			}
+		}
 		users[u.Username] = u
 	}
 	return &UserStore{users: users}, nil
@@ -51,3 +74,25 @@ func (s *UserStore) Validate(username, password string) bool {
 	}
 	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
 }
+
+// RoleOn returns the role username has on a backend: its override for the
+// backend if there is one, otherwise its role. Unknown users have
+// RoleNone.
+func (s *UserStore) RoleOn(username, backend string) string {
+	user, ok := s.users[username]
+	if !ok {
+		return RoleNone
+	}
+	role := user.Backends[backend]
+	if role == "" {
+		role = user.Role
+	}
+	if role == "" {
+		role = RoleEditor
+	}
+	return role
+}
+
+func validRole(role string) bool {
+	return role == RoleAdmin || role == RoleEditor || role == RoleViewer
+}
@@ -35,6 +35,49 @@ func TestLoadUsersAndValidate(t *testing.T) {
 	if store.Validate("alice", "wrong") {
 		t.Fatalf("expected invalid password")
 	}
+	if store.RoleOn("alice", "prod") != RoleEditor {
+		t.Fatalf("alice has no role, so edits")
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	store, err := NewUserStore([]User{{Username: "root", PasswordHash: "h", Role: RoleAdmin}, {Username: "ed", PasswordHash: "h"}})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if store.RoleOn("root", "prod") != RoleAdmin || store.RoleOn("ed", "prod") != RoleEditor || store.RoleOn("nobody", "prod") != RoleNone {
+		t.Fatalf("unexpected roles")
+	}
+	if _, err := NewUserStore([]User{{Username: "x", PasswordHash: "h", Role: "owner"}}); err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+	if _, err := NewUserStore([]User{{Username: "x", PasswordHash: "h", Role: RoleNone}}); err == nil {
+		t.Fatalf("none is only valid per backend")
+	}
+	if _, err := NewUserStore([]User{{Username: "x", PasswordHash: "h", Backends: map[string]string{"prod": "owner"}}}); err == nil {
+		t.Fatalf("expected unknown backend role to be rejected")
+	}
+}
+
+func TestUserRolesPerBackend(t *testing.T) {
+	store, err := NewUserStore([]User{
+		{Username: "root", PasswordHash: "h", Role: RoleAdmin, Backends: map[string]string{"production": RoleViewer}},
+		{Username: "ed", PasswordHash: "h", Backends: map[string]string{"production": RoleNone}},
+	})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	for _, c := range []struct{ user, backend, want string }{
+		{"root", "staging", RoleAdmin},
+		{"root", "production", RoleViewer},
+		{"ed", "staging", RoleEditor},
+		{"ed", "production", RoleNone},
+		{"nobody", "staging", RoleNone},
+	} {
+		if got := store.RoleOn(c.user, c.backend); got != c.want {
+			t.Errorf("%s on %s: got %q, want %q", c.user, c.backend, got, c.want)
+		}
+	}
 }
 
 func TestSessionStore(t *testing.T) {
@@ -46,7 +89,19 @@ func TestSessionStore(t *testing.T) {
 	if _, ok := store.Get(sess.ID); !ok {
 		t.Fatalf("expected session present")
 	}
+	if !store.SetBackend(sess.ID, "staging") {
+		t.Fatalf("expected the backend to be set")
+	}
+	if got, _ := store.Get(sess.ID); got.Backend != "staging" || got.CSRFToken != sess.CSRFToken {
+		t.Fatalf("unexpected session %+v", got)
+	}
+	if n := store.Count(); n != 1 {
+		t.Fatalf("expected one active session, got %d", n)
+	}
 	time.Sleep(30 * time.Millisecond)
+	if n := store.Count(); n != 0 {
+		t.Fatalf("expected expired sessions not counted, got %d", n)
+	}
 	if _, ok := store.Get(sess.ID); ok {
 		t.Fatalf("expected session expired")
 	}
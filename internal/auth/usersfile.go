@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"ganache-admin-ui/internal/filestore"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UsersDocument is a users.yaml file held as a YAML node tree, so it can be
+// edited and written back with its comments and ordering intact.
+type UsersDocument struct {
+	path string
+	doc  yaml.Node
+	list *yaml.Node
+}
+
+// OpenUsersFile reads path for editing. A missing file opens as an empty
+// document, which Save creates.
+func OpenUsersFile(path string) (*UsersDocument, error) {
+	d := &UsersDocument{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &d.doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if d.doc.Kind == 0 {
+		d.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := d.doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping with a users list", path)
+	}
+	if d.list = mappingValue(root, "users"); d.list == nil {
+		d.list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, scalar("users"), d.list)
+	}
+	if d.list.Kind != yaml.SequenceNode {
+		// "users:" with nothing after it decodes as null.
+		if d.list.Kind != yaml.ScalarNode || d.list.Tag != "!!null" {
+			return nil, fmt.Errorf("%s: users must be a list", path)
+		}
+		*d.list = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: d.list.HeadComment, LineComment: d.list.LineComment}
+	}
+	return d, nil
+}
+
+// Users decodes the document's users in file order.
+func (d *UsersDocument) Users() ([]User, error) {
+	var users []User
+	if err := d.list.Decode(&users); err != nil {
+		return nil, fmt.Errorf("%s: %w", d.path, err)
+	}
+	return users, nil
+}
+
+// Has reports whether the document lists username.
+func (d *UsersDocument) Has(username string) bool {
+	return d.find(username) != nil
+}
+
+// Add appends a user to the end of the list.
+func (d *UsersDocument) Add(u User) error {
+	if d.Has(u.Username) {
+		return fmt.Errorf("user %s already exists", u.Username)
+	}
+	entry := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	setMappingValue(entry, "username", u.Username)
+	setMappingValue(entry, "passwordHash", u.PasswordHash)
+	if u.Role != "" {
+		setMappingValue(entry, "role", u.Role)
+	}
+	d.list.Content = append(d.list.Content, entry)
+	return nil
+}
+
+// Remove deletes a user along with the comments attached to its entry.
+func (d *UsersDocument) Remove(username string) error {
+	for i, entry := range d.list.Content {
+		if entryName(entry) == username {
+			d.list.Content = append(d.list.Content[:i], d.list.Content[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no user %s", username)
+}
+
+// SetPassword replaces a user's password hash.
+func (d *UsersDocument) SetPassword(username, hash string) error {
+	entry := d.find(username)
+	if entry == nil {
+		return fmt.Errorf("no user %s", username)
+	}
+	setMappingValue(entry, "passwordHash", hash)
+	return nil
+}
+
+// SetRole changes a user's role. An empty role removes it.
+func (d *UsersDocument) SetRole(username, role string) error {
+	entry := d.find(username)
+	if entry == nil {
+		return fmt.Errorf("no user %s", username)
+	}
+	if role != "" {
+		setMappingValue(entry, "role", role)
+		return nil
+	}
+	deleteMappingValue(entry, "role")
+	return nil
+}
+
+// SetBackendRole changes a user's role on one backend. An empty role
+// removes the override, so the user's own role applies again.
+func (d *UsersDocument) SetBackendRole(username, backend, role string) error {
+	entry := d.find(username)
+	if entry == nil {
+		return fmt.Errorf("no user %s", username)
+	}
+	backends := mappingValue(entry, "backends")
+	if backends == nil || backends.Kind != yaml.MappingNode {
+		if role == "" {
+			return nil
+		}
+		deleteMappingValue(entry, "backends")
+		backends = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		entry.Content = append(entry.Content, scalar("backends"), backends)
+	}
+	if role != "" {
+		setMappingValue(backends, backend, role)
+		return nil
+	}
+	deleteMappingValue(backends, backend)
+	if len(backends.Content) == 0 {
+		deleteMappingValue(entry, "backends")
+	}
+	return nil
+}
+
+// Save checks the edited users the way the server will load them, then
+// replaces the file atomically. The previous version is kept next to it
+// with a .bak suffix.
+func (d *UsersDocument) Save() error {
+	users, err := d.Users()
+	if err != nil {
+		return err
+	}
+	if _, err := NewUserStore(users); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, u := range users {
+		if seen[u.Username] {
+			return fmt.Errorf("user %s is listed twice", u.Username)
+		}
+		seen[u.Username] = true
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&d.doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	mode := fs.FileMode(0o600)
+	old, err := os.ReadFile(d.path)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(d.path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := filestore.WriteFile(d.path+".bak", old, mode); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return filestore.WriteFile(d.path, buf.Bytes(), mode)
+}
+
+func (d *UsersDocument) find(username string) *yaml.Node {
+	for _, entry := range d.list.Content {
+		if entryName(entry) == username {
+			return entry
+		}
+	}
+	return nil
+}
+
+func entryName(entry *yaml.Node) string {
+	if v := mappingValue(entry, "username"); v != nil {
+		return v.Value
+	}
+	return ""
+}
+
+// mappingValue returns the value node for key, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to a string, keeping the existing node and its
+// comments when there is one.
+func setMappingValue(m *yaml.Node, key, value string) {
+	if v := mappingValue(m, key); v != nil {
+		v.Kind, v.Tag, v.Value, v.Content = yaml.ScalarNode, "!!str", value, nil
+		return
+	}
+	v := scalar(value)
+	if key == "passwordHash" {
+		v.Style = yaml.DoubleQuotedStyle
+	}
+	m.Content = append(m.Content, scalar(key), v)
+}
+
+// deleteMappingValue removes key and its value, if present.
+func deleteMappingValue(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
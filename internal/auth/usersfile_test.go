@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const usersYAML = `# Accounts for the admin UI.
+users:
+  # Site owner.
+  - username: admin
+    passwordHash: "$2a$12$admin"
+    role: admin # can see /trash
+  - username: editor
+    passwordHash: "$2a$12$editor"
+`
+
+func writeUsers(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUsersDocumentRoundTripsUnchanged(t *testing.T) {
+	path := writeUsers(t, usersYAML)
+	d, err := OpenUsersFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != usersYAML {
+		t.Fatalf("file changed on a no-op save:\n%s", data)
+	}
+}
+
+func TestUsersDocumentEditsKeepComments(t *testing.T) {
+	path := writeUsers(t, usersYAML)
+	d, err := OpenUsersFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := d.Add(User{Username: "ed", PasswordHash: "$2a$12$ed", Role: RoleAdmin}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := d.Add(User{Username: "editor", PasswordHash: "x"}); err == nil {
+		t.Fatal("expected a duplicate username to be rejected")
+	}
+	if err := d.SetPassword("admin", "$2a$12$new"); err != nil {
+		t.Fatalf("passwd: %v", err)
+	}
+	if err := d.SetRole("admin", ""); err != nil {
+		t.Fatalf("set-role: %v", err)
+	}
+	if err := d.Remove("editor"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := d.Remove("nobody"); err == nil {
+		t.Fatal("expected removing an unknown user to fail")
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	want := `# Accounts for the admin UI.
+users:
+  # Site owner.
+  - username: admin
+    passwordHash: "$2a$12$new"
+  - username: ed
+    passwordHash: "$2a$12$ed"
+    role: admin
+`
+	data, _ := os.ReadFile(path)
+	if string(data) != want {
+		t.Fatalf("unexpected file:\n%s", data)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != usersYAML {
+		t.Fatalf("expected the previous file as a backup, got %q, %v", backup, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("file mode should be kept: %v, %v", info.Mode(), err)
+	}
+	if _, err := LoadUsers(path); err != nil {
+		t.Fatalf("saved file should load: %v", err)
+	}
+}
+
+func TestUsersDocumentValidatesBeforeSaving(t *testing.T) {
+	path := writeUsers(t, usersYAML)
+	d, err := OpenUsersFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := d.SetRole("editor", "owner"); err != nil {
+		t.Fatalf("set-role: %v", err)
+	}
+	if err := d.Save(); err == nil || !strings.Contains(err.Error(), "unknown role") {
+		t.Fatalf("expected the unknown role to be rejected, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != usersYAML {
+		t.Fatal("a rejected edit must not touch the file")
+	}
+}
+
+func TestUsersDocumentCreatesMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	d, err := OpenUsersFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := d.Add(User{Username: "admin", PasswordHash: "$2a$12$h", Role: RoleAdmin}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	store, err := LoadUsers(path)
+	if err != nil || store.RoleOn("admin", "prod") != RoleAdmin {
+		t.Fatalf("expected the new file to load, got %v", err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Fatal("there is nothing to back up for a new file")
+	}
+}
+
+func TestUsersDocumentSetsBackendRoles(t *testing.T) {
+	path := writeUsers(t, usersYAML)
+	d, err := OpenUsersFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := d.SetBackendRole("editor", "production", RoleViewer); err != nil {
+		t.Fatalf("set backend role: %v", err)
+	}
+	if err := d.SetBackendRole("editor", "staging", RoleNone); err != nil {
+		t.Fatalf("set backend role: %v", err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	store, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if store.RoleOn("editor", "production") != RoleViewer || store.RoleOn("editor", "staging") != RoleNone {
+		t.Fatal("expected the backend roles to be saved")
+	}
+
+	d, _ = OpenUsersFile(path)
+	d.SetBackendRole("editor", "production", "")
+	d.SetBackendRole("editor", "staging", "")
+	if err := d.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != usersYAML {
+		t.Fatalf("expected removing every override to drop backends, got:\n%s", data)
+	}
+}
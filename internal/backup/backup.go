@@ -0,0 +1,361 @@
+// Package backup keeps snapshots of asset metadata outside Ganache, so
+// captions, credits and tags survive the loss of its database.
+//
+// A backup directory holds one JSONL file per snapshot, one asset per
+// line. A full snapshot lists every asset; an incremental one only the
+// assets created since the last snapshot, found by paging Ganache newest
+// first. Edits to older assets are picked up by the next full snapshot.
+// Originals can be kept too, in originals/ under their SHA-256, so a file
+// shared by several snapshots or assets is stored once.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// pageSize is the Ganache page size used while backing up.
+const pageSize = 100
+
+// OriginalsDir holds the downloaded originals inside a backup directory.
+const OriginalsDir = "originals"
+
+// Snapshot file names are the kind followed by the UTC time they were
+// taken, so they sort in order within a kind.
+const (
+	fullPrefix        = "full-"
+	incrementalPrefix = "incr-"
+	stampFormat       = "20060102T150405Z"
+	snapshotExt       = ".jsonl"
+)
+
+// Record is one asset in a snapshot. SHA256 names the original in
+// OriginalsDir when it was downloaded.
+type Record struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Caption    string    `json:"caption,omitempty"`
+	Credit     string    `json:"credit,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	UsageNotes string    `json:"usageNotes,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	SHA256     string    `json:"sha256,omitempty"`
+}
+
+func newRecord(a ganache.Asset) Record {
+	return Record{
+		ID:         string(a.ID),
+		Title:      a.Title,
+		Caption:    a.Caption,
+		Credit:     a.Credit,
+		Source:     a.Source,
+		UsageNotes: a.UsageNotes,
+		Tags:       a.Tags,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+// Asset returns the record as an asset without variants.
+func (r Record) Asset() ganache.Asset {
+	return ganache.Asset{
+		ID:         ganache.StringID(r.ID),
+		Title:      r.Title,
+		Caption:    r.Caption,
+		Credit:     r.Credit,
+		Source:     r.Source,
+		UsageNotes: r.UsageNotes,
+		Tags:       r.Tags,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// Snapshot is one file in a backup directory.
+type Snapshot struct {
+	Path  string
+	Full  bool
+	Taken time.Time
+}
+
+// Snapshots lists the snapshots in dir, oldest first. A missing directory
+// has none.
+func Snapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+		s := Snapshot{Path: filepath.Join(dir, name)}
+		stamp := strings.TrimSuffix(name, snapshotExt)
+		switch {
+		case strings.HasPrefix(stamp, fullPrefix):
+			s.Full, stamp = true, strings.TrimPrefix(stamp, fullPrefix)
+		case strings.HasPrefix(stamp, incrementalPrefix):
+			stamp = strings.TrimPrefix(stamp, incrementalPrefix)
+		default:
+			continue
+		}
+		if s.Taken, err = time.Parse(stampFormat, stamp); err != nil {
+			continue
+		}
+		snaps = append(snaps, s)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Taken.Before(snaps[j].Taken) })
+	return snaps, nil
+}
+
+// State is the metadata of every asset as of a snapshot: the last full
+// snapshot up to it, with the incremental ones after it applied.
+type State struct {
+	Records map[string]Record
+	// Taken is when the newest snapshot in the state was taken; zero
+	// when there is none.
+	Taken time.Time
+}
+
+// Load reads the state of dir as of at, or as of the newest snapshot when
+// at is zero.
+func Load(dir string, at time.Time) (State, error) {
+	st := State{Records: map[string]Record{}}
+	snaps, err := Snapshots(dir)
+	if err != nil {
+		return st, err
+	}
+	start := -1
+	for i, s := range snaps {
+		if !at.IsZero() && s.Taken.After(at) {
+			snaps = snaps[:i]
+			break
+		}
+		if s.Full {
+			start = i
+		}
+	}
+	if start < 0 {
+		return st, nil
+	}
+	for _, s := range snaps[start:] {
+		if err := readSnapshot(s.Path, func(r Record) { st.Records[r.ID] = r }); err != nil {
+			return st, err
+		}
+		st.Taken = s.Taken
+	}
+	return st, nil
+}
+
+func readSnapshot(path string, fn func(Record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return fmt.Errorf("%s line %d: %w", filepath.Base(path), line, err)
+		}
+		fn(r)
+	}
+	return sc.Err()
+}
+
+// Source is the Ganache API a backup reads from.
+type Source interface {
+	SearchAssets(ctx context.Context, q string, tags []string, page, pageSize int, sort string) (ganache.SearchResponse, error)
+	Download(ctx context.Context, rawURL string) (io.ReadCloser, error)
+}
+
+// Options tune a backup. Full snapshots every asset even when there is an
+// earlier snapshot; Originals downloads each asset's original file.
+type Options struct {
+	Full      bool
+	Originals bool
+	Logf      func(format string, args ...any)
+}
+
+// Result describes the snapshot a backup wrote.
+type Result struct {
+	Snapshot Snapshot
+	Assets   int
+	// Downloaded counts the originals fetched; originals already in the
+	// directory are not fetched again.
+	Downloaded int
+	// Failed counts the originals that could not be downloaded. Their
+	// metadata is still in the snapshot.
+	Failed int
+}
+
+// Run writes a new snapshot to dir. Without a full snapshot to build on,
+// the snapshot is full whatever opts says. The snapshot file only appears
+// once it is complete.
+func Run(ctx context.Context, src Source, dir string, opts Options) (Result, error) {
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	prev, err := Load(dir, time.Time{})
+	if err != nil {
+		return Result{}, err
+	}
+	full := opts.Full || prev.Taken.IsZero()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Result{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if !now.After(prev.Taken) {
+		// Keep snapshot names unique and in order.
+		now = prev.Taken.Add(time.Second)
+	}
+	prefix := incrementalPrefix
+	if full {
+		prefix = fullPrefix
+	}
+	res := Result{Snapshot: Snapshot{
+		Path:  filepath.Join(dir, prefix+now.Format(stampFormat)+snapshotExt),
+		Full:  full,
+		Taken: now,
+	}}
+	tmp, err := os.CreateTemp(dir, ".snapshot-*.tmp")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	seen := map[string]bool{}
+	for page := 1; ; page++ {
+		resp, err := src.SearchAssets(ctx, "", nil, page, pageSize, "newest")
+		if err != nil {
+			return Result{}, err
+		}
+		caughtUp := false
+		for _, a := range resp.Assets {
+			id := string(a.ID)
+			if seen[id] {
+				// Uploads during the backup shift the pages.
+				continue
+			}
+			seen[id] = true
+			old, known := prev.Records[id]
+			if !full && known && old.CreatedAt.Equal(a.CreatedAt) {
+				caughtUp = true
+				break
+			}
+			rec := newRecord(a)
+			if known && old.CreatedAt.Equal(a.CreatedAt) && old.SHA256 != "" && exists(OriginalPath(dir, old.SHA256)) {
+				rec.SHA256 = old.SHA256
+			} else if opts.Originals && a.Variants.Original != "" {
+				sum, err := download(ctx, src, dir, a.Variants.Original)
+				if err != nil {
+					if ctx.Err() != nil {
+						return Result{}, ctx.Err()
+					}
+					opts.Logf("asset %s: original: %v", id, err)
+					res.Failed++
+				} else {
+					rec.SHA256 = sum
+					res.Downloaded++
+				}
+			}
+			if err := enc.Encode(rec); err != nil {
+				return Result{}, err
+			}
+			res.Assets++
+		}
+		size := resp.PageSize
+		if size <= 0 {
+			size = pageSize
+		}
+		if caughtUp || len(resp.Assets) < size || (resp.Total > 0 && page*size >= resp.Total) {
+			break
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return Result{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return Result{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Result{}, err
+	}
+	if err := os.Rename(tmp.Name(), res.Snapshot.Path); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// OriginalPath returns where the original with the given SHA-256 is kept
+// in dir.
+func OriginalPath(dir, sum string) string {
+	return filepath.Join(dir, OriginalsDir, sum[:2], sum)
+}
+
+// download stores the file at rawURL under its SHA-256 and returns it.
+func download(ctx context.Context, src Source, dir, rawURL string) (string, error) {
+	body, err := src.Download(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	tmpDir := filepath.Join(dir, OriginalsDir)
+	if err := os.MkdirAll(tmpDir, 0o700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(tmpDir, ".download-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), body); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	dst := OriginalPath(dir, sum)
+	if exists(dst) {
+		return sum, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(tmp.Name(), dst)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
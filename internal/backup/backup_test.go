@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// fakeGanache pages assets newest first, like Ganache's "newest" sort.
+type fakeGanache struct {
+	assets    []ganache.Asset
+	files     map[string]string
+	pages     int
+	downloads int
+}
+
+func (g *fakeGanache) SearchAssets(_ context.Context, q string, tags []string, page, size int, sort string) (ganache.SearchResponse, error) {
+	g.pages++
+	if sort != "newest" {
+		return ganache.SearchResponse{}, errors.New("expected newest first")
+	}
+	start := (page - 1) * size
+	end := start + size
+	if start > len(g.assets) {
+		start = len(g.assets)
+	}
+	if end > len(g.assets) {
+		end = len(g.assets)
+	}
+	return ganache.SearchResponse{Assets: g.assets[start:end], Page: page, PageSize: size, Total: len(g.assets)}, nil
+}
+
+func (g *fakeGanache) Download(_ context.Context, rawURL string) (io.ReadCloser, error) {
+	g.downloads++
+	content, ok := g.files[rawURL]
+	if !ok {
+		return nil, &ganache.APIError{StatusCode: 404, Message: "not found"}
+	}
+	return io.NopCloser(bytes.NewBufferString(content)), nil
+}
+
+// add puts a new asset at the front, as the newest.
+func (g *fakeGanache) add(id, title string, created time.Time) {
+	a := ganache.Asset{ID: ganache.StringID(id), Title: title, Tags: []string{"cup"}, CreatedAt: created}
+	a.Variants.Original = "http://ganache/files/" + id
+	if g.files == nil {
+		g.files = map[string]string{}
+	}
+	g.files[a.Variants.Original] = "image " + title
+	g.assets = append([]ganache.Asset{a}, g.assets...)
+}
+
+func run(t *testing.T, g *fakeGanache, dir string, opts Options) Result {
+	t.Helper()
+	res, err := Run(context.Background(), g, dir, opts)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	return res
+}
+
+func TestRunIsIncrementalAfterAFullSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := &fakeGanache{}
+	for i := 0; i < 150; i++ {
+		g.add(fmt.Sprintf("a%d", i), "old", base.Add(time.Duration(i)*time.Minute))
+	}
+
+	first := run(t, g, dir, Options{})
+	if !first.Snapshot.Full || first.Assets != 150 {
+		t.Fatalf("the first snapshot should be full, got %+v", first)
+	}
+
+	g.add("new1", "New one", base.Add(time.Hour*24))
+	g.add("new2", "New two", base.Add(time.Hour*25))
+	g.pages = 0
+	second := run(t, g, dir, Options{})
+	if second.Snapshot.Full || second.Assets != 2 || g.pages != 1 {
+		t.Fatalf("expected an incremental snapshot of the two new assets from one page, got %+v after %d pages", second, g.pages)
+	}
+	if !second.Snapshot.Taken.After(first.Snapshot.Taken) {
+		t.Fatal("snapshots taken within a second should still be ordered")
+	}
+
+	st, err := Load(dir, time.Time{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(st.Records) != 152 || st.Records["new2"].Title != "New two" {
+		t.Fatalf("expected the full and incremental snapshots merged, got %d records", len(st.Records))
+	}
+	old, err := Load(dir, first.Snapshot.Taken)
+	if err != nil || len(old.Records) != 150 {
+		t.Fatalf("expected the state as of the first snapshot, got %d records, %v", len(old.Records), err)
+	}
+
+	third := run(t, g, dir, Options{Full: true})
+	if !third.Snapshot.Full || third.Assets != 152 {
+		t.Fatalf("expected a full snapshot, got %+v", third)
+	}
+}
+
+func TestRunStoresOriginalsByContent(t *testing.T) {
+	dir := t.TempDir()
+	g := &fakeGanache{}
+	now := time.Now()
+	g.add("1", "same", now)
+	g.add("2", "same", now.Add(time.Second))
+	g.add("3", "missing", now.Add(2*time.Second))
+	delete(g.files, "http://ganache/files/3")
+
+	res := run(t, g, dir, Options{Originals: true})
+	if res.Downloaded != 2 || res.Failed != 1 {
+		t.Fatalf("expected two downloads and one failure, got %+v", res)
+	}
+	sum := sha256.Sum256([]byte("image same"))
+	want := hex.EncodeToString(sum[:])
+	st, _ := Load(dir, time.Time{})
+	if st.Records["1"].SHA256 != want || st.Records["2"].SHA256 != want || st.Records["3"].SHA256 != "" {
+		t.Fatalf("unexpected hashes %+v", st.Records)
+	}
+	if data, err := os.ReadFile(OriginalPath(dir, want)); err != nil || string(data) != "image same" {
+		t.Fatalf("expected the original under its hash, got %q, %v", data, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, OriginalsDir, ".download-*")); len(leftovers) != 0 {
+		t.Fatalf("temporary downloads left behind: %v", leftovers)
+	}
+
+	g.downloads = 0
+	run(t, g, dir, Options{Full: true, Originals: true})
+	if g.downloads != 1 {
+		t.Fatalf("a full snapshot should only fetch originals it does not have, fetched %d", g.downloads)
+	}
+}
+
+func TestRunLeavesNoSnapshotOnError(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Run(context.Background(), failingSource{}, dir, Options{})
+	if err == nil {
+		t.Fatal("expected the search error")
+	}
+	if snaps, _ := Snapshots(dir); len(snaps) != 0 {
+		t.Fatalf("a failed backup must not leave a snapshot, got %v", snaps)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+type failingSource struct{}
+
+func (failingSource) SearchAssets(context.Context, string, []string, int, int, string) (ganache.SearchResponse, error) {
+	return ganache.SearchResponse{}, errors.New("ganache down")
+}
+
+func (failingSource) Download(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("ganache down")
+}
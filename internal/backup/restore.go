@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"sort"
+	"strings"
+
+	"ganache-admin-ui/internal/metaimport"
+	"ganache-admin-ui/internal/query"
+)
+
+// Filter picks the records a restore applies to. IDs, when set, limits it
+// to those assets; Query matches records with the UI's search syntax.
+type Filter struct {
+	IDs   map[string]bool
+	Query query.Query
+}
+
+// Match reports whether the filter selects r. Unlike a Ganache search, the
+// query's words and tags are matched against the record itself: words as
+// case-insensitive substrings of any field, tags exactly.
+func (f Filter) Match(r Record) bool {
+	if len(f.IDs) > 0 && !f.IDs[r.ID] {
+		return false
+	}
+	a := r.Asset()
+	if !f.Query.Match(a) {
+		return false
+	}
+	for _, t := range f.Query.Tags {
+		found := false
+		for _, have := range r.Tags {
+			if strings.EqualFold(have, t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	text := strings.ToLower(strings.Join([]string{r.Title, r.Caption, r.Credit, r.Source, r.UsageNotes, strings.Join(r.Tags, " ")}, "\n"))
+	for _, w := range f.Query.Terms {
+		if !strings.Contains(text, strings.ToLower(w)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rows turns the records the filter selects into import rows, sorted by
+// asset ID, so a restore can be planned and applied like a metadata
+// import. Every field is set, so restoring replaces the live metadata
+// rather than merging with it.
+func (st State) Rows(f Filter) []metaimport.Row {
+	var recs []Record
+	for _, r := range st.Records {
+		if f.Match(r) {
+			recs = append(recs, r)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID < recs[j].ID })
+	rows := make([]metaimport.Row, len(recs))
+	for i, r := range recs {
+		rows[i] = metaimport.Row{
+			Line: i + 1,
+			ID:   r.ID,
+			Fields: map[string]string{
+				"title":      r.Title,
+				"caption":    r.Caption,
+				"credit":     r.Credit,
+				"source":     r.Source,
+				"usageNotes": r.UsageNotes,
+				"tags":       strings.Join(r.Tags, ", "),
+			},
+		}
+	}
+	return rows
+}
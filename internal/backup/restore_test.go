@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/metaimport"
+	"ganache-admin-ui/internal/query"
+)
+
+func TestFilterMatchesRecords(t *testing.T) {
+	r := Record{ID: "7", Title: "Kickoff", Caption: "Final at noon", Credit: "AP", Tags: []string{"Cup"}, CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	cases := []struct {
+		ids  map[string]bool
+		q    string
+		want bool
+	}{
+		{nil, "", true},
+		{map[string]bool{"7": true}, "", true},
+		{map[string]bool{"8": true}, "", false},
+		{nil, "noon", true},
+		{nil, "evening", false},
+		{nil, "tag:cup credit:ap", true},
+		{nil, "tag:league", false},
+		{nil, "-credit:ap", false},
+	}
+	for _, c := range cases {
+		q, err := query.Parse(c.q)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.q, err)
+		}
+		if got := (Filter{IDs: c.ids, Query: q}).Match(r); got != c.want {
+			t.Errorf("ids %v, query %q: got %v, want %v", c.ids, c.q, got, c.want)
+		}
+	}
+}
+
+func TestRowsReplaceLiveMetadata(t *testing.T) {
+	st := State{Records: map[string]Record{
+		"2": {ID: "2", Title: "Two", Tags: []string{"cup", "final"}},
+		"1": {ID: "1", Title: "One", Credit: "AP"},
+	}}
+	rows := st.Rows(Filter{})
+	if len(rows) != 2 || rows[0].ID != "1" || rows[1].ID != "2" {
+		t.Fatalf("expected rows sorted by ID, got %+v", rows)
+	}
+
+	live := map[string]ganache.Asset{
+		"1": {ID: "1", Title: "One", Credit: "Reuters", Caption: "added later"},
+		"2": {ID: "2", Title: "Two", Tags: []string{"final", "cup"}},
+	}
+	get := func(_ context.Context, id string) (ganache.Asset, error) { return live[id], nil }
+	plan := metaimport.Plan(context.Background(), get, nil, rows)
+	if plan[0].Status != metaimport.Changed || plan[1].Status != metaimport.Unchanged {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	var fields []string
+	for _, c := range plan[0].Changes() {
+		fields = append(fields, c.Field)
+	}
+	if strings.Join(fields, ",") != "caption,credit" {
+		t.Fatalf("restoring should reset every field, got changes to %v", fields)
+	}
+}
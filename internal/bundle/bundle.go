@@ -0,0 +1,385 @@
+// Package bundle streams assets as a ZIP archive: one file per asset, taken
+// from one of its variants, plus the metadata as metadata.csv or as XMP
+// sidecars. Variants are fetched a few at a time into temporary files and
+// written to the archive in order, so neither the archive nor the files are
+// held in memory.
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/export"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/xmp"
+)
+
+// Variants and MetadataFormats list the accepted option values; the first
+// of each is the default.
+var (
+	Variants        = []string{"original", "content", "thumb"}
+	MetadataFormats = []string{"csv", "xmp", "none"}
+)
+
+// Options choose what goes into the archive. Concurrency bounds the
+// variants fetched at once; MaxAssets, when positive, stops the archive
+// after that many assets.
+type Options struct {
+	Variant     string
+	Metadata    string
+	Concurrency int
+	MaxAssets   int
+	// TempDir holds fetched variants until they are written; empty means
+	// the system temporary directory.
+	TempDir string
+}
+
+// Validate fills in defaults and rejects unknown option values.
+func (o *Options) Validate() error {
+	if o.Variant == "" {
+		o.Variant = Variants[0]
+	}
+	if o.Metadata == "" {
+		o.Metadata = MetadataFormats[0]
+	}
+	if !contains(Variants, o.Variant) {
+		return fmt.Errorf("variant must be one of %s", strings.Join(Variants, ", "))
+	}
+	if !contains(MetadataFormats, o.Metadata) {
+		return fmt.Errorf("metadata must be one of %s", strings.Join(MetadataFormats, ", "))
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	return nil
+}
+
+// Source calls yield with each asset to include, in archive order, and
+// stops when yield returns an error.
+type Source func(yield func(ganache.Asset) error) error
+
+// Fetcher opens a variant URL.
+type Fetcher func(ctx context.Context, url string) (io.ReadCloser, error)
+
+// Failure is an asset whose variant could not be added.
+type Failure struct {
+	ID  string
+	Err string
+}
+
+// Report describes a finished archive. A non-empty report is also written
+// into the archive as download-errors.txt.
+type Report struct {
+	Added     int
+	Failed    []Failure
+	Truncated bool
+}
+
+var errLimit = errors.New("asset limit reached")
+
+// pending is an asset whose variant is being fetched. done is closed once
+// file or err is set.
+type pending struct {
+	asset ganache.Asset
+	file  *os.File
+	ext   string
+	err   error
+	done  chan struct{}
+}
+
+// Write streams the archive to w. An error means the archive is incomplete;
+// assets that fail on their own are listed in the report instead.
+func Write(ctx context.Context, w io.Writer, src Source, fetch Fetcher, opts Options) (Report, error) {
+	if err := opts.Validate(); err != nil {
+		return Report{}, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	window := make(chan *pending, opts.Concurrency)
+	sem := make(chan struct{}, opts.Concurrency)
+	srcErr := make(chan error, 1)
+	go func() {
+		defer close(window)
+		count := 0
+		srcErr <- src(func(a ganache.Asset) error {
+			if opts.MaxAssets > 0 && count == opts.MaxAssets {
+				return errLimit
+			}
+			count++
+			p := &pending{asset: a, done: make(chan struct{})}
+			select {
+			case window <- p:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			go func() {
+				defer close(p.done)
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					p.err = ctx.Err()
+					return
+				}
+				p.fetch(ctx, fetch, opts)
+			}()
+			return nil
+		})
+	}()
+
+	a := &archive{zip: zip.NewWriter(w), opts: opts, names: map[string]bool{}}
+	var writeErr error
+	if opts.Metadata == "csv" {
+		if writeErr = a.startSheet(); writeErr != nil {
+			cancel()
+		}
+	}
+	for p := range window {
+		<-p.done
+		if writeErr == nil {
+			writeErr = a.add(p)
+			if writeErr != nil {
+				cancel()
+			}
+		}
+		p.cleanup()
+	}
+	err := <-srcErr
+	if errors.Is(err, errLimit) {
+		a.report.Truncated = true
+		err = nil
+	}
+	if writeErr != nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = a.finish()
+	}
+	a.closeSheet()
+	return a.report, err
+}
+
+func (p *pending) fetch(ctx context.Context, fetch Fetcher, opts Options) {
+	u := variantURL(p.asset, opts.Variant)
+	if u == "" {
+		p.err = fmt.Errorf("no %s variant", opts.Variant)
+		return
+	}
+	body, err := fetch(ctx, u)
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer body.Close()
+	f, err := os.CreateTemp(opts.TempDir, "bundle-*")
+	if err != nil {
+		p.err = err
+		return
+	}
+	p.file = f
+	if _, err := io.Copy(f, body); err != nil {
+		p.err = err
+		return
+	}
+	_, p.err = f.Seek(0, io.SeekStart)
+	p.ext = path.Ext(urlPath(u))
+}
+
+func (p *pending) cleanup() {
+	if p.file != nil {
+		p.file.Close()
+		os.Remove(p.file.Name())
+	}
+}
+
+// archive holds the state of the ZIP being written.
+type archive struct {
+	zip    *zip.Writer
+	opts   Options
+	names  map[string]bool
+	report Report
+	sheet  *os.File
+	csv    *export.Exporter
+	// file is the archive name of the asset being added, for the File
+	// column of metadata.csv.
+	file string
+}
+
+// startSheet opens metadata.csv in a temporary file; it is added to the
+// archive after the assets.
+func (a *archive) startSheet() error {
+	f, err := os.CreateTemp(a.opts.TempDir, "bundle-*.csv")
+	if err != nil {
+		return err
+	}
+	a.sheet = f
+	file := export.Column{Key: "file", Header: "File", Value: func(ganache.Asset) string { return a.file }}
+	a.csv, err = export.New(export.NewCSV(f), append([]export.Column{file}, export.Columns...))
+	return err
+}
+
+func (a *archive) closeSheet() {
+	if a.sheet != nil {
+		a.sheet.Close()
+		os.Remove(a.sheet.Name())
+	}
+}
+
+func (a *archive) add(p *pending) error {
+	id := string(p.asset.ID)
+	a.file = ""
+	if p.err != nil {
+		a.report.Failed = append(a.report.Failed, Failure{ID: id, Err: p.err.Error()})
+	} else {
+		name := a.uniqueName(p)
+		a.file = name
+		modified := p.asset.CreatedAt
+		if modified.IsZero() {
+			modified = time.Now()
+		}
+		fw, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fw, p.file); err != nil {
+			return err
+		}
+		a.report.Added++
+		if a.opts.Metadata == "xmp" {
+			fw, err := a.zip.Create(strings.TrimSuffix(name, path.Ext(name)) + ".xmp")
+			if err != nil {
+				return err
+			}
+			if err := xmp.Write(fw, p.asset.AsUpdate()); err != nil {
+				return err
+			}
+		}
+	}
+	if a.csv != nil {
+		return a.csv.Asset(p.asset)
+	}
+	return nil
+}
+
+// finish adds metadata.csv and the report, then closes the archive.
+func (a *archive) finish() error {
+	if a.csv != nil {
+		if err := a.csv.Close(); err != nil {
+			return err
+		}
+		if _, err := a.sheet.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		fw, err := a.zip.Create("metadata.csv")
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fw, a.sheet); err != nil {
+			return err
+		}
+	}
+	if len(a.report.Failed) > 0 || a.report.Truncated {
+		fw, err := a.zip.Create("download-errors.txt")
+		if err != nil {
+			return err
+		}
+		if a.report.Truncated {
+			fmt.Fprintf(fw, "Stopped after %d assets; narrow the selection to download the rest.\n", a.opts.MaxAssets)
+		}
+		for _, f := range a.report.Failed {
+			fmt.Fprintf(fw, "%s: %s\n", f.ID, f.Err)
+		}
+	}
+	return a.zip.Close()
+}
+
+// uniqueName names an asset's file after its ID and the variant's file
+// name, falling back to an extension for the content type.
+func (a *archive) uniqueName(p *pending) string {
+	base := strings.TrimSuffix(path.Base(urlPath(variantURL(p.asset, a.opts.Variant))), p.ext)
+	base = sanitize(base)
+	ext := ""
+	if e := sanitize(strings.TrimPrefix(p.ext, ".")); e != "" {
+		ext = "." + e
+	} else {
+		ext = imageExts[guessType(p.file)]
+	}
+	stem := sanitize(string(p.asset.ID))
+	if base != "" && base != "_" && base != stem {
+		stem += "-" + base
+	}
+	name := stem + ext
+	for i := 2; a.names[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	a.names[name] = true
+	return name
+}
+
+// imageExts names files whose variant URL has no extension.
+var imageExts = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// guessType sniffs the content type of a fetched file and rewinds it.
+func guessType(f *os.File) string {
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+	f.Seek(0, io.SeekStart)
+	return http.DetectContentType(buf[:n])
+}
+
+func variantURL(a ganache.Asset, variant string) string {
+	switch variant {
+	case "content":
+		return a.Variants.Content
+	case "thumb":
+		return a.Variants.Thumb
+	}
+	return a.Variants.Original
+}
+
+func urlPath(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// sanitize keeps names portable: letters, digits, dot, dash and underscore.
+func sanitize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return strings.Trim(b.String(), ".")
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+type fakeFiles struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (f *fakeFiles) fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	f.active++
+	if f.active > f.maxSeen {
+		f.maxSeen = f.active
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+	if strings.Contains(url, "broken") {
+		return nil, errors.New("404 not found")
+	}
+	if strings.Contains(url, "/t/") {
+		return io.NopCloser(strings.NewReader("\xff\xd8\xff\xe0 jpeg")), nil
+	}
+	return io.NopCloser(strings.NewReader("data:" + url)), nil
+}
+
+func assets(n int) Source {
+	return func(yield func(ganache.Asset) error) error {
+		for i := 1; i <= n; i++ {
+			a := ganache.Asset{ID: ganache.StringID(fmt.Sprint(i)), Title: fmt.Sprintf("Asset %d", i)}
+			a.Variants.Original = fmt.Sprintf("https://cdn.example/o/%d/IMG %d.jpg", i, i)
+			a.Variants.Thumb = fmt.Sprintf("https://cdn.example/t/%d", i)
+			if i == 3 {
+				a.Variants.Original = "https://cdn.example/broken.jpg"
+			}
+			if err := yield(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func readZip(t *testing.T, data []byte) (names []string, files map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files = map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		names = append(names, f.Name)
+		files[f.Name] = string(body)
+	}
+	return names, files
+}
+
+func TestWriteOriginalsWithMetadataCSV(t *testing.T) {
+	var buf bytes.Buffer
+	files := &fakeFiles{}
+	report, err := Write(context.Background(), &buf, assets(12), files.fetch, Options{Concurrency: 3, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if report.Added != 11 || len(report.Failed) != 1 || report.Failed[0].ID != "3" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if files.maxSeen > 3 {
+		t.Fatalf("fetched %d variants at once, limit is 3", files.maxSeen)
+	}
+	names, contents := readZip(t, buf.Bytes())
+	if names[0] != "1-IMG_1.jpg" || names[1] != "2-IMG_2.jpg" || names[2] != "4-IMG_4.jpg" {
+		t.Fatalf("assets should keep their order: %v", names)
+	}
+	if contents["1-IMG_1.jpg"] != "data:https://cdn.example/o/1/IMG 1.jpg" {
+		t.Fatalf("unexpected content %q", contents["1-IMG_1.jpg"])
+	}
+	rows, err := csv.NewReader(strings.NewReader(contents["metadata.csv"])).ReadAll()
+	if err != nil || len(rows) != 13 || rows[0][0] != "File" || rows[1][0] != "1-IMG_1.jpg" || rows[3][0] != "" || rows[3][1] != "3" {
+		t.Fatalf("unexpected metadata.csv %v %v", rows, err)
+	}
+	if !strings.Contains(contents["download-errors.txt"], "3: 404 not found") {
+		t.Fatalf("missing error report: %q", contents["download-errors.txt"])
+	}
+}
+
+func TestWriteThumbsWithSidecarsAndLimit(t *testing.T) {
+	var buf bytes.Buffer
+	files := &fakeFiles{}
+	report, err := Write(context.Background(), &buf, assets(5), files.fetch, Options{
+		Variant: "thumb", Metadata: "xmp", Concurrency: 2, MaxAssets: 2, TempDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !report.Truncated || report.Added != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	names, contents := readZip(t, buf.Bytes())
+	want := []string{"1.jpg", "1.xmp", "2.jpg", "2.xmp", "download-errors.txt"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected entries %v", names)
+	}
+	if !strings.Contains(contents["1.xmp"], "Asset 1") {
+		t.Fatalf("sidecar missing title: %s", contents["1.xmp"])
+	}
+}
+
+func TestWriteStopsOnSourceError(t *testing.T) {
+	src := func(yield func(ganache.Asset) error) error {
+		yield(ganache.Asset{ID: "1"})
+		return errors.New("ganache unavailable")
+	}
+	files := &fakeFiles{}
+	if _, err := Write(context.Background(), io.Discard, src, files.fetch, Options{TempDir: t.TempDir()}); err == nil {
+		t.Fatalf("expected the source error")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := Options{}
+	if err := opts.Validate(); err != nil || opts.Variant != "original" || opts.Metadata != "csv" || opts.Concurrency != 1 {
+		t.Fatalf("unexpected defaults %+v %v", opts, err)
+	}
+	if err := (&Options{Variant: "raw"}).Validate(); err == nil {
+		t.Fatalf("expected error for unknown variant")
+	}
+}
@@ -0,0 +1,231 @@
+// Package collections stores named, ordered lists of assets such as the
+// gallery for an article. Collections are shared: every signed-in user can
+// see and edit them, and the creator is kept for reference.
+package collections
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+var ErrNotFound = errors.New("collection not found")
+
+type Collection struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Note      string    `json:"note,omitempty"`
+	Owner     string    `json:"owner"`
+	AssetIDs  []string  `json:"assetIds"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Contains reports whether the asset is in the collection.
+func (c Collection) Contains(assetID string) bool {
+	for _, id := range c.AssetIDs {
+		if id == assetID {
+			return true
+		}
+	}
+	return false
+}
+
+type Store struct {
+	path string
+
+	mu          sync.Mutex
+	collections map[string]*Collection
+}
+
+func NewStore(path string) (*Store, error) {
+	var list []*Collection
+	if err := filestore.ReadJSON(path, &list); err != nil {
+		return nil, err
+	}
+	st := &Store{path: path, collections: make(map[string]*Collection, len(list))}
+	for _, c := range list {
+		st.collections[c.ID] = c
+	}
+	return st, nil
+}
+
+func (st *Store) Create(c Collection) (Collection, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return Collection{}, errors.New("name is required")
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return Collection{}, err
+	}
+	c.ID = hex.EncodeToString(buf)
+	c.AssetIDs = appendNew(nil, c.AssetIDs)
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.collections[c.ID] = &c
+	if err := st.saveLocked(); err != nil {
+		delete(st.collections, c.ID)
+		return Collection{}, err
+	}
+	return c, nil
+}
+
+func (st *Store) Get(id string) (Collection, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	c, ok := st.collections[id]
+	if !ok {
+		return Collection{}, ErrNotFound
+	}
+	return clone(c), nil
+}
+
+// List returns every collection, most recently changed first.
+func (st *Store) List() []Collection {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]Collection, 0, len(st.collections))
+	for _, c := range st.collections {
+		list = append(list, clone(c))
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if !list[a].UpdatedAt.Equal(list[b].UpdatedAt) {
+			return list[a].UpdatedAt.After(list[b].UpdatedAt)
+		}
+		return list[a].ID < list[b].ID
+	})
+	return list
+}
+
+// Containing returns the collections the asset belongs to, by name.
+func (st *Store) Containing(assetID string) []Collection {
+	var out []Collection
+	for _, c := range st.List() {
+		if c.Contains(assetID) {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return strings.ToLower(out[a].Name) < strings.ToLower(out[b].Name) })
+	return out
+}
+
+// Update applies fn to the collection and persists the result. fn may
+// change the name, note and asset order; the asset list is de-duplicated.
+func (st *Store) Update(id string, fn func(*Collection)) (Collection, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	c, ok := st.collections[id]
+	if !ok {
+		return Collection{}, ErrNotFound
+	}
+	prev := clone(c)
+	fn(c)
+	c.ID, c.Owner, c.CreatedAt = prev.ID, prev.Owner, prev.CreatedAt
+	if c.Name = strings.TrimSpace(c.Name); c.Name == "" {
+		c.Name = prev.Name
+	}
+	c.Note = strings.TrimSpace(c.Note)
+	c.AssetIDs = appendNew(nil, c.AssetIDs)
+	c.UpdatedAt = time.Now().UTC()
+	if err := st.saveLocked(); err != nil {
+		*c = prev
+		return Collection{}, err
+	}
+	return clone(c), nil
+}
+
+// Add appends assets that are not already in the collection.
+func (st *Store) Add(id string, assetIDs ...string) (Collection, error) {
+	return st.Update(id, func(c *Collection) { c.AssetIDs = appendNew(c.AssetIDs, assetIDs) })
+}
+
+func (st *Store) Remove(id, assetID string) (Collection, error) {
+	return st.Update(id, func(c *Collection) {
+		kept := c.AssetIDs[:0]
+		for _, a := range c.AssetIDs {
+			if a != assetID {
+				kept = append(kept, a)
+			}
+		}
+		c.AssetIDs = kept
+	})
+}
+
+// Reorder puts the listed assets first, in the given order. Assets missing
+// from order keep their relative order after them, so a reorder based on a
+// stale page never drops assets another user added meanwhile; unknown IDs
+// are ignored.
+func (st *Store) Reorder(id string, order []string) (Collection, error) {
+	return st.Update(id, func(c *Collection) {
+		member := make(map[string]bool, len(c.AssetIDs))
+		for _, a := range c.AssetIDs {
+			member[a] = true
+		}
+		var next []string
+		for _, a := range order {
+			if member[a] {
+				next = append(next, a)
+			}
+		}
+		c.AssetIDs = appendNew(next, c.AssetIDs)
+	})
+}
+
+// Forget removes a deleted asset from every collection holding it.
+func (st *Store) Forget(assetID string) error {
+	for _, c := range st.Containing(assetID) {
+		if _, err := st.Remove(c.ID, assetID); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *Store) Delete(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.collections[id]; !ok {
+		return ErrNotFound
+	}
+	delete(st.collections, id)
+	return st.saveLocked()
+}
+
+func (st *Store) saveLocked() error {
+	list := make([]*Collection, 0, len(st.collections))
+	for _, c := range st.collections {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return filestore.WriteJSON(st.path, list)
+}
+
+// appendNew appends the non-empty IDs from add that are not yet in list.
+func appendNew(list, add []string) []string {
+	seen := make(map[string]bool, len(list)+len(add))
+	out := make([]string, 0, len(list)+len(add))
+	for _, id := range append(append([]string(nil), list...), add...) {
+		if id = strings.TrimSpace(id); id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+func clone(c *Collection) Collection {
+	out := *c
+	out.AssetIDs = append([]string(nil), c.AssetIDs...)
+	return out
+}
@@ -0,0 +1,74 @@
+package collections
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStoreAddReorderPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collections.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := store.Create(Collection{Owner: "alice", Name: " "}); err == nil {
+		t.Fatalf("expected name to be required")
+	}
+	c, err := store.Create(Collection{Owner: "alice", Name: "Derby gallery", AssetIDs: []string{"1", "2", "1"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !reflect.DeepEqual(c.AssetIDs, []string{"1", "2"}) {
+		t.Fatalf("duplicates kept: %q", c.AssetIDs)
+	}
+	if _, err := store.Add(c.ID, "3", "2", ""); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	// "4" is unknown and "1" was left out by a stale page: it stays, last.
+	c, err = store.Reorder(c.ID, []string{"3", "4", "2"})
+	if err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if !reflect.DeepEqual(c.AssetIDs, []string{"3", "2", "1"}) {
+		t.Fatalf("order = %q", c.AssetIDs)
+	}
+	if _, err := store.Update(c.ID, func(c *Collection) { c.Name = ""; c.Note = " for Saturday " }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	store.Create(Collection{Owner: "bob", Name: "Other", AssetIDs: []string{"9"}})
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, err := reloaded.Get(c.ID)
+	if err != nil || got.Name != "Derby gallery" || got.Note != "for Saturday" || got.Owner != "alice" {
+		t.Fatalf("unexpected collection %+v (%v)", got, err)
+	}
+	if in := reloaded.Containing("2"); len(in) != 1 || in[0].ID != c.ID {
+		t.Fatalf("containing = %+v", in)
+	}
+	if len(reloaded.List()) != 2 {
+		t.Fatalf("expected two collections")
+	}
+}
+
+func TestStoreRemoveAndDelete(t *testing.T) {
+	store, _ := NewStore(filepath.Join(t.TempDir(), "collections.json"))
+	c, _ := store.Create(Collection{Owner: "alice", Name: "g", AssetIDs: []string{"1", "2"}})
+	c, err := store.Remove(c.ID, "1")
+	if err != nil || !reflect.DeepEqual(c.AssetIDs, []string{"2"}) {
+		t.Fatalf("remove: %q %v", c.AssetIDs, err)
+	}
+	if err := store.Delete(c.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(c.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := store.Add(c.ID, "3"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -4,9 +4,15 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"ganache-admin-ui/internal/media"
+
 	"github.com/joho/godotenv"
 )
 
@@ -14,19 +20,146 @@ const defaultListenAddr = ":8080"
 const defaultUsersFile = "./users.yaml"
 const defaultTimeout = 10 * time.Second
 const secretLength = 32
+const defaultUploadMaxDimension = 12000
+const defaultUploadMaxPixels = 60_000_000
+const defaultScanTimeout = 30 * time.Second
+const defaultDataDir = "./data"
+const defaultTusMaxSize = 2 << 30
+
+// Resumable uploads exist for originals too large for a single request,
+// so they also accept video and TIFF scans by default.
+const tusExtraAllowedTypes = "image/tiff,video/*"
+
+const defaultTusExpiry = 24 * time.Hour
+const defaultJobConcurrency = 4
+const defaultJobMaxAttempts = 3
+const defaultSearchScanLimit = 2000
+const defaultIndexSyncInterval = 5 * time.Minute
+const defaultIndexFullSyncInterval = 6 * time.Hour
+const defaultIndexMaxAge = 30 * time.Minute
+const defaultTrashRetention = 30 * 24 * time.Hour
+const defaultTrashPurgeInterval = time.Hour
+const defaultDownloadConcurrency = 4
+const defaultDownloadMaxAssets = 500
 
+// DefaultBackend names the backend configured by GANACHE_BASE_URL when
+// GANACHE_BACKENDS is not set.
+const DefaultBackend = "default"
+
+// GanacheConfig is one Ganache instance. Name identifies it in URLs, in
+// users.yaml and on the command line; Label is shown in the header.
 type GanacheConfig struct {
+	Name    string
+	Label   string
 	BaseURL string
 	APIKey  string
 	Timeout time.Duration
 }
 
+type UploadConfig struct {
+	AllowedTypes []string
+	MaxWidth     int
+	MaxHeight    int
+	MaxPixels    int64
+}
+
+// ScanConfig configures malware scanning of uploads. Scanning is disabled
+// when ClamdAddr is empty. FailOpen accepts uploads when the scanner cannot
+// be reached; the default is to reject them.
+type ScanConfig struct {
+	ClamdAddr string
+	Timeout   time.Duration
+	FailOpen  bool
+}
+
+// TusConfig limits resumable uploads. Partial uploads are staged under
+// DataDir and removed once they have been idle for Expiry. AllowedTypes
+// replaces the upload allowlist for them; the image limits still apply.
+type TusConfig struct {
+	MaxSize      int64
+	Expiry       time.Duration
+	AllowedTypes []string
+}
+
+// JobsConfig bounds the background worker pool that runs queued uploads
+// and bulk edits against Ganache.
+type JobsConfig struct {
+	Concurrency int
+	MaxAttempts int
+}
+
+// SearchConfig bounds searches that filter results locally. ScanLimit is
+// the number of Ganache results inspected before giving up on a page.
+type SearchConfig struct {
+	ScanLimit int
+}
+
+// IndexConfig enables the local metadata index. It syncs new assets every
+// SyncInterval and re-reads the whole library every FullSyncInterval;
+// searches go to Ganache when the last sync is older than MaxAge.
+type IndexConfig struct {
+	Enabled          bool
+	SyncInterval     time.Duration
+	FullSyncInterval time.Duration
+	MaxAge           time.Duration
+}
+
+// TrashConfig controls soft delete. Deleted assets stay restorable for
+// Retention and are purged from Ganache by a check every PurgeInterval.
+type TrashConfig struct {
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// DownloadConfig bounds ZIP downloads: Concurrency variants are fetched
+// at once, and an archive holds at most MaxAssets assets.
+type DownloadConfig struct {
+	Concurrency int
+	MaxAssets   int
+}
+
+// MetricsConfig exposes Prometheus metrics at /metrics. With Addr they are
+// served on that address alone, otherwise on the UI's; with Token scrapes
+// must send it as a bearer token. Metrics are not served when both are
+// empty.
+type MetricsConfig struct {
+	Addr  string
+	Token string
+}
+
+// TracingConfig configures OpenTelemetry tracing. Exporter is "none" (the
+// default), "otlp" or "stdout"; the OTLP exporter posts spans to Endpoint,
+// the base URL of an OTLP/HTTP collector, with Headers. SampleRatio is the
+// share of new traces recorded.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	Headers     map[string]string
+	SampleRatio float64
+}
+
 type Config struct {
 	ListenAddr    string
 	UsersFile     string
+	DataDir       string
+	TagPolicyFile string
+	// LogLevel is the least severe level logged.
+	LogLevel      slog.Level
 	SessionSecret []byte
 	CSRFSecret    []byte
-	Ganache       GanacheConfig
+	// Backends lists the Ganache instances the UI can switch between. The
+	// first is the default.
+	Backends []GanacheConfig
+	Upload   UploadConfig
+	Scan     ScanConfig
+	Tus      TusConfig
+	Jobs     JobsConfig
+	Search   SearchConfig
+	Index    IndexConfig
+	Trash    TrashConfig
+	Download DownloadConfig
+	Metrics  MetricsConfig
+	Tracing  TracingConfig
 }
 
 func Load() (*Config, error) {
@@ -35,19 +168,77 @@ func Load() (*Config, error) {
 	listenAddr := valueOrDefault("UI_LISTEN_ADDR", defaultListenAddr)
 	usersFile := valueOrDefault("UI_USERS_FILE", defaultUsersFile)
 
-	ganacheBase := os.Getenv("GANACHE_BASE_URL")
-	if ganacheBase == "" {
-		return nil, errors.New("GANACHE_BASE_URL is required")
+	backends, err := loadBackends()
+	if err != nil {
+		return nil, err
 	}
-	ganacheKey := os.Getenv("GANACHE_API_KEY")
-	if ganacheKey == "" {
-		return nil, errors.New("GANACHE_API_KEY is required")
+
+	upload, err := loadUploadConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	timeoutStr := valueOrDefault("GANACHE_TIMEOUT", defaultTimeout.String())
-	timeout, err := time.ParseDuration(timeoutStr)
+	scanTimeout, err := time.ParseDuration(valueOrDefault("UI_CLAMD_TIMEOUT", defaultScanTimeout.String()))
 	if err != nil {
-		return nil, fmt.Errorf("invalid GANACHE_TIMEOUT: %w", err)
+		return nil, fmt.Errorf("invalid UI_CLAMD_TIMEOUT: %w", err)
+	}
+
+	tusMaxSize, err := intValue("UI_TUS_MAX_SIZE", defaultTusMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	tusExpiry, err := time.ParseDuration(valueOrDefault("UI_TUS_EXPIRY", defaultTusExpiry.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid UI_TUS_EXPIRY: %w", err)
+	}
+
+	jobConcurrency, err := intValue("UI_JOB_CONCURRENCY", defaultJobConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	jobMaxAttempts, err := intValue("UI_JOB_MAX_ATTEMPTS", defaultJobMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	searchScanLimit, err := intValue("UI_SEARCH_SCAN_LIMIT", defaultSearchScanLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadIndexConfig()
+	if err != nil {
+		return nil, err
+	}
+	trashRetention, err := positiveDuration("UI_TRASH_RETENTION", defaultTrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	trashPurgeInterval, err := positiveDuration("UI_TRASH_PURGE_INTERVAL", defaultTrashPurgeInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadConcurrency, err := intValue("UI_DOWNLOAD_CONCURRENCY", defaultDownloadConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if downloadConcurrency == 0 {
+		return nil, errors.New("invalid UI_DOWNLOAD_CONCURRENCY: must be positive")
+	}
+	downloadMaxAssets, err := intValue("UI_DOWNLOAD_MAX_ASSETS", defaultDownloadMaxAssets)
+	if err != nil {
+		return nil, err
+	}
+
+	tracing, err := loadTracingConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(valueOrDefault("UI_LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid UI_LOG_LEVEL: %q must be debug, info, warn or error", os.Getenv("UI_LOG_LEVEL"))
 	}
 
 	sessionSecret, err := readSecret("UI_SESSION_SECRET")
@@ -62,16 +253,253 @@ func Load() (*Config, error) {
 	return &Config{
 		ListenAddr:    listenAddr,
 		UsersFile:     usersFile,
+		DataDir:       valueOrDefault("UI_DATA_DIR", defaultDataDir),
+		TagPolicyFile: os.Getenv("UI_TAG_POLICY_FILE"),
+		LogLevel:      logLevel,
 		SessionSecret: sessionSecret,
 		CSRFSecret:    csrfSecret,
-		Ganache: GanacheConfig{
-			BaseURL: ganacheBase,
-			APIKey:  ganacheKey,
-			Timeout: timeout,
+		Backends:      backends,
+		Upload:        upload,
+		Scan: ScanConfig{
+			ClamdAddr: os.Getenv("UI_CLAMD_ADDR"),
+			Timeout:   scanTimeout,
+			FailOpen:  os.Getenv("UI_SCAN_FAIL_OPEN") == "true",
+		},
+		Tus: TusConfig{
+			MaxSize:      int64(tusMaxSize),
+			Expiry:       tusExpiry,
+			AllowedTypes: typeList("UI_TUS_ALLOWED_TYPES", defaultUploadAllowedTypes()+","+tusExtraAllowedTypes),
+		},
+		Jobs: JobsConfig{
+			Concurrency: jobConcurrency,
+			MaxAttempts: jobMaxAttempts,
+		},
+		Search: SearchConfig{
+			ScanLimit: searchScanLimit,
+		},
+		Index: index,
+		Trash: TrashConfig{
+			Retention:     trashRetention,
+			PurgeInterval: trashPurgeInterval,
 		},
+		Download: DownloadConfig{
+			Concurrency: downloadConcurrency,
+			MaxAssets:   downloadMaxAssets,
+		},
+		Metrics: MetricsConfig{
+			Addr:  os.Getenv("UI_METRICS_ADDR"),
+			Token: os.Getenv("UI_METRICS_TOKEN"),
+		},
+		Tracing: tracing,
 	}, nil
 }
 
+// loadBackends reads GANACHE_BACKENDS, a comma-separated list of backend
+// names, each configured with GANACHE_<NAME>_BASE_URL, _API_KEY and
+// optionally _TIMEOUT and _LABEL. Without it there is a single backend
+// named "default" configured by GANACHE_BASE_URL and GANACHE_API_KEY.
+func loadBackends() ([]GanacheConfig, error) {
+	timeout, err := time.ParseDuration(valueOrDefault("GANACHE_TIMEOUT", defaultTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GANACHE_TIMEOUT: %w", err)
+	}
+	names := os.Getenv("GANACHE_BACKENDS")
+	if strings.TrimSpace(names) == "" {
+		b := GanacheConfig{
+			Name:    DefaultBackend,
+			Label:   "Ganache",
+			BaseURL: os.Getenv("GANACHE_BASE_URL"),
+			APIKey:  os.Getenv("GANACHE_API_KEY"),
+			Timeout: timeout,
+		}
+		if b.BaseURL == "" {
+			return nil, errors.New("GANACHE_BASE_URL is required")
+		}
+		if b.APIKey == "" {
+			return nil, errors.New("GANACHE_API_KEY is required")
+		}
+		return []GanacheConfig{b}, nil
+	}
+
+	var backends []GanacheConfig
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !validBackendName(name) {
+			return nil, fmt.Errorf("invalid GANACHE_BACKENDS: %q must be lowercase letters, digits and dashes", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid GANACHE_BACKENDS: %q is listed twice", name)
+		}
+		seen[name] = true
+		prefix := "GANACHE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		b := GanacheConfig{
+			Name:    name,
+			Label:   valueOrDefault(prefix+"LABEL", name),
+			BaseURL: os.Getenv(prefix + "BASE_URL"),
+			APIKey:  os.Getenv(prefix + "API_KEY"),
+			Timeout: timeout,
+		}
+		if b.BaseURL == "" {
+			return nil, fmt.Errorf("%sBASE_URL is required", prefix)
+		}
+		if b.APIKey == "" {
+			return nil, fmt.Errorf("%sAPI_KEY is required", prefix)
+		}
+		if v := os.Getenv(prefix + "TIMEOUT"); v != "" {
+			if b.Timeout, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid %sTIMEOUT: %w", prefix, err)
+			}
+		}
+		backends = append(backends, b)
+	}
+	if len(backends) == 0 {
+		return nil, errors.New("invalid GANACHE_BACKENDS: no backend names")
+	}
+	return backends, nil
+}
+
+func validBackendName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Backend returns the named backend, or the default one when name is
+// empty.
+func (c *Config) Backend(name string) (GanacheConfig, error) {
+	if name == "" {
+		return c.Backends[0], nil
+	}
+	for _, b := range c.Backends {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return GanacheConfig{}, fmt.Errorf("unknown Ganache backend %q", name)
+}
+
+// BackendDataDir is where the UI keeps the data tied to a backend's
+// assets, such as the trash and revision history. The default backend uses
+// DataDir itself, so a single-backend setup keeps its data when more
+// backends are added.
+func (c *Config) BackendDataDir(name string) string {
+	if len(c.Backends) == 0 || name == c.Backends[0].Name {
+		return c.DataDir
+	}
+	return filepath.Join(c.DataDir, "backends", name)
+}
+
+func loadIndexConfig() (IndexConfig, error) {
+	cfg := IndexConfig{Enabled: os.Getenv("UI_INDEX_ENABLED") == "true"}
+	durations := []struct {
+		key string
+		def time.Duration
+		dst *time.Duration
+	}{
+		{"UI_INDEX_SYNC_INTERVAL", defaultIndexSyncInterval, &cfg.SyncInterval},
+		{"UI_INDEX_FULL_SYNC_INTERVAL", defaultIndexFullSyncInterval, &cfg.FullSyncInterval},
+		{"UI_INDEX_MAX_AGE", defaultIndexMaxAge, &cfg.MaxAge},
+	}
+	for _, d := range durations {
+		v, err := positiveDuration(d.key, d.def)
+		if err != nil {
+			return IndexConfig{}, err
+		}
+		*d.dst = v
+	}
+	return cfg, nil
+}
+
+func loadTracingConfig() (TracingConfig, error) {
+	cfg := TracingConfig{
+		Exporter: valueOrDefault("UI_TRACING_EXPORTER", "none"),
+		Endpoint: valueOrDefault("UI_TRACING_ENDPOINT", "http://localhost:4318"),
+		Headers:  map[string]string{},
+	}
+	switch cfg.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		return TracingConfig{}, fmt.Errorf("invalid UI_TRACING_EXPORTER: %q must be none, otlp or stdout", cfg.Exporter)
+	}
+	for _, pair := range strings.Split(os.Getenv("UI_TRACING_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return TracingConfig{}, fmt.Errorf("invalid UI_TRACING_HEADERS: %q is not key=value", pair)
+		}
+		cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	ratio, err := strconv.ParseFloat(valueOrDefault("UI_TRACING_SAMPLE_RATIO", "1"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return TracingConfig{}, fmt.Errorf("invalid UI_TRACING_SAMPLE_RATIO: %q must be between 0 and 1", os.Getenv("UI_TRACING_SAMPLE_RATIO"))
+	}
+	cfg.SampleRatio = ratio
+	return cfg, nil
+}
+
+func positiveDuration(key string, def time.Duration) (time.Duration, error) {
+	v, err := time.ParseDuration(valueOrDefault(key, def.String()))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return v, nil
+}
+
+func loadUploadConfig() (UploadConfig, error) {
+	types := typeList("UI_UPLOAD_ALLOWED_TYPES", defaultUploadAllowedTypes())
+	maxWidth, err := intValue("UI_UPLOAD_MAX_WIDTH", defaultUploadMaxDimension)
+	if err != nil {
+		return UploadConfig{}, err
+	}
+	maxHeight, err := intValue("UI_UPLOAD_MAX_HEIGHT", defaultUploadMaxDimension)
+	if err != nil {
+		return UploadConfig{}, err
+	}
+	maxPixels, err := intValue("UI_UPLOAD_MAX_PIXELS", defaultUploadMaxPixels)
+	if err != nil {
+		return UploadConfig{}, err
+	}
+	return UploadConfig{
+		AllowedTypes: types,
+		MaxWidth:     maxWidth,
+		MaxHeight:    maxHeight,
+		MaxPixels:    int64(maxPixels),
+	}, nil
+}
+
+// defaultUploadAllowedTypes is the allowlist media applies when none is
+// configured.
+func defaultUploadAllowedTypes() string {
+	return strings.Join(media.DefaultAllowedTypes, ",")
+}
+
+// typeList reads a comma-separated list of content types such as
+// "image/png,video/*".
+func typeList(key, def string) []string {
+	var types []string
+	for _, t := range strings.Split(valueOrDefault(key, def), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
 func valueOrDefault(key, def string) string {
 	val := os.Getenv(key)
 	if val == "" {
@@ -80,6 +508,18 @@ func valueOrDefault(key, def string) string {
 	return val
 }
 
+func intValue(key string, def int) (int, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, val)
+	}
+	return i, nil
+}
+
 func readSecret(key string) ([]byte, error) {
 	val := os.Getenv(key)
 	if val != "" {
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvWriter struct {
+	csv *csv.Writer
+	row []string
+}
+
+// NewCSV writes RFC 4180 CSV. Cells that a spreadsheet would read as a
+// formula are prefixed with a single quote, since captions come from
+// outside sources.
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{csv: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(row []string) error {
+	c.row = c.row[:0]
+	for _, cell := range row {
+		c.row = append(c.row, neutralize(cell))
+	}
+	return c.csv.Write(c.row)
+}
+
+func (c *csvWriter) Close() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+func neutralize(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	}
+	return cell
+}
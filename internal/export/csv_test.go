@@ -0,0 +1,19 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCSVNeutralizesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	w.Write([]string{"=HYPERLINK(\"x\")", "@SUM(A1)", "plain, with comma", ""})
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	want := `'=HYPERLINK(""x"")",'@SUM(A1),"plain, with comma",` + "\n"
+	if got := buf.String(); got != `"`+want {
+		t.Fatalf("unexpected csv %q", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Package export writes asset metadata as spreadsheets. Rows are written
+// as they arrive, so an export of a whole search never holds more than one
+// Ganache page in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// Column is one exportable asset field.
+type Column struct {
+	Key    string
+	Header string
+	Value  func(ganache.Asset) string
+}
+
+// Columns lists every exportable field in export order.
+var Columns = []Column{
+	{"id", "ID", func(a ganache.Asset) string { return string(a.ID) }},
+	{"title", "Title", func(a ganache.Asset) string { return a.Title }},
+	{"caption", "Caption", func(a ganache.Asset) string { return a.Caption }},
+	{"credit", "Credit", func(a ganache.Asset) string { return a.Credit }},
+	{"source", "Source", func(a ganache.Asset) string { return a.Source }},
+	{"usageNotes", "Usage notes", func(a ganache.Asset) string { return a.UsageNotes }},
+	{"tags", "Tags", func(a ganache.Asset) string { return strings.Join(a.Tags, ", ") }},
+	{"createdAt", "Created", func(a ganache.Asset) string {
+		if a.CreatedAt.IsZero() {
+			return ""
+		}
+		return a.CreatedAt.UTC().Format(time.RFC3339)
+	}},
+	{"thumb", "Thumbnail URL", func(a ganache.Asset) string { return a.Variants.Thumb }},
+	{"content", "Content URL", func(a ganache.Asset) string { return a.Variants.Content }},
+	{"original", "Original URL", func(a ganache.Asset) string { return a.Variants.Original }},
+}
+
+// Select returns the named columns in export order. No keys selects every
+// column; an unknown key is an error.
+func Select(keys []string) ([]Column, error) {
+	if len(keys) == 0 {
+		return Columns, nil
+	}
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[k] = true
+	}
+	var cols []Column
+	for _, c := range Columns {
+		if want[c.Key] {
+			cols = append(cols, c)
+			delete(want, c.Key)
+		}
+	}
+	for k := range want {
+		return nil, fmt.Errorf("unknown column %q", k)
+	}
+	return cols, nil
+}
+
+// Writer receives the header row followed by one row per asset. Close
+// flushes buffered output; nothing is complete until it returns.
+type Writer interface {
+	Write(row []string) error
+	Close() error
+}
+
+// Formats maps a format name to its content type and file extension.
+var Formats = map[string]struct{ ContentType, Ext string }{
+	"csv":  {"text/csv; charset=utf-8", ".csv"},
+	"xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"},
+}
+
+// NewWriter returns a writer for a name in Formats.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return NewCSV(w), nil
+	case "xlsx":
+		return NewXLSX(w)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Exporter writes assets as rows of the chosen columns.
+type Exporter struct {
+	cols []Column
+	w    Writer
+	row  []string
+}
+
+// New writes the header row and returns an exporter for the assets.
+func New(w Writer, cols []Column) (*Exporter, error) {
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Header
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Exporter{cols: cols, w: w, row: make([]string, len(cols))}, nil
+}
+
+func (e *Exporter) Asset(a ganache.Asset) error {
+	for i, c := range e.cols {
+		e.row[i] = c.Value(a)
+	}
+	return e.w.Write(e.row)
+}
+
+func (e *Exporter) Close() error { return e.w.Close() }
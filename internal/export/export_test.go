@@ -0,0 +1,68 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestSelect(t *testing.T) {
+	all, err := Select(nil)
+	if err != nil || len(all) != len(Columns) {
+		t.Fatalf("expected every column, got %d %v", len(all), err)
+	}
+	cols, err := Select([]string{"tags", "id"})
+	if err != nil || len(cols) != 2 || cols[0].Key != "id" || cols[1].Key != "tags" {
+		t.Fatalf("expected id, tags in export order, got %+v %v", cols, err)
+	}
+	if _, err := Select([]string{"id", "secret"}); err == nil {
+		t.Fatalf("expected error for unknown column")
+	}
+}
+
+func TestExporterWritesRows(t *testing.T) {
+	var buf bytes.Buffer
+	cols, _ := Select([]string{"id", "tags", "createdAt", "original"})
+	exp, err := New(NewCSV(&buf), cols)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	exp.Asset(ganache.Asset{
+		ID:        "7",
+		Tags:      []string{"cup", "final"},
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Variants:  ganache.Variants{Original: "https://cdn/7.jpg"},
+	})
+	exp.Asset(ganache.Asset{ID: "8"})
+	if err := exp.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := [][]string{
+		{"ID", "Tags", "Created", "Original URL"},
+		{"7", "cup, final", "2024-05-01T10:00:00Z", "https://cdn/7.jpg"},
+		{"8", "", "", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Fatalf("row %d: got %v want %v", i, rows[i], want[i])
+			}
+		}
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	w.Write([]string{"ID", "Caption"})
+	w.Write([]string{"1", "Fish & <chips>"})
+	w.Write([]string{"2", ""})
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("missing part %s", name)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Text string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatalf("sheet xml: %v", err)
+	}
+	if len(sheet.Rows) != 3 || sheet.Rows[1].Cells[1].Text != "Fish & <chips>" || len(sheet.Rows[2].Cells) != 2 {
+		t.Fatalf("unexpected sheet %+v", sheet)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "hé" {
+		t.Fatalf("got %q", got)
+	}
+	if got := truncate(strings.Repeat("a", 3), 5); got != "aaa" {
+		t.Fatalf("got %q", got)
+	}
+}
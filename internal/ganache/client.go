@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -18,16 +20,81 @@ type Client struct {
 	baseURL string
 	apiKey  string
 	http    *http.Client
+	// files fetches variants and sends new assets. It has no overall
+	// timeout, since large originals can take longer than an API call;
+	// callers bound it with their context.
+	files *http.Client
+	// observe, when set, is told about every call; see Observe.
+	observe Observer
 }
 
+// Observer is told about each call to Ganache once it returns: the client
+// method ("search", "get", "update", "delete", "download", "create" or
+// "tags"), how long it took and its error, if any. A download is timed up
+// to its response headers, as the caller reads the body.
+type Observer func(method string, elapsed time.Duration, err error)
+
 func NewClient(baseURL, apiKey string, timeout time.Duration) *Client {
 	return &Client{
 		baseURL: strings.TrimRight(baseURL, "/"),
 		apiKey:  apiKey,
-		http:    &http.Client{Timeout: timeout},
+		http:    &http.Client{Timeout: timeout, CheckRedirect: keepKeyOnHost},
+		files:   &http.Client{CheckRedirect: keepKeyOnHost},
+	}
+}
+
+// keepKeyOnHost stops the API key from following a redirect to another
+// host, such as a CDN or storage bucket serving a variant. net/http only
+// drops standard credentials like Authorization on its own.
+func keepKeyOnHost(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("X-Api-Key")
+	}
+	return nil
+}
+
+// Observe makes o watch every call made through the client. It must be
+// called before the client is used.
+func (c *Client) Observe(o Observer) {
+	c.observe = o
+}
+
+func (c *Client) observed(method string, start time.Time, err *error) {
+	if c.observe != nil {
+		c.observe(method, time.Since(start), *err)
 	}
 }
 
+// WrapTransport wraps the transport of every request the client makes,
+// for instrumentation. MethodOf tells the wrapper which client method made
+// a request. It must be called before the client is used.
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	for _, hc := range []*http.Client{c.http, c.files} {
+		rt := hc.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		hc.Transport = wrap(rt)
+	}
+}
+
+type methodKey struct{}
+
+// MethodOf returns the client method that made req, as named for an
+// Observer, or "" for a request made elsewhere.
+func MethodOf(req *http.Request) string {
+	method, _ := req.Context().Value(methodKey{}).(string)
+	return method
+}
+
+// send makes req with hc on behalf of the named client method.
+func (c *Client) send(hc *http.Client, method string, req *http.Request) (*http.Response, error) {
+	return hc.Do(req.WithContext(context.WithValue(req.Context(), methodKey{}, method)))
+}
+
 func (c *Client) SearchAssets(ctx context.Context, q string, tags []string, page, pageSize int, sort string) (SearchResponse, error) {
 	u, _ := url.Parse(c.baseURL)
 	u.Path = path.Join(u.Path, "/api/assets")
@@ -58,7 +125,7 @@ func (c *Client) SearchAssets(ctx context.Context, q string, tags []string, page
 	c.addAuth(req)
 
 	var respData SearchResponse
-	if err := c.doJSON(req, &respData); err != nil {
+	if err := c.doJSON("search", req, &respData); err != nil {
 		return SearchResponse{}, err
 	}
 	if len(respData.Assets) == 0 && len(respData.Items) > 0 {
@@ -78,7 +145,7 @@ func (c *Client) GetAsset(ctx context.Context, id string) (Asset, error) {
 	}
 	c.addAuth(req)
 	var asset Asset
-	if err := c.doJSON(req, &asset); err != nil {
+	if err := c.doJSON("get", req, &asset); err != nil {
 		return Asset{}, err
 	}
 	c.absolutizeVariants(&asset)
@@ -98,20 +165,21 @@ func (c *Client) UpdateAsset(ctx context.Context, id string, update AssetUpdate)
 	req.Header.Set("Content-Type", "application/json")
 	c.addAuth(req)
 	var asset Asset
-	if err := c.doJSON(req, &asset); err != nil {
+	if err := c.doJSON("update", req, &asset); err != nil {
 		return Asset{}, err
 	}
 	return asset, nil
 }
 
-func (c *Client) DeleteAsset(ctx context.Context, id string) error {
+func (c *Client) DeleteAsset(ctx context.Context, id string) (err error) {
+	defer c.observed("delete", time.Now(), &err)
 	u := fmt.Sprintf("%s/api/assets/%s", c.baseURL, url.PathEscape(id))
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
 	if err != nil {
 		return err
 	}
 	c.addAuth(req)
-	resp, err := c.http.Do(req)
+	resp, err := c.send(c.http, "delete", req)
 	if err != nil {
 		return err
 	}
@@ -122,44 +190,85 @@ func (c *Client) DeleteAsset(ctx context.Context, id string) error {
 	return nil
 }
 
-func (c *Client) CreateAssetMultipart(ctx context.Context, file io.Reader, filename string, fields map[string]string, tags []string) (Asset, error) {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-	fw, err := writer.CreateFormFile("file", filename)
+// Download opens a variant URL, as found in Asset.Variants. The API key is
+// only sent to URLs on the Ganache host, not to a CDN, including through a
+// redirect. The caller must close the returned body.
+func (c *Client) Download(ctx context.Context, rawURL string) (_ io.ReadCloser, err error) {
+	defer c.observed("download", time.Now(), &err)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return Asset{}, err
+		return nil, err
 	}
-	if _, err := io.Copy(fw, file); err != nil {
-		return Asset{}, err
+	if strings.HasPrefix(rawURL, c.baseURL+"/") {
+		c.addAuth(req)
 	}
-	for k, v := range fields {
-		if v == "" {
-			continue
-		}
-		_ = writer.WriteField(k, v)
+	resp, err := c.send(c.files, "download", req)
+	if err != nil {
+		return nil, err
 	}
-	for _, t := range tags {
-		if t == "" {
-			continue
-		}
-		_ = writer.WriteField("tags[]", t)
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
 	}
-	writer.Close()
+	return resp.Body, nil
+}
+
+// CreateAssetMultipart streams file to Ganache as a multipart upload. The
+// body is produced on the fly, so file is never buffered in memory. The
+// client's timeout does not apply, as a large file can take longer to send;
+// ctx bounds the upload instead.
+func (c *Client) CreateAssetMultipart(ctx context.Context, file io.Reader, filename string, fields map[string]string, tags []string) (Asset, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(writer, file, filename, fields, tags))
+	}()
 
 	u := fmt.Sprintf("%s/api/assets", c.baseURL)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, pr)
 	if err != nil {
+		pr.Close()
 		return Asset{}, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	c.addAuth(req)
 	var asset Asset
-	if err := c.doJSON(req, &asset); err != nil {
+	err = c.sendJSON(c.files, "create", req, &asset)
+	// Unblock the writer goroutine if Ganache answered before reading it all.
+	pr.Close()
+	if err != nil {
 		return Asset{}, err
 	}
 	return asset, nil
 }
 
+func writeMultipart(writer *multipart.Writer, file io.Reader, filename string, fields map[string]string, tags []string) error {
+	fw, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, file); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := writer.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	for _, t := range tags {
+		if t == "" {
+			continue
+		}
+		if err := writer.WriteField("tags[]", t); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
 func (c *Client) ListTags(ctx context.Context, prefix string, page, pageSize int) (TagResponse, error) {
 	u, _ := url.Parse(c.baseURL)
 	u.Path = path.Join(u.Path, "/api/tags")
@@ -181,14 +290,20 @@ func (c *Client) ListTags(ctx context.Context, prefix string, page, pageSize int
 	}
 	c.addAuth(req)
 	var respData TagResponse
-	if err := c.doJSON(req, &respData); err != nil {
+	if err := c.doJSON("tags", req, &respData); err != nil {
 		return TagResponse{}, err
 	}
 	return respData, nil
 }
 
-func (c *Client) doJSON(req *http.Request, target any) error {
-	resp, err := c.http.Do(req)
+func (c *Client) doJSON(method string, req *http.Request, target any) error {
+	return c.sendJSON(c.http, method, req, target)
+}
+
+// sendJSON makes req with hc and decodes the JSON response into target.
+func (c *Client) sendJSON(hc *http.Client, method string, req *http.Request, target any) (err error) {
+	defer c.observed(method, time.Now(), &err)
+	resp, err := c.send(hc, method, req)
 	if err != nil {
 		return err
 	}
@@ -221,19 +336,46 @@ func (c *Client) absolutizeVariants(a *Asset) {
 	}
 }
 
+// APIError is a non-2xx response from Ganache. Its message is the one
+// Ganache sent, so it can be shown to users as is.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+// IsNotFound reports whether err is a 404 from Ganache.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsUnreachable reports whether err happened while connecting to Ganache,
+// before any of the request was sent, so it is safe to try again.
+func IsUnreachable(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
 func parseError(resp *http.Response) error {
 	data, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{StatusCode: resp.StatusCode}
 	var er ErrorResponse
 	if err := json.Unmarshal(data, &er); err == nil {
 		if er.Error.Message != "" {
-			return fmt.Errorf("%s", er.Error.Message)
+			apiErr.Message = er.Error.Message
+			return apiErr
 		}
 		if er.Message != "" {
-			return fmt.Errorf("%s", er.Message)
+			apiErr.Message = er.Message
+			return apiErr
 		}
 	}
 	if len(data) > 0 {
-		return fmt.Errorf("%s", strings.TrimSpace(string(data)))
+		apiErr.Message = strings.TrimSpace(string(data))
+		return apiErr
 	}
-	return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	apiErr.Message = fmt.Sprintf("unexpected status: %d", resp.StatusCode)
+	return apiErr
 }
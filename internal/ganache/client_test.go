@@ -3,6 +3,7 @@ package ganache
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -116,6 +117,43 @@ func TestCreateAssetMultipart(t *testing.T) {
 	}
 }
 
+// slowReader yields its data a byte at a time, pausing before each read.
+type slowReader struct {
+	data  []byte
+	pause time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.pause)
+	p[0], r.data = r.data[0], r.data[1:]
+	return 1, nil
+}
+
+func TestCreateAssetMultipartOutlastsTimeout(t *testing.T) {
+	var size int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f, _, err := r.FormFile("file"); err == nil {
+			data, _ := io.ReadAll(f)
+			size = len(data)
+		}
+		io.WriteString(w, `{"id":"57"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "key", 50*time.Millisecond)
+	body := &slowReader{data: []byte("slow"), pause: 40 * time.Millisecond}
+	asset, err := client.CreateAssetMultipart(context.Background(), body, "slow.mp4", nil, nil)
+	if err != nil {
+		t.Fatalf("a slow upload should not hit the API timeout: %v", err)
+	}
+	if asset.ID != "57" || size != 4 {
+		t.Fatalf("unexpected result %+v with %d bytes", asset, size)
+	}
+}
+
 func TestParseErrorUsesMessage(t *testing.T) {
 	rec := httptest.NewRecorder()
 	rec.WriteHeader(http.StatusBadRequest)
@@ -124,4 +162,157 @@ func TestParseErrorUsesMessage(t *testing.T) {
 	if err == nil || err.Error() != "nope" {
 		t.Fatalf("expected error message")
 	}
+	if IsNotFound(err) {
+		t.Fatalf("400 reported as not found")
+	}
+	rec = httptest.NewRecorder()
+	rec.WriteHeader(http.StatusNotFound)
+	if err := parseError(rec.Result()); !IsNotFound(err) || err.Error() != "unexpected status: 404" {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestCreateAssetMultipartStreamsBody(t *testing.T) {
+	var contentLength int64
+	var size int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		r.ParseMultipartForm(1 << 20)
+		if f, _, err := r.FormFile("file"); err == nil {
+			data, _ := io.ReadAll(f)
+			size = len(data)
+		}
+		io.WriteString(w, `{"id":"56"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, "key", time.Second)
+	payload := strings.Repeat("x", 3<<20)
+	if _, err := client.CreateAssetMultipart(context.Background(), strings.NewReader(payload), "big.bin", nil, nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if contentLength != -1 {
+		t.Fatalf("expected chunked body, got content length %d", contentLength)
+	}
+	if size != len(payload) {
+		t.Fatalf("expected %d bytes, got %d", len(payload), size)
+	}
+}
+
+func TestDownloadSendsKeyOnlyToGanache(t *testing.T) {
+	var keys []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Api-Key"))
+		if r.URL.Path == "/missing.jpg" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "image")
+	})
+	ganache := httptest.NewServer(handler)
+	t.Cleanup(ganache.Close)
+	cdn := httptest.NewServer(handler)
+	t.Cleanup(cdn.Close)
+
+	client := NewClient(ganache.URL, "key", time.Second)
+	for _, u := range []string{ganache.URL + "/files/1.jpg", cdn.URL + "/1.jpg"} {
+		body, err := client.Download(context.Background(), u)
+		if err != nil {
+			t.Fatalf("download %s: %v", u, err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != "image" {
+			t.Fatalf("unexpected body %q", data)
+		}
+	}
+	if len(keys) != 2 || keys[0] != "key" || keys[1] != "" {
+		t.Fatalf("unexpected keys %q", keys)
+	}
+	if _, err := client.Download(context.Background(), ganache.URL+"/missing.jpg"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestDownloadDropsKeyOnRedirectToOtherHost(t *testing.T) {
+	var cdnKey string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnKey = r.Header.Get("X-Api-Key")
+		io.WriteString(w, "image")
+	}))
+	t.Cleanup(cdn.Close)
+	ganache := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" {
+			t.Errorf("expected the key on the Ganache host")
+		}
+		http.Redirect(w, r, cdn.URL+"/signed/1.jpg", http.StatusFound)
+	}))
+	t.Cleanup(ganache.Close)
+
+	client := NewClient(ganache.URL, "key", time.Second)
+	body, err := client.Download(context.Background(), ganache.URL+"/files/1.jpg")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "image" {
+		t.Fatalf("expected the redirect to be followed, got %q", data)
+	}
+	if cdnKey != "" {
+		t.Fatalf("API key leaked to the redirect target: %q", cdnKey)
+	}
+}
+
+func TestObserverSeesEachCall(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, `{"id":"1"}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	var calls []string
+	client := NewClient(ts.URL, "key", time.Second)
+	client.Observe(func(method string, elapsed time.Duration, err error) {
+		calls = append(calls, fmt.Sprintf("%s:%v", method, err != nil))
+	})
+	client.GetAsset(context.Background(), "1")
+	client.UpdateAsset(context.Background(), "1", AssetUpdate{})
+	client.DeleteAsset(context.Background(), "1")
+	if strings.Join(calls, " ") != "get:false update:false delete:true" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+}
+
+func TestWrapTransportTellsMethod(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"assets":[],"tags":[]}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	var methods []string
+	client := NewClient(ts.URL, "key", time.Second)
+	client.WrapTransport(func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			methods = append(methods, MethodOf(r))
+			return rt.RoundTrip(r)
+		})
+	})
+	client.SearchAssets(context.Background(), "", nil, 1, 10, "")
+	client.ListTags(context.Background(), "", 1, 10)
+	body, err := client.Download(context.Background(), ts.URL+"/files/1.jpg")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body.Close()
+	if strings.Join(methods, " ") != "search tags download" {
+		t.Fatalf("unexpected methods %v", methods)
+	}
 }
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
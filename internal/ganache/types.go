@@ -49,6 +49,19 @@ type AssetUpdate struct {
 	Tags       []string `json:"tags"`
 }
 
+// AsUpdate returns an update that rewrites the asset's current metadata,
+// for callers that change a single field. UpdateAsset replaces every field.
+func (a Asset) AsUpdate() AssetUpdate {
+	return AssetUpdate{
+		Title:      a.Title,
+		Caption:    a.Caption,
+		Credit:     a.Credit,
+		Source:     a.Source,
+		UsageNotes: a.UsageNotes,
+		Tags:       append([]string(nil), a.Tags...),
+	}
+}
+
 type SearchResponse struct {
 	Assets   []Asset `json:"assets"`
 	Items    []Asset `json:"items"`
@@ -67,7 +80,8 @@ type ErrorBody struct {
 }
 
 type Tag struct {
-	Name string `json:"name"`
+	Name  string `json:"name"`
+	Count int    `json:"count,omitempty"`
 }
 
 type TagResponse struct {
@@ -0,0 +1,96 @@
+package hotfolder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultsFile names the per-folder defaults.
+const DefaultsFile = ".ganache.yaml"
+
+// Defaults are the metadata in a folder's .ganache.yaml. They apply to the
+// files in the folder and its subfolders. A subfolder's defaults override
+// its parent's field by field, and tags add up.
+type Defaults struct {
+	Caption    string   `yaml:"caption"`
+	Credit     string   `yaml:"credit"`
+	Source     string   `yaml:"source"`
+	UsageNotes string   `yaml:"usageNotes"`
+	Tags       []string `yaml:"tags"`
+}
+
+// loadDefaults merges the defaults files from root down to dir, which is
+// relative to root.
+func loadDefaults(root, dir string) (ganache.AssetUpdate, error) {
+	var m ganache.AssetUpdate
+	levels := []string{"."}
+	if dir != "." {
+		parts := strings.Split(filepath.ToSlash(dir), "/")
+		for i := range parts {
+			levels = append(levels, filepath.Join(parts[:i+1]...))
+		}
+	}
+	for _, level := range levels {
+		path := filepath.Join(root, level, DefaultsFile)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return m, err
+		}
+		var d Defaults
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&d); err != nil && !errors.Is(err, io.EOF) {
+			return m, fmt.Errorf("%s: %w", filepath.Join(level, DefaultsFile), err)
+		}
+		overlay(&m, ganache.AssetUpdate{
+			Caption: d.Caption, Credit: d.Credit, Source: d.Source,
+			UsageNotes: d.UsageNotes, Tags: d.Tags,
+		})
+	}
+	return m, nil
+}
+
+// overlay copies the non-empty fields of top over m and adds its tags.
+func overlay(m *ganache.AssetUpdate, top ganache.AssetUpdate) {
+	for _, f := range []struct {
+		dst *string
+		v   string
+	}{
+		{&m.Title, top.Title},
+		{&m.Caption, top.Caption},
+		{&m.Credit, top.Credit},
+		{&m.Source, top.Source},
+		{&m.UsageNotes, top.UsageNotes},
+	} {
+		if v := strings.TrimSpace(f.v); v != "" {
+			*f.dst = v
+		}
+	}
+	for _, t := range top.Tags {
+		t = strings.TrimSpace(t)
+		if t != "" && !contains(m.Tags, t) {
+			m.Tags = append(m.Tags, t)
+		}
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+// Package hotfolder uploads the files dropped into a directory, such as a
+// scanner's output folder. A file is picked up once its size and
+// modification time have not changed for a while. Uploaded files move to
+// done/ and rejected ones to failed/, next to a .error.txt report.
+//
+// Every attempt is recorded in a journal in the directory before it is
+// made, keyed by the file's content hash. If the process dies mid-upload,
+// the file is moved to failed/ on the next run instead of being uploaded
+// again, since Ganache may already have it.
+package hotfolder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/iptc"
+)
+
+// Folder and file names the watcher manages inside the directory.
+const (
+	DoneDir     = "done"
+	FailedDir   = "failed"
+	JournalFile = ".ganache-journal.jsonl"
+)
+
+// Uploader sends one file to Ganache. It is expected to validate the file
+// and its metadata first; any error other than failing to connect moves
+// the file to failed/.
+type Uploader func(ctx context.Context, f *os.File, filename string, meta ganache.AssetUpdate) (ganache.Asset, error)
+
+// Options tune the watcher. StableFor is how long a file must stay
+// unchanged before it is uploaded; Logf, when set, receives one line per
+// file handled.
+type Options struct {
+	StableFor time.Duration
+	Logf      func(format string, args ...any)
+}
+
+// Result counts the files a scan handled.
+type Result struct {
+	Uploaded int
+	Failed   int
+}
+
+// Watcher holds the state of one watched directory.
+type Watcher struct {
+	dir     string
+	upload  Uploader
+	opts    Options
+	journal *journal
+	seen    map[string]observation
+}
+
+// observation is a file's size and modification time when last seen, and
+// when they last changed.
+type observation struct {
+	size  int64
+	mod   time.Time
+	since time.Time
+}
+
+// New opens the directory's journal. Only one watcher may run per
+// directory.
+func New(dir string, upload Uploader, opts Options) (*Watcher, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	j, err := openJournal(filepath.Join(dir, JournalFile))
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+	return &Watcher{dir: dir, upload: upload, opts: opts, journal: j, seen: map[string]observation{}}, nil
+}
+
+// Close closes the journal.
+func (w *Watcher) Close() error {
+	return w.journal.close()
+}
+
+// Run scans the directory every interval until ctx is cancelled. It only
+// returns early if the directory itself cannot be read.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Scan(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan makes one pass over the directory and uploads the files that have
+// become stable. A file is never uploaded on the pass that first sees it.
+// A subdirectory or file that cannot be handled is logged and left for the
+// next pass; Scan only fails if the directory itself cannot be read or ctx
+// is cancelled.
+func (w *Watcher) Scan(ctx context.Context) (Result, error) {
+	var res Result
+	now := time.Now()
+	present := map[string]bool{}
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(w.dir, path)
+		if err != nil {
+			if rel == "." {
+				return err
+			}
+			w.opts.Logf("%s: %v", rel, err)
+			return nil
+		}
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || (d.IsDir() && (rel == DoneDir || rel == FailedDir)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		present[rel] = true
+		prev, ok := w.seen[rel]
+		if !ok || prev.size != info.Size() || !prev.mod.Equal(info.ModTime()) {
+			w.seen[rel] = observation{size: info.Size(), mod: info.ModTime(), since: now}
+			return nil
+		}
+		if now.Sub(prev.since) < w.opts.StableFor {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out, err := w.process(ctx, rel)
+		switch out {
+		case uploaded:
+			res.Uploaded++
+		case failed:
+			res.Failed++
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.opts.Logf("%s: %v", rel, err)
+		}
+		return nil
+	})
+	for rel := range w.seen {
+		if !present[rel] || !w.exists(rel) {
+			delete(w.seen, rel)
+		}
+	}
+	return res, err
+}
+
+func (w *Watcher) exists(rel string) bool {
+	_, err := os.Stat(filepath.Join(w.dir, rel))
+	return err == nil
+}
+
+// outcome is what became of a file a scan handled.
+type outcome int
+
+const (
+	skipped outcome = iota
+	uploaded
+	failed
+)
+
+// process uploads one stable file. An error leaves the file where it is,
+// to be looked at again on the next pass.
+func (w *Watcher) process(ctx context.Context, rel string) (outcome, error) {
+	f, err := os.Open(filepath.Join(w.dir, rel))
+	if err != nil {
+		return skipped, nil
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return failed, w.fail(f, rel, "", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	if rec, ok := w.journal.lookup(hash); ok {
+		switch rec.State {
+		case stateUploaded:
+			w.opts.Logf("%s: already uploaded as asset %s", rel, rec.AssetID)
+			f.Close()
+			_, err := w.move(DoneDir, rel)
+			return skipped, err
+		case statePending:
+			return failed, w.fail(f, rel, hash, errors.New("a previous upload of this file was interrupted; check Ganache for it before dropping it again"))
+		}
+	}
+
+	meta, err := w.metadata(f, rel)
+	if err != nil {
+		return failed, w.fail(f, rel, hash, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return failed, w.fail(f, rel, hash, err)
+	}
+	if err := w.journal.append(record{Hash: hash, File: rel, State: statePending}); err != nil {
+		return skipped, err
+	}
+	asset, err := w.upload(ctx, f, filepath.Base(rel), meta)
+	if err != nil {
+		if ctx.Err() != nil {
+			return skipped, ctx.Err()
+		}
+		if ganache.IsUnreachable(err) {
+			w.opts.Logf("%s: %v; will retry", rel, err)
+			return skipped, w.journal.append(record{Hash: hash, File: rel, State: stateFailed, Error: err.Error()})
+		}
+		return failed, w.fail(f, rel, hash, err)
+	}
+	if err := w.journal.append(record{Hash: hash, File: rel, State: stateUploaded, AssetID: string(asset.ID)}); err != nil {
+		return skipped, err
+	}
+	f.Close()
+	if _, err := w.move(DoneDir, rel); err != nil {
+		return skipped, err
+	}
+	w.opts.Logf("%s: uploaded as asset %s", rel, asset.ID)
+	return uploaded, nil
+}
+
+// metadata merges the folder defaults with the file's IPTC fields, which
+// take precedence. The title falls back to the file name.
+func (w *Watcher) metadata(f *os.File, rel string) (ganache.AssetUpdate, error) {
+	meta, err := loadDefaults(w.dir, filepath.Dir(rel))
+	if err != nil {
+		return meta, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return meta, err
+	}
+	embedded, err := iptc.Read(f)
+	if err != nil {
+		return meta, fmt.Errorf("read IPTC: %w", err)
+	}
+	overlay(&meta, embedded)
+	if meta.Title == "" {
+		base := filepath.Base(rel)
+		meta.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return meta, nil
+}
+
+// fail moves the file to failed/ and writes the error report next to it.
+// Recording the failure lets the file be retried if it is dropped again.
+func (w *Watcher) fail(f *os.File, rel, hash string, cause error) error {
+	f.Close()
+	if hash != "" {
+		if err := w.journal.append(record{Hash: hash, File: rel, State: stateFailed, Error: cause.Error()}); err != nil {
+			return err
+		}
+	}
+	dst, err := w.move(FailedDir, rel)
+	if err != nil {
+		return err
+	}
+	report := fmt.Sprintf("file: %s\ntime: %s\nerror: %s\n", rel, time.Now().UTC().Format(time.RFC3339), cause)
+	if err := os.WriteFile(dst+".error.txt", []byte(report), 0o644); err != nil {
+		return err
+	}
+	w.opts.Logf("%s: failed: %v", rel, cause)
+	return nil
+}
+
+// move renames the file into sub, keeping its folder and adding a number
+// when the name is taken. It returns the new path.
+func (w *Watcher) move(sub, rel string) (string, error) {
+	dst := filepath.Join(w.dir, sub, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(dst)
+	stem := strings.TrimSuffix(dst, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dst); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		dst = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	return dst, os.Rename(filepath.Join(w.dir, rel), dst)
+}
@@ -0,0 +1,225 @@
+package hotfolder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+type fakeUploads struct {
+	calls []string
+	metas map[string]ganache.AssetUpdate
+	err   error
+}
+
+func (u *fakeUploads) upload(_ context.Context, f *os.File, filename string, meta ganache.AssetUpdate) (ganache.Asset, error) {
+	u.calls = append(u.calls, filename)
+	if u.metas == nil {
+		u.metas = map[string]ganache.AssetUpdate{}
+	}
+	u.metas[filename] = meta
+	if u.err != nil {
+		return ganache.Asset{}, u.err
+	}
+	return ganache.Asset{ID: ganache.StringID("id-" + filename)}, nil
+}
+
+func write(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newWatcher(t *testing.T, dir string, u *fakeUploads) *Watcher {
+	t.Helper()
+	w, err := New(dir, u.upload, Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func scan(t *testing.T, w *Watcher) Result {
+	t.Helper()
+	res, err := w.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return res
+}
+
+func TestScanUploadsStableFilesWithDefaults(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, DefaultsFile, "credit: Desk\ntags: [scan]\n")
+	write(t, dir, "match/"+DefaultsFile, "source: Scanner 2\ntags: [cup]\n")
+	write(t, dir, "match/kickoff.jpg", "image")
+	u := &fakeUploads{}
+	w := newWatcher(t, dir, u)
+
+	if res := scan(t, w); res.Uploaded != 0 || len(u.calls) != 0 {
+		t.Fatalf("a file should not be uploaded the first time it is seen: %+v", res)
+	}
+	if res := scan(t, w); res.Uploaded != 1 {
+		t.Fatalf("expected one upload, got %+v", res)
+	}
+	meta := u.metas["kickoff.jpg"]
+	if meta.Title != "kickoff" || meta.Credit != "Desk" || meta.Source != "Scanner 2" || strings.Join(meta.Tags, ",") != "scan,cup" {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DoneDir, "match", "kickoff.jpg")); err != nil {
+		t.Fatalf("file should move to done/: %v", err)
+	}
+	if res := scan(t, w); res.Uploaded != 0 || len(u.calls) != 1 {
+		t.Fatalf("done/ should not be rescanned: %+v", res)
+	}
+}
+
+func TestScanWaitsForChangingFiles(t *testing.T) {
+	dir := t.TempDir()
+	u := &fakeUploads{}
+	w := newWatcher(t, dir, u)
+	write(t, dir, "a.jpg", "part")
+	scan(t, w)
+	write(t, dir, "a.jpg", "partial upload")
+	scan(t, w)
+	if len(u.calls) != 0 {
+		t.Fatal("a growing file should not be uploaded")
+	}
+	scan(t, w)
+	if len(u.calls) != 1 {
+		t.Fatal("expected the file once it stopped changing")
+	}
+}
+
+func TestScanMovesFailuresWithReport(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "bad.jpg", "not an image")
+	u := &fakeUploads{err: errors.New("file type not allowed")}
+	w := newWatcher(t, dir, u)
+	scan(t, w)
+	if res := scan(t, w); res.Failed != 1 {
+		t.Fatalf("expected one failure, got %+v", res)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, FailedDir, "bad.jpg.error.txt"))
+	if err != nil || !strings.Contains(string(report), "file type not allowed") {
+		t.Fatalf("expected an error report, got %q, %v", report, err)
+	}
+
+	// Dropping the file again after a failure retries it.
+	write(t, dir, "bad.jpg", "not an image")
+	u.err = nil
+	scan(t, w)
+	if res := scan(t, w); res.Uploaded != 1 {
+		t.Fatalf("expected a retry after a failure, got %+v", res)
+	}
+}
+
+func TestScanRetriesWhenGanacheIsUnreachable(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "a.jpg", "image")
+	u := &fakeUploads{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	w := newWatcher(t, dir, u)
+	scan(t, w)
+	if res := scan(t, w); res.Failed != 0 {
+		t.Fatalf("an unreachable Ganache should not fail the file: %+v", res)
+	}
+	u.err = nil
+	if res := scan(t, w); res.Uploaded != 1 {
+		t.Fatalf("expected the retry to upload, got %+v", res)
+	}
+}
+
+func TestScanNeverUploadsTwiceAfterACrash(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "interrupted.jpg", "one")
+	write(t, dir, "uploaded.jpg", "two")
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var journal strings.Builder
+	for _, rec := range []record{
+		{Hash: hash("one"), File: "interrupted.jpg", State: statePending},
+		{Hash: hash("two"), File: "uploaded.jpg", State: statePending},
+		{Hash: hash("two"), File: "uploaded.jpg", State: stateUploaded, AssetID: "42"},
+	} {
+		line, _ := json.Marshal(rec)
+		journal.Write(line)
+		journal.WriteByte('\n')
+	}
+	journal.WriteString(`{"hash":"torn`)
+	write(t, dir, JournalFile, journal.String())
+
+	u := &fakeUploads{}
+	w := newWatcher(t, dir, u)
+	scan(t, w)
+	scan(t, w)
+	if len(u.calls) != 0 {
+		t.Fatalf("nothing should be uploaded again, got %v", u.calls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DoneDir, "uploaded.jpg")); err != nil {
+		t.Fatalf("uploaded file should move to done/: %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, FailedDir, "interrupted.jpg.error.txt"))
+	if err != nil || !strings.Contains(string(report), "interrupted") {
+		t.Fatalf("interrupted file should move to failed/: %q, %v", report, err)
+	}
+}
+
+func TestScanRejectsBadDefaults(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, DefaultsFile, "credits: typo\n")
+	write(t, dir, "a.jpg", "image")
+	u := &fakeUploads{}
+	w := newWatcher(t, dir, u)
+	scan(t, w)
+	if res := scan(t, w); res.Failed != 1 || len(u.calls) != 0 {
+		t.Fatalf("unknown defaults keys should fail the file: %+v", res)
+	}
+}
+
+func TestScanCarriesOnPastAFileItCannotMove(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "match/kickoff.jpg", "one")
+	write(t, dir, "zz.jpg", "two")
+	// A file where done/match should be makes the move after upload fail.
+	write(t, dir, DoneDir+"/match", "")
+	var logs []string
+	u := &fakeUploads{}
+	w, err := New(dir, u.upload, Options{Logf: func(format string, args ...any) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	scan(t, w)
+	if res := scan(t, w); res.Uploaded != 1 || len(u.calls) != 2 {
+		t.Fatalf("expected the scan to go on to the next file: %+v, calls %v", res, u.calls)
+	}
+	if !strings.Contains(strings.Join(logs, "\n"), "match/kickoff.jpg: ") {
+		t.Fatalf("expected the failed move to be logged: %q", logs)
+	}
+
+	os.RemoveAll(dir)
+	if _, err := w.Scan(context.Background()); err == nil {
+		t.Fatal("expected an error once the directory is gone")
+	}
+}
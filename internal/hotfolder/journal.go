@@ -0,0 +1,109 @@
+package hotfolder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+// States a file moves through. A file is keyed by its content hash, so a
+// renamed or re-dropped copy is recognised too.
+const (
+	statePending  = "pending"
+	stateUploaded = "uploaded"
+	stateFailed   = "failed"
+)
+
+// record is one journal line.
+type record struct {
+	Time    time.Time `json:"time"`
+	Hash    string    `json:"hash"`
+	File    string    `json:"file"`
+	State   string    `json:"state"`
+	AssetID string    `json:"assetId,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// journal is an append-only log of upload attempts. Each record is synced
+// before the step it describes, so after a crash the last record for a
+// file says whether an upload may already have reached Ganache.
+type journal struct {
+	mu     sync.Mutex
+	f      *os.File
+	latest map[string]record
+}
+
+// openJournal replays path and compacts it to the latest record per file.
+// A torn last line, left by a crash mid-write, is dropped.
+func openJournal(path string) (*journal, error) {
+	j := &journal{latest: map[string]record{}}
+	if f, err := os.Open(path); err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			var rec record
+			if json.Unmarshal(sc.Bytes(), &rec) == nil && rec.Hash != "" {
+				j.latest[rec.Hash] = rec
+			}
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range j.latest {
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	if err := filestore.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	j.f = f
+	return j, nil
+}
+
+func (j *journal) lookup(hash string) (record, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec, ok := j.latest[hash]
+	return rec, ok
+}
+
+// append writes rec and syncs it to disk before returning.
+func (j *journal) append(rec record) error {
+	rec.Time = time.Now().UTC()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.latest[rec.Hash] = rec
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.f.Close()
+}
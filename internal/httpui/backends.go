@@ -0,0 +1,135 @@
+package httpui
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"ganache-admin-ui/internal/auth"
+)
+
+// Each configured Ganache backend is served by its own Server, with its
+// own client and its own trash, history, collections, jobs and index, since
+// asset IDs only mean something within one backend. With several backends,
+// a backend's pages live under /b/<name>/ so links never cross backends;
+// the unprefixed paths serve the backend the session last switched to.
+
+type backendContextKey struct{}
+
+// BackendOption is an entry in the header's backend switcher.
+type BackendOption struct {
+	Name  string
+	Label string
+}
+
+// path prefixes a backend-relative path with the backend's base.
+func (s *Server) path(p string) string {
+	return s.base + p
+}
+
+// lookup returns the server for the named backend.
+func (s *Server) lookup(name string) *Server {
+	for _, b := range s.backends {
+		if b.backend.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// allows reports whether user may use this backend at all.
+func (s *Server) allows(user string) bool {
+	return s.users.RoleOn(user, s.backend.Name) != auth.RoleNone
+}
+
+// active returns the backend the session switched to, or the first one the
+// user may use.
+func (s *Server) active(r *http.Request) *Server {
+	sess, _ := auth.SessionFromContext(r.Context())
+	if b := s.lookup(sess.Backend); b != nil && b.allows(sess.Username) {
+		return b
+	}
+	for _, b := range s.backends {
+		if b.allows(sess.Username) {
+			return b
+		}
+	}
+	return nil
+}
+
+// serveActive handles the unprefixed paths.
+func (s *Server) serveActive(w http.ResponseWriter, r *http.Request) {
+	b := s.active(r)
+	if b == nil {
+		http.Error(w, "you do not have access to any Ganache backend", http.StatusForbidden)
+		return
+	}
+	b.handler.ServeHTTP(w, r)
+}
+
+// enter checks the user's role on the backend before handing the request
+// to its routes. Viewers may only read, save searches and download.
+func (s *Server) enter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch s.users.RoleOn(currentUser(r), s.backend.Name) {
+		case auth.RoleNone:
+			http.Error(w, "you do not have access to this Ganache backend", http.StatusForbidden)
+			return
+		case auth.RoleViewer:
+			if !readOnlyRequest(strings.TrimPrefix(r.URL.Path, s.base), r.Method) {
+				http.Error(w, "you have read-only access to this Ganache backend", http.StatusForbidden)
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), backendContextKey{}, s)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// readOnlyRequest reports whether a viewer may make the request. Saved
+// searches belong to the user, and ZIP downloads and copies to another
+// backend only read from this one.
+func readOnlyRequest(path, method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return path == "/downloads" || path == "/jobs/copy" || path == "/searches" || strings.HasPrefix(path, "/searches/")
+}
+
+// switchBackend makes another backend the session's active one.
+func (s *Server) switchBackend(w http.ResponseWriter, r *http.Request) {
+	b := s.lookup(r.FormValue("backend"))
+	if b == nil || !b.allows(currentUser(r)) {
+		http.Error(w, "unknown Ganache backend", http.StatusBadRequest)
+		return
+	}
+	sess, _ := auth.SessionFromContext(r.Context())
+	s.sessions.SetBackend(sess.ID, b.backend.Name)
+	http.Redirect(w, r, b.path("/assets"), http.StatusFound)
+}
+
+// scope fills in the parts of a page that depend on the backend it shows:
+// the base of its links, the switcher and what the user's role allows.
+func (s *Server) scope(r *http.Request, data *TemplateData) {
+	b, _ := r.Context().Value(backendContextKey{}).(*Server)
+	if b == nil {
+		if b = s.active(r); b == nil {
+			return
+		}
+	}
+	role := s.users.RoleOn(data.User, b.backend.Name)
+	data.Admin = role == auth.RoleAdmin
+	data.ReadOnly = role == auth.RoleViewer
+	data.Base = b.base
+	data.Backend = BackendOption{Name: b.backend.Name, Label: b.backend.Label}
+	var options []BackendOption
+	for _, other := range s.backends {
+		if other.allows(data.User) {
+			options = append(options, BackendOption{Name: other.backend.Name, Label: other.backend.Label})
+		}
+	}
+	if len(options) > 1 {
+		data.Backends = options
+	}
+}
@@ -1,85 +1,129 @@
 package httpui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/export"
 	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/revisions"
+	"ganache-admin-ui/internal/tagpolicy"
+	"ganache-admin-ui/internal/trash"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func (s *Server) assetsIndex(w http.ResponseWriter, r *http.Request) {
-	q := strings.TrimSpace(r.URL.Query().Get("q"))
-	tags := r.URL.Query()["tag"]
-	sort := r.URL.Query().Get("sort")
-	page := parseInt(r.URL.Query().Get("page"), 1)
-	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
-
-	resp, err := s.client.SearchAssets(r.Context(), q, tags, page, pageSize, sort)
+	data, err := s.searchAssets(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	extra := map[string]any{
-		"sort":     sort,
-		"hasPrev":  resp.Page > 1,
-		"hasNext":  resp.Page*resp.PageSize < resp.Total,
-		"prevPage": resp.Page - 1,
-		"nextPage": resp.Page + 1,
-		"pageSize": resp.PageSize,
-	}
-	s.templates.Render(w, "assets_index.html", TemplateData{
-		Title:  "Assets",
-		Query:  q,
-		Tags:   tags,
-		Search: resp,
-		Assets: resp.Assets,
-		Extra:  extra,
-	}, r)
+	data.Title = "Assets"
+	data.Extra["saved"] = s.savedSearchBanner(r)
+	data.Extra["collections"] = s.collections.List()
+	data.Extra["copyTargets"] = s.copyTargets(currentUser(r))
+	data.Extra["exportColumns"] = export.Columns
+	s.templates.Render(w, "assets_index.html", data, r)
 }
 
 func (s *Server) assetsResults(w http.ResponseWriter, r *http.Request) {
-	q := strings.TrimSpace(r.URL.Query().Get("q"))
-	tags := r.URL.Query()["tag"]
-	sort := r.URL.Query().Get("sort")
-	page := parseInt(r.URL.Query().Get("page"), 1)
-	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
-	resp, err := s.client.SearchAssets(r.Context(), q, tags, page, pageSize, sort)
+	data, err := s.searchAssets(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	extra := map[string]any{
-		"sort":     sort,
-		"hasPrev":  resp.Page > 1,
-		"hasNext":  resp.Page*resp.PageSize < resp.Total,
-		"prevPage": resp.Page - 1,
-		"nextPage": resp.Page + 1,
-		"pageSize": resp.PageSize,
-	}
 
 	if r.Header.Get("HX-Request") != "true" {
-		s.templates.Render(w, "assets_index.html", TemplateData{
-			Title:  "Assets",
-			Query:  q,
-			Tags:   tags,
-			Search: resp,
-			Assets: resp.Assets,
-			Extra:  extra,
-		}, r)
+		data.Title = "Assets"
+		s.templates.Render(w, "assets_index.html", data, r)
 		return
 	}
 
-	s.templates.Render(w, "assets_results_partial.html", TemplateData{
-		Query:  q,
-		Tags:   tags,
-		Search: resp,
-		Assets: resp.Assets,
-		Extra:  extra,
-	}, r)
+	s.templates.Render(w, "assets_results_partial.html", data, r)
+}
+
+// searchAssets runs the search described by the request's q, tag, sort and
+// paging parameters. A malformed q is reported in Extra["queryError"] with
+// no results rather than as an error.
+func (s *Server) searchAssets(r *http.Request) (TemplateData, error) {
+	params := r.URL.Query()
+	q := strings.TrimSpace(params.Get("q"))
+	tags := params["tag"]
+	sort := params.Get("sort")
+	page := parseInt(params.Get("page"), 1)
+	pageSize := parseInt(params.Get("pageSize"), 20)
+
+	link := url.Values{}
+	if q != "" {
+		link.Set("q", q)
+	}
+	for _, t := range tags {
+		link.Add("tag", t)
+	}
+	if sort != "" {
+		link.Set("sort", sort)
+	}
+	link.Set("pageSize", strconv.Itoa(pageSize))
+	extra := map[string]any{
+		"sort":      sort,
+		"pageSize":  pageSize,
+		"pageQuery": link.Encode(),
+	}
+	data := TemplateData{Query: q, Tags: tags, Extra: extra}
+
+	parsed, err := query.Parse(q)
+	if err != nil {
+		var syntaxErr *query.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			extra["queryError"] = syntaxErr
+			return data, nil
+		}
+		return data, err
+	}
+	parsed.Tags = append(parsed.Tags, tags...)
+	if parsed.Sort == "" {
+		parsed.Sort = sort
+	}
+	parsed.ExcludeIDs = s.trash.IDs()
+	if s.index != nil && s.index.Fresh(s.cfg.Index.MaxAge) {
+		res := s.index.Search(parsed, page, pageSize)
+		data.Search = res
+		data.Assets = res.Assets
+		extra["hasPrev"] = page > 1
+		extra["hasNext"] = page*pageSize < res.Total
+		extra["prevPage"] = page - 1
+		extra["nextPage"] = page + 1
+		extra["total"] = res.Total
+		extra["facets"] = facetGroups(s.base, q, tags, sort, res.Facets)
+		extra["indexedAt"] = s.index.Status().SyncedAt
+		return data, nil
+	}
+	res, err := query.Search(r.Context(), s.client, parsed, page, pageSize, s.cfg.Search.ScanLimit)
+	if err != nil {
+		return data, err
+	}
+	data.Search = res
+	data.Assets = res.Assets
+	extra["hasPrev"] = res.Page > 1
+	extra["hasNext"] = res.HasNext
+	extra["prevPage"] = res.Page - 1
+	extra["nextPage"] = res.Page + 1
+	extra["truncated"] = res.Truncated
+	extra["scanLimit"] = s.cfg.Search.ScanLimit
+	return data, nil
 }
 
 func (s *Server) assetsNew(w http.ResponseWriter, r *http.Request) {
@@ -101,23 +145,111 @@ func (s *Server) assetsUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-
-	fields := map[string]string{
-		"title":      r.FormValue("title"),
-		"caption":    r.FormValue("caption"),
-		"credit":     r.FormValue("credit"),
-		"source":     r.FormValue("source"),
-		"usageNotes": r.FormValue("usageNotes"),
+	if len(r.MultipartForm.File["file"]) > 1 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUploadForm(w, r, formFields(r), parseTags(r), "Several files were selected.", map[string]string{
+			"file": "Save to Library takes one file; use Queue in background for several.",
+		})
+		return
 	}
+
+	fields := formFields(r)
 	tags := parseTags(r)
 
-	asset, err := s.client.CreateAssetMultipart(r.Context(), file, header.Filename, fields, tags)
+	if _, err := media.Validate(file, s.uploadLimits()); err != nil {
+		var verr *media.ValidationError
+		if !errors.As(err, &verr) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		s.renderUploadForm(w, r, fields, tags, "Upload rejected. Fix the highlighted fields and try again.", verr.Fields)
+		return
+	}
+
+	if err := s.scanUpload(r.Context(), currentUser(r), file, header.Filename); err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			s.renderUploadForm(w, r, fields, tags, "Upload rejected by the malware scanner.", verr.Fields)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		s.renderUploadForm(w, r, fields, tags, err.Error(), nil)
+		return
+	}
+
+	asset, err := s.createAsset(r.Context(), file, header.Filename, fields, tags)
 	if err != nil {
-		data := TemplateData{Title: "Upload Asset", Error: err.Error(), Extra: map[string]any{"new": true}}
-		s.templates.Render(w, "assets_index.html", data, r)
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			s.renderUploadForm(w, r, fields, tags, "Upload rejected. Fix the highlighted fields and try again.", verr.Fields)
+			return
+		}
+		s.renderUploadForm(w, r, fields, tags, err.Error(), nil)
 		return
 	}
-	http.Redirect(w, r, fmt.Sprintf("/assets/%s", asset.ID), http.StatusFound)
+	http.Redirect(w, r, s.path(fmt.Sprintf("/assets/%s", asset.ID)), http.StatusFound)
+}
+
+func (s *Server) renderUploadForm(w http.ResponseWriter, r *http.Request, fields map[string]string, tags []string, msg string, fieldErrors map[string]string) {
+	form := make(map[string]string, len(fields)+1)
+	for k, v := range fields {
+		form[k] = v
+	}
+	form["tags"] = strings.Join(tags, ", ")
+	data := TemplateData{
+		Title: "Upload Asset",
+		Error: msg,
+		Extra: map[string]any{"new": true, "form": form, "fieldErrors": fieldErrors},
+	}
+	s.templates.Render(w, "assets_index.html", data, r)
+}
+
+// scanUpload runs the configured malware scanner over file and rewinds it.
+// Infected content is reported as a field error; an unreachable scanner is
+// only an error when the scan policy is fail-closed.
+func (s *Server) scanUpload(ctx context.Context, user string, file io.ReadSeeker, filename string) error {
+	if s.scanner == nil {
+		return nil
+	}
+	res, err := s.scanner.Scan(ctx, file)
+	if _, serr := file.Seek(0, io.SeekStart); serr != nil {
+		return serr
+	}
+	if err != nil {
+		if s.cfg.Scan.FailOpen {
+			slog.WarnContext(ctx, "upload scan unavailable, accepting (fail-open)", "user", user, "file", filename, "error", err)
+			return nil
+		}
+		slog.ErrorContext(ctx, "upload scan unavailable, rejecting (fail-closed)", "user", user, "file", filename, "error", err)
+		return errors.New("malware scanner unavailable; upload not accepted, try again later")
+	}
+	if res.Infected {
+		slog.WarnContext(ctx, "upload rejected, malware detected", "user", user, "file", filename, "signature", res.Signature)
+		return &media.ValidationError{Fields: map[string]string{
+			"file": fmt.Sprintf("file rejected: malware detected (%s)", res.Signature),
+		}}
+	}
+	return nil
+}
+
+func (s *Server) uploadLimits() media.Limits {
+	return media.Limits{
+		AllowedTypes: s.cfg.Upload.AllowedTypes,
+		MaxWidth:     s.cfg.Upload.MaxWidth,
+		MaxHeight:    s.cfg.Upload.MaxHeight,
+		MaxPixels:    s.cfg.Upload.MaxPixels,
+	}
+}
+
+// tusLimits also accept the video and TIFF originals that resumable
+// uploads are meant for. Only images have their dimensions checked.
+func (s *Server) tusLimits() media.Limits {
+	limits := s.uploadLimits()
+	limits.AllowedTypes = s.cfg.Tus.AllowedTypes
+	return limits
 }
 
 func (s *Server) assetDetail(w http.ResponseWriter, r *http.Request) {
@@ -127,7 +259,18 @@ func (s *Server) assetDetail(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	s.templates.Render(w, "asset_detail.html", TemplateData{Title: asset.Title, Asset: asset}, r)
+	s.templates.Render(w, "asset_detail.html", TemplateData{
+		Title: asset.Title,
+		Asset: asset,
+		Extra: map[string]any{
+			"tagWarnings":    s.tagWarnings(asset.Tags),
+			"memberships":    s.collections.Containing(id),
+			"trashed":        s.trashedEntry(id),
+			"allCollections": s.collections.List(),
+			"copyTargets":    s.copyTargets(currentUser(r)),
+			"copies":         s.assetCopies(id),
+		},
+	}, r)
 }
 
 func (s *Server) assetEdit(w http.ResponseWriter, r *http.Request) {
@@ -144,25 +287,182 @@ func (s *Server) assetEdit(w http.ResponseWriter, r *http.Request) {
 		UsageNotes: r.FormValue("usageNotes"),
 		Tags:       parseTags(r),
 	}
-	asset, err := s.client.UpdateAsset(r.Context(), id, update)
+	before, err := s.client.GetAsset(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
+	asset, err := s.updateAsset(r.Context(), currentUser(r), "", before, update)
+	if err != nil {
+		var verr *media.ValidationError
+		if !errors.As(err, &verr) {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if r.Header.Get("HX-Request") != "true" {
+			fmt.Fprintln(w, verr.Error())
+			return
+		}
+		// Keep what was typed so the user can fix the tags in place.
+		typed := ganache.Asset{
+			ID:         ganache.StringID(id),
+			Title:      update.Title,
+			Caption:    update.Caption,
+			Credit:     update.Credit,
+			Source:     update.Source,
+			UsageNotes: update.UsageNotes,
+			Tags:       update.Tags,
+		}
+		s.templates.Render(w, "asset_meta_partial.html", TemplateData{
+			Asset: typed,
+			Extra: map[string]any{"fieldErrors": verr.Fields},
+		}, r)
+		return
+	}
 	if r.Header.Get("HX-Request") == "true" {
-		s.templates.Render(w, "asset_meta_partial.html", TemplateData{Asset: asset}, r)
+		s.templates.Render(w, "asset_meta_partial.html", TemplateData{
+			Asset: asset,
+			Extra: map[string]any{"tagWarnings": s.tagWarnings(asset.Tags)},
+		}, r)
 		return
 	}
-	http.Redirect(w, r, fmt.Sprintf("/assets/%s", asset.ID), http.StatusFound)
+	http.Redirect(w, r, s.path(fmt.Sprintf("/assets/%s", asset.ID)), http.StatusFound)
 }
 
 func (s *Server) assetDelete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if err := s.client.DeleteAsset(r.Context(), id); err != nil {
+	if err := s.trashAsset(r.Context(), currentUser(r), id); err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	http.Redirect(w, r, "/assets", http.StatusFound)
+	http.Redirect(w, r, s.path("/assets"), http.StatusFound)
+}
+
+// createAsset, updateAsset and deleteAsset are the only paths from the UI
+// to Ganache writes, so local state such as the search index follows every
+// change made here. The tag policy is applied here for the same reason.
+// deleteAsset is only reached through the trash purge.
+func (s *Server) createAsset(ctx context.Context, file io.Reader, filename string, fields map[string]string, tags []string) (ganache.Asset, error) {
+	tags, err := s.checkTags(tags)
+	if err != nil {
+		return ganache.Asset{}, err
+	}
+	counted := &countingReader{r: file}
+	asset, err := s.client.CreateAssetMultipart(ctx, counted, filename, fields, tags)
+	s.metrics.Upload(s.backend.Name, counted.n.Load(), err)
+	if err != nil {
+		return asset, err
+	}
+	if s.index != nil && asset.ID != "" {
+		indexed := asset
+		if indexed.Title == "" && len(indexed.Tags) == 0 {
+			indexed.Title, indexed.Caption = fields["title"], fields["caption"]
+			indexed.Credit, indexed.Source, indexed.UsageNotes = fields["credit"], fields["source"], fields["usageNotes"]
+			indexed.Tags = tags
+		}
+		if indexed.CreatedAt.IsZero() {
+			indexed.CreatedAt = time.Now().UTC()
+		}
+		s.index.Put(indexed)
+	}
+	return asset, nil
+}
+
+// countingReader counts the bytes read through it. The multipart upload
+// reads it from its own goroutine, which may outlive a failed request.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// updateAsset writes update over before, the asset as last read from
+// Ganache, and records the change in the revision history under author.
+func (s *Server) updateAsset(ctx context.Context, author, note string, before ganache.Asset, update ganache.AssetUpdate) (ganache.Asset, error) {
+	tags, err := s.checkTags(update.Tags)
+	if err != nil {
+		return ganache.Asset{}, err
+	}
+	update.Tags = tags
+	id := string(before.ID)
+	asset, err := s.client.UpdateAsset(ctx, id, update)
+	if err != nil {
+		return asset, err
+	}
+	if s.index != nil {
+		s.index.Update(id, update)
+	}
+	if _, err := s.revisions.Record(revisions.Revision{
+		AssetID: id,
+		User:    author,
+		Note:    note,
+		Before:  before.AsUpdate(),
+		After:   update,
+	}); err != nil {
+		slog.ErrorContext(ctx, "revisions: record", "asset", id, "error", err)
+	}
+	return asset, nil
+}
+
+func (s *Server) deleteAsset(ctx context.Context, id string) error {
+	if err := s.client.DeleteAsset(ctx, id); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index.Remove(id)
+	}
+	if err := s.collections.Forget(id); err != nil {
+		slog.ErrorContext(ctx, "collections: forget", "asset", id, "error", err)
+	}
+	return nil
+}
+
+// trashedEntry returns the trash entry for id, or nil when the asset is
+// not in the trash.
+func (s *Server) trashedEntry(id string) *trash.Entry {
+	e, err := s.trash.Get(id)
+	if err != nil {
+		return nil
+	}
+	return &e
+}
+
+// checkTags normalises tags with the tag policy. Tags a strict policy
+// rejects come back as a field error on "tags".
+func (s *Server) checkTags(tags []string) ([]string, error) {
+	out, err := s.policy.Check(tags)
+	var perr *tagpolicy.Error
+	if errors.As(err, &perr) {
+		return nil, &media.ValidationError{Fields: map[string]string{"tags": perr.Error()}}
+	}
+	return out, err
+}
+
+// tagWarnings lists the asset's tags that are outside the vocabulary.
+func (s *Server) tagWarnings(tags []string) []tagpolicy.Unknown {
+	return s.policy.Apply(tags).Unknown
+}
+
+// formFields collects the asset metadata fields Ganache accepts on upload.
+func formFields(r *http.Request) map[string]string {
+	return map[string]string{
+		"title":      r.FormValue("title"),
+		"caption":    r.FormValue("caption"),
+		"credit":     r.FormValue("credit"),
+		"source":     r.FormValue("source"),
+		"usageNotes": r.FormValue("usageNotes"),
+	}
+}
+
+func currentUser(r *http.Request) string {
+	sess, _ := auth.SessionFromContext(r.Context())
+	return sess.Username
 }
 
 func parseTags(r *http.Request) []string {
@@ -172,23 +472,7 @@ func parseTags(r *http.Request) []string {
 	if v := r.FormValue("tags"); v != "" {
 		inputs = append(inputs, v)
 	}
-
-	seen := map[string]struct{}{}
-	var result []string
-	for _, raw := range inputs {
-		for _, part := range strings.Split(raw, ",") {
-			t := strings.TrimSpace(part)
-			if t == "" {
-				continue
-			}
-			if _, ok := seen[t]; ok {
-				continue
-			}
-			seen[t] = struct{}{}
-			result = append(result, t)
-		}
-	}
-	return result
+	return tagpolicy.Split(inputs...)
 }
 
 func parseInt(val string, def int) int {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/logging"
 )
 
 func (s *Server) showLogin(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +20,9 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
-	if !s.users.Validate(username, password) {
+	ok := s.users.Validate(username, password)
+	s.metrics.Login(ok)
+	if !ok {
 		data := TemplateData{Title: "Login", Error: "Invalid credentials"}
 		s.templates.Render(w, "login.html", data, r)
 		return
@@ -29,6 +32,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unable to create session", http.StatusInternalServerError)
 		return
 	}
+	logging.SetUser(r.Context(), username)
 	secure := secureCookie()
 	if r.TLS != nil {
 		secure = true
@@ -0,0 +1,142 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/config"
+	"ganache-admin-ui/internal/ganache"
+)
+
+// newBackendsServer serves two backends, staging and production. Each is
+// a fake Ganache whose single asset is titled after it, unless handlers
+// has one for it.
+func newBackendsServer(t *testing.T, users *auth.UserStore, handlers map[string]http.HandlerFunc) (*Server, *auth.SessionStore) {
+	t.Helper()
+	var backends []config.GanacheConfig
+	for _, name := range []string{"staging", "production"} {
+		title := name
+		handler := handlers[name]
+		if handler == nil {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/assets" {
+					json.NewEncoder(w).Encode(ganache.SearchResponse{
+						Assets: []ganache.Asset{{ID: "1", Title: title}},
+						Page:   1, PageSize: 20, Total: 1,
+					})
+					return
+				}
+				json.NewEncoder(w).Encode(ganache.Asset{ID: "1", Title: title})
+			}
+		}
+		fake := httptest.NewServer(handler)
+		t.Cleanup(fake.Close)
+		backends = append(backends, config.GanacheConfig{Name: name, Label: strings.ToUpper(name), BaseURL: fake.URL, APIKey: "key", Timeout: time.Second})
+	}
+	cfg := &config.Config{
+		DataDir:  t.TempDir(),
+		Backends: backends,
+		Tus:      config.TusConfig{MaxSize: 1 << 20, Expiry: time.Hour},
+	}
+	sessions := auth.NewSessionStore(time.Hour)
+	srv, err := NewServer(cfg, users, sessions)
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	return srv, sessions
+}
+
+func TestBackendsAreNamespacedAndSwitchedPerSession(t *testing.T) {
+	users, _ := auth.NewUserStore([]auth.User{{Username: "tester", PasswordHash: "hash"}})
+	srv, sessions := newBackendsServer(t, users, nil)
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/assets/1")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "staging") {
+		t.Fatalf("expected the default backend's asset, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `href="/b/staging/collections"`) || !strings.Contains(rec.Body.String(), "PRODUCTION") {
+		t.Fatalf("expected links under the backend's base and a switcher: %s", rec.Body.String())
+	}
+	if rec := get("/b/production/assets/1"); !strings.Contains(rec.Body.String(), "production") {
+		t.Fatalf("expected the production asset under its base, got %d", rec.Code)
+	}
+
+	form := url.Values{"csrf": {sess.CSRFToken}, "backend": {"production"}}
+	req := httptest.NewRequest(http.MethodPost, "/backend", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/b/production/assets" {
+		t.Fatalf("expected a redirect to the production library, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := get("/assets/1"); !strings.Contains(rec.Body.String(), "production") {
+		t.Fatal("expected unprefixed paths to follow the switch")
+	}
+	other, _ := sessions.Create("tester")
+	if got, _ := sessions.Get(other.ID); got.Backend != "" {
+		t.Fatal("the active backend should be per session")
+	}
+}
+
+func TestBackendRoles(t *testing.T) {
+	users, _ := auth.NewUserStore([]auth.User{
+		{Username: "tester", PasswordHash: "hash", Backends: map[string]string{"production": auth.RoleViewer}},
+		{Username: "intern", PasswordHash: "hash", Backends: map[string]string{"staging": auth.RoleNone}},
+	})
+	srv, sessions := newBackendsServer(t, users, nil)
+	router := srv.Router()
+	tester, _ := sessions.Create("tester")
+	intern, _ := sessions.Create("intern")
+	do := func(sess auth.Session, method, path string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodPost {
+			form := url.Values{"csrf": {sess.CSRFToken}, "title": {"Changed"}}
+			req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(tester, http.MethodGet, "/b/production/assets/1")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Delete asset") {
+		t.Fatalf("expected a read-only page for a viewer, got %d", rec.Code)
+	}
+	if rec := do(tester, http.MethodPost, "/b/production/assets/1/edit"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected viewers to be refused edits, got %d", rec.Code)
+	}
+	if rec := do(tester, http.MethodPost, "/b/production/collections"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected viewers to be refused new collections, got %d", rec.Code)
+	}
+	if rec := do(tester, http.MethodGet, "/b/staging/assets/1"); !strings.Contains(rec.Body.String(), "Delete asset") {
+		t.Fatal("the viewer role should only apply to production")
+	}
+
+	if rec := do(intern, http.MethodGet, "/b/staging/assets"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected no access to staging, got %d", rec.Code)
+	}
+	rec = do(intern, http.MethodGet, "/assets/1")
+	if !strings.Contains(rec.Body.String(), "production") || strings.Contains(rec.Body.String(), "STAGING") {
+		t.Fatal("expected unprefixed paths to fall back to a backend the user may use, without the other in the switcher")
+	}
+}
@@ -0,0 +1,262 @@
+package httpui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/collections"
+	"ganache-admin-ui/internal/ganache"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const collectionFetchConcurrent = 4
+
+// collectionItem is one asset on a collection page. Assets Ganache no
+// longer returns are kept with Missing set so they can be removed.
+type collectionItem struct {
+	ID      string
+	Asset   ganache.Asset
+	Missing bool
+}
+
+// collectionExport is the JSON export of a collection, assets in order.
+type collectionExport struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name"`
+	Note      string                  `json:"note,omitempty"`
+	CreatedBy string                  `json:"createdBy"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	Assets    []collectionExportAsset `json:"assets"`
+}
+
+type collectionExportAsset struct {
+	ganache.Asset
+	Missing bool `json:"missing,omitempty"`
+}
+
+func (s *Server) collectionsIndex(w http.ResponseWriter, r *http.Request) {
+	s.templates.Render(w, "collections.html", TemplateData{
+		Title: "Collections",
+		Extra: map[string]any{"collections": s.collections.List()},
+	}, r)
+}
+
+func (s *Server) collectionCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	c, err := s.collections.Create(collections.Collection{
+		Owner: currentUser(r),
+		Name:  r.FormValue("name"),
+		Note:  strings.TrimSpace(r.FormValue("note")),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, s.path("/collections/"+c.ID), http.StatusFound)
+}
+
+// collectionAdd adds the posted asset IDs to a collection, creating it when
+// a new name is given instead. It serves both the bulk bar on the library
+// page and the detail page, which sends back to return there.
+func (s *Server) collectionAdd(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var ids []string
+	for _, id := range r.Form["ids"] {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "select at least one asset", http.StatusBadRequest)
+		return
+	}
+	var (
+		c   collections.Collection
+		err error
+	)
+	switch id := r.FormValue("collection"); {
+	case strings.TrimSpace(r.FormValue("collectionName")) != "":
+		c, err = s.collections.Create(collections.Collection{Owner: currentUser(r), Name: r.FormValue("collectionName"), AssetIDs: ids})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case id != "":
+		c, err = s.collections.Add(id, ids...)
+		if !collectionWriteOK(w, err) {
+			return
+		}
+	default:
+		http.Error(w, "choose a collection or enter a name for a new one", http.StatusBadRequest)
+		return
+	}
+	target := s.path("/collections/" + c.ID)
+	http.Redirect(w, r, s.backTo(r.FormValue("back"), target), http.StatusFound)
+}
+
+func (s *Server) collectionShow(w http.ResponseWriter, r *http.Request) {
+	c, err := s.collections.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	items, err := s.collectionItems(r.Context(), c.AssetIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.templates.Render(w, "collection.html", TemplateData{
+		Title: c.Name,
+		Extra: map[string]any{"collection": c, "items": items},
+	}, r)
+}
+
+func (s *Server) collectionExport(w http.ResponseWriter, r *http.Request) {
+	c, err := s.collections.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	items, err := s.collectionItems(r.Context(), c.AssetIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out := collectionExport{
+		ID:        c.ID,
+		Name:      c.Name,
+		Note:      c.Note,
+		CreatedBy: c.Owner,
+		UpdatedAt: c.UpdatedAt,
+		Assets:    make([]collectionExportAsset, len(items)),
+	}
+	for i, it := range items {
+		out.Assets[i] = collectionExportAsset{Asset: it.Asset, Missing: it.Missing}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "collection-"+c.ID+".json"))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(out)
+}
+
+func (s *Server) collectionUpdate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	c, err := s.collections.Update(chi.URLParam(r, "id"), func(c *collections.Collection) {
+		c.Name = r.FormValue("name")
+		c.Note = r.FormValue("note")
+	})
+	if !collectionWriteOK(w, err) {
+		return
+	}
+	http.Redirect(w, r, s.path("/collections/"+c.ID), http.StatusFound)
+}
+
+func (s *Server) collectionRemove(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	c, err := s.collections.Remove(chi.URLParam(r, "id"), r.FormValue("asset"))
+	if !collectionWriteOK(w, err) {
+		return
+	}
+	target := s.path("/collections/" + c.ID)
+	http.Redirect(w, r, s.backTo(r.FormValue("back"), target), http.StatusFound)
+}
+
+// backTo returns back when it is a path within this backend's pages, and
+// fallback otherwise, so a posted form cannot redirect off the site.
+// Browsers read a backslash as a slash, so "/\host" would lead to another
+// host as "//host" does.
+func (s *Server) backTo(back, fallback string) string {
+	u, err := url.Parse(back)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || strings.Contains(back, "\\") {
+		return fallback
+	}
+	if !strings.HasPrefix(u.Path, s.path("/")) {
+		return fallback
+	}
+	return back
+}
+
+// collectionReorder stores the order posted by the drag-and-drop list.
+func (s *Server) collectionReorder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	_, err := s.collections.Reorder(chi.URLParam(r, "id"), r.Form["ids"])
+	if !collectionWriteOK(w, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) collectionDelete(w http.ResponseWriter, r *http.Request) {
+	err := s.collections.Delete(chi.URLParam(r, "id"))
+	if !collectionWriteOK(w, err) {
+		return
+	}
+	http.Redirect(w, r, s.path("/collections"), http.StatusFound)
+}
+
+func collectionWriteOK(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, collections.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
+
+// collectionItems loads the assets of a collection in order. Assets Ganache
+// reports as not found are marked missing; any other error fails the page.
+func (s *Server) collectionItems(ctx context.Context, ids []string) ([]collectionItem, error) {
+	items := make([]collectionItem, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, collectionFetchConcurrent)
+	for i, id := range ids {
+		items[i].ID = id
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			asset, err := s.client.GetAsset(ctx, id)
+			if err != nil {
+				if ganache.IsNotFound(err) {
+					items[i].Missing = true
+					items[i].Asset.ID = ganache.StringID(id)
+					return
+				}
+				errs[i] = err
+				return
+			}
+			items[i].Asset = asset
+		}(i, id)
+	}
+	wg.Wait()
+	return items, errors.Join(errs...)
+}
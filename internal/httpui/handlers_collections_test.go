@@ -0,0 +1,94 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestCollectionAddReorderAndExport(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+		if id == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": "not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(ganache.Asset{
+			ID:       ganache.StringID(id),
+			Title:    "Asset " + id,
+			Variants: ganache.Variants{Content: "https://cdn.example/" + id + ".jpg"},
+		})
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("csrf", sess.CSRFToken)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/collections/add", url.Values{"ids": {"1", "2"}, "collectionName": {"Derby"}})
+	target := rec.Header().Get("Location")
+	if rec.Code != http.StatusFound || !strings.HasPrefix(target, "/collections/") {
+		t.Fatalf("unexpected create response %d %q", rec.Code, target)
+	}
+	id := strings.TrimPrefix(target, "/collections/")
+	rec = post("/collections/add", url.Values{"ids": {"gone"}, "collection": {id}, "back": {"/assets/gone"}})
+	if loc := rec.Header().Get("Location"); loc != "/assets/gone" {
+		t.Fatalf("expected redirect back, got %q", loc)
+	}
+	for _, back := range []string{"//evil.example", `/\evil.example`, "https://evil.example/", "javascript:alert(1)", "assets/1"} {
+		rec = post("/collections/add", url.Values{"ids": {"1"}, "collection": {id}, "back": {back}})
+		if loc := rec.Header().Get("Location"); loc != target {
+			t.Fatalf("back=%q: expected the collection page, got %q", back, loc)
+		}
+	}
+	if rec := post("/collections/add", url.Values{"ids": {"1"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a collection, got %d", rec.Code)
+	}
+
+	if rec := post(target+"/reorder", url.Values{"ids": {"2", "gone", "1"}}); rec.Code != http.StatusNoContent {
+		t.Fatalf("reorder: %d", rec.Code)
+	}
+
+	body := get(target).Body.String()
+	if !strings.Contains(body, `data-copy="https://cdn.example/2.jpg"`) || !strings.Contains(body, "no longer exists") {
+		t.Fatalf("collection page missing variant URL or missing asset")
+	}
+	if strings.Index(body, "Asset 2") > strings.Index(body, "Asset 1") {
+		t.Fatalf("collection page not in saved order")
+	}
+
+	rec = get(target + "/export.json")
+	var export collectionExport
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if export.Name != "Derby" || export.CreatedBy != "tester" || len(export.Assets) != 3 {
+		t.Fatalf("unexpected export %+v", export)
+	}
+	if export.Assets[0].ID != "2" || !export.Assets[1].Missing || export.Assets[2].Variants.Content != "https://cdn.example/1.jpg" {
+		t.Fatalf("unexpected export assets %+v", export.Assets)
+	}
+
+	if body := get("/assets/1").Body.String(); !strings.Contains(body, `href="`+target+`"`) {
+		t.Fatalf("detail page should list the collection")
+	}
+}
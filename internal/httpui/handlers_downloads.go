@@ -0,0 +1,124 @@
+package httpui
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/bundle"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/query"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// downloadSelection zips the assets ticked on the library page.
+func (s *Server) downloadSelection(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range r.Form["ids"] {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "select at least one asset", http.StatusBadRequest)
+		return
+	}
+	s.writeBundle(w, r, "assets", s.assetsByID(r, ids))
+}
+
+// downloadSearch zips every result of a saved search.
+func (s *Server) downloadSearch(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.searches.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	parsed, err := query.Parse(saved.Query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed.Tags = append(parsed.Tags, saved.Tags...)
+	if parsed.Sort == "" {
+		parsed.Sort = saved.Sort
+	}
+	parsed.ExcludeIDs = s.trash.IDs()
+	s.writeBundle(w, r, "search-"+saved.ID, func(yield func(ganache.Asset) error) error {
+		return query.Each(r.Context(), s.client, parsed, yield)
+	})
+}
+
+// downloadCollection zips a collection's assets in collection order.
+func (s *Server) downloadCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := s.collections.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.writeBundle(w, r, "collection-"+c.ID, s.assetsByID(r, c.AssetIDs))
+}
+
+// assetsByID yields the assets in order, fetching each one as it is
+// needed. Trashed assets and those Ganache no longer has are skipped.
+func (s *Server) assetsByID(r *http.Request, ids []string) bundle.Source {
+	return func(yield func(ganache.Asset) error) error {
+		trashed := s.trash.IDs()
+		for _, id := range ids {
+			if trashed[id] {
+				continue
+			}
+			a, err := s.client.GetAsset(r.Context(), id)
+			if ganache.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := yield(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// writeBundle streams the ZIP. Once the archive has started the status can
+// no longer change, so a failure aborts the connection rather than leave a
+// truncated archive that looks complete.
+func (s *Server) writeBundle(w http.ResponseWriter, r *http.Request, name string, src bundle.Source) {
+	opts := bundle.Options{
+		Variant:     r.FormValue("variant"),
+		Metadata:    r.FormValue("metadata"),
+		Concurrency: s.cfg.Download.Concurrency,
+		MaxAssets:   s.cfg.Download.MaxAssets,
+		TempDir:     filepath.Join(s.cfg.DataDir, "tmp"),
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := os.MkdirAll(opts.TempDir, 0o700); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("%s-%s.zip", name, time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	report, err := bundle.Write(r.Context(), w, src, s.client.Download, opts)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "download: aborted", "file", filename, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	slog.InfoContext(r.Context(), "download: done", "file", filename, "assets", report.Added, "failed", len(report.Failed))
+}
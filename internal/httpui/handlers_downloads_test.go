@@ -0,0 +1,116 @@
+package httpui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/collections"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/searches"
+)
+
+// downloadBackend serves asset metadata with relative variant URLs and the
+// variant files themselves, which need the API key.
+func downloadBackend(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/files/"):
+		if r.Header.Get("X-Api-Key") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "bytes of "+strings.TrimPrefix(r.URL.Path, "/files/"))
+	case r.URL.Path == "/api/assets":
+		json.NewEncoder(w).Encode(ganache.SearchResponse{
+			Assets: []ganache.Asset{downloadAsset("7"), downloadAsset("8")},
+			Page:   1, PageSize: 100, Total: 2,
+		})
+	case r.URL.Path == "/api/assets/404":
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		json.NewEncoder(w).Encode(downloadAsset(strings.TrimPrefix(r.URL.Path, "/api/assets/")))
+	}
+}
+
+func downloadAsset(id string) ganache.Asset {
+	return ganache.Asset{
+		ID:       ganache.StringID(id),
+		Title:    "Asset " + id,
+		Variants: ganache.Variants{Original: "/files/" + id + ".jpg", Thumb: "/files/" + id + "-thumb.jpg"},
+	}
+}
+
+func zipEntries(t *testing.T, rec *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
+
+func TestDownloadSelection(t *testing.T) {
+	srv, sessions := newTestServer(t, downloadBackend)
+	srv.cfg.Download.Concurrency = 2
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	form := url.Values{"csrf": {sess.CSRFToken}, "ids": {"1", "404", "2", "1"}, "variant": {"thumb"}, "metadata": {"xmp"}}
+	req := httptest.NewRequest(http.MethodPost, "/downloads", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	entries := zipEntries(t, rec)
+	if len(entries) != 4 || entries["1-1-thumb.jpg"] != "bytes of 1-thumb.jpg" || !strings.Contains(entries["2-2-thumb.xmp"], "Asset 2") {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+}
+
+func TestDownloadCollectionAndSearch(t *testing.T) {
+	srv, sessions := newTestServer(t, downloadBackend)
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	c, _ := srv.collections.Create(collections.Collection{Owner: "tester", Name: "Gallery", AssetIDs: []string{"5", "3"}})
+	saved, _ := srv.searches.Create(searches.Search{Owner: "someone", Name: "Cup", Query: "cup"})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	entries := zipEntries(t, get("/collections/"+c.ID+"/download.zip"))
+	if entries["5.jpg"] != "bytes of 5.jpg" || !strings.Contains(entries["metadata.csv"], "5.jpg,5,Asset 5") {
+		t.Fatalf("unexpected collection zip %v", entries)
+	}
+
+	entries = zipEntries(t, get("/searches/"+saved.ID+"/download.zip?metadata=none"))
+	if len(entries) != 2 || entries["8.jpg"] != "bytes of 8.jpg" {
+		t.Fatalf("unexpected search zip %v", entries)
+	}
+
+	if rec := get("/collections/" + c.ID + "/download.zip?variant=raw"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown variant, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package httpui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/export"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/query"
+)
+
+// assetsExport streams every result of the current search as a CSV or
+// XLSX download. It always asks Ganache rather than the local index, so
+// the export reflects live metadata.
+func (s *Server) assetsExport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	format := params.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	kind, ok := export.Formats[format]
+	if !ok {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+	cols, err := export.Select(params["col"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed, err := query.Parse(strings.TrimSpace(params.Get("q")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed.Tags = append(parsed.Tags, params["tag"]...)
+	if parsed.Sort == "" {
+		parsed.Sort = params.Get("sort")
+	}
+	parsed.ExcludeIDs = s.trash.IDs()
+
+	name := "assets-" + time.Now().UTC().Format("20060102-150405") + kind.Ext
+	w.Header().Set("Content-Type", kind.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	count, err := s.writeExport(r.Context(), w, format, cols, parsed)
+	if err != nil {
+		// The download has started, so the status can no longer change.
+		// Aborting the connection keeps a truncated file from looking complete.
+		slog.ErrorContext(r.Context(), "export: aborted", "format", format, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	slog.InfoContext(r.Context(), "export: done", "format", format, "assets", count)
+}
+
+// writeExport writes the matching assets and returns how many were written.
+func (s *Server) writeExport(ctx context.Context, w io.Writer, format string, cols []export.Column, q query.Query) (int, error) {
+	out, err := export.NewWriter(format, w)
+	if err != nil {
+		return 0, err
+	}
+	exp, err := export.New(out, cols)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = query.Each(ctx, s.client, q, func(a ganache.Asset) error {
+		count++
+		return exp.Asset(a)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, exp.Close()
+}
@@ -0,0 +1,107 @@
+package httpui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/trash"
+)
+
+// exportBackend serves 250 assets; every third one is credited to Reuters.
+func exportBackend(tags *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tags != nil {
+			*tags = r.URL.Query()["tag"]
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		resp := ganache.SearchResponse{Page: page, PageSize: size, Total: 250}
+		for i := (page - 1) * size; i < page*size && i < 250; i++ {
+			a := ganache.Asset{ID: ganache.StringID(strconv.Itoa(i)), Title: fmt.Sprintf("Asset %d", i), Credit: "AP"}
+			if i%3 == 0 {
+				a.Credit = "Reuters"
+			}
+			resp.Assets = append(resp.Assets, a)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestAssetsExportCSVStreamsEveryPage(t *testing.T) {
+	var tags []string
+	srv, sessions := newTestServer(t, exportBackend(&tags))
+	router := srv.Router()
+	srv.trash.Add(trash.Entry{Asset: ganache.Asset{ID: "3"}, DeletedAt: time.Now()})
+	sess, _ := sessions.Create("tester")
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/export?format=csv&q=credit:reuters&tag=sport&col=id&col=credit", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	// 84 Reuters assets across three Ganache pages, less the trashed one.
+	if len(rows) != 84 || rows[0][0] != "ID" || rows[0][1] != "Credit" || rows[1][0] != "0" || rows[2][0] != "6" || rows[83][0] != "249" {
+		t.Fatalf("unexpected rows: %d %v", len(rows), rows[:3])
+	}
+	if len(tags) != 1 || tags[0] != "sport" {
+		t.Fatalf("tag filter not passed to Ganache: %v", tags)
+	}
+}
+
+func TestAssetsExportXLSX(t *testing.T) {
+	srv, sessions := newTestServer(t, exportBackend(nil))
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/export?format=xlsx", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	if last := zr.File[len(zr.File)-1].Name; last != "xl/worksheets/sheet1.xml" {
+		t.Fatalf("unexpected last part %s", last)
+	}
+}
+
+func TestAssetsExportRejectsBadParams(t *testing.T) {
+	srv, sessions := newTestServer(t, exportBackend(nil))
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	for _, path := range []string{
+		"/assets/export?format=pdf",
+		"/assets/export?col=password",
+		`/assets/export?q=credit:"open`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package httpui
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/metaimport"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxImportSize = 5 * 1024 * 1024
+
+func (s *Server) importsNew(w http.ResponseWriter, r *http.Request) {
+	s.templates.Render(w, "imports.html", TemplateData{Title: "Import"}, r)
+}
+
+// importCreate parses an uploaded CSV and saves the dry run for review.
+// Nothing is written to Ganache until the import is applied.
+func (s *Server) importCreate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		s.renderImportError(w, r, http.StatusRequestEntityTooLarge, "The file is larger than 5MB.")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.renderImportError(w, r, http.StatusBadRequest, "Choose a CSV file to import.")
+		return
+	}
+	defer file.Close()
+	rows, err := metaimport.Parse(file)
+	if err != nil {
+		s.renderImportError(w, r, http.StatusUnprocessableEntity, header.Filename+": "+err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		s.renderImportError(w, r, http.StatusUnprocessableEntity, header.Filename+" has no rows.")
+		return
+	}
+	imp, err := s.imports.Save(metaimport.Import{
+		Owner:    currentUser(r),
+		Filename: header.Filename,
+		Results:  metaimport.Plan(r.Context(), s.client.GetAsset, s.policy.Check, rows),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/imports/"+imp.ID), http.StatusFound)
+}
+
+func (s *Server) renderImportError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	w.WriteHeader(status)
+	s.templates.Render(w, "imports.html", TemplateData{Title: "Import", Error: msg}, r)
+}
+
+// importShow renders the dry run, or the report once it has been applied.
+func (s *Server) importShow(w http.ResponseWriter, r *http.Request) {
+	imp, ok := s.ownImport(w, r)
+	if !ok {
+		return
+	}
+	s.templates.Render(w, "imports.html", TemplateData{
+		Title: "Import",
+		Extra: map[string]any{"import": imp, "summary": imp.Summary()},
+	}, r)
+}
+
+func (s *Server) importApply(w http.ResponseWriter, r *http.Request) {
+	imp, ok := s.ownImport(w, r)
+	if !ok {
+		return
+	}
+	if !imp.AppliedAt.IsZero() {
+		http.Error(w, "this import has already been applied", http.StatusConflict)
+		return
+	}
+	user := currentUser(r)
+	note := "CSV import of " + imp.Filename
+	imp.Results = metaimport.Apply(r.Context(), s.client.GetAsset, func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+		_, err := s.updateAsset(ctx, user, note, before, after)
+		return err
+	}, imp.Results)
+	imp.AppliedAt = time.Now().UTC()
+	if _, err := s.imports.Save(imp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := imp.Summary()
+	slog.InfoContext(r.Context(), "import: applied", "file", imp.Filename, "applied", sum.Applied, "unchanged", sum.Unchanged, "failed", sum.Failed)
+	http.Redirect(w, r, s.path("/imports/"+imp.ID), http.StatusFound)
+}
+
+// ownImport loads the import in the URL. Imports are only visible to the
+// user who uploaded them.
+func (s *Server) ownImport(w http.ResponseWriter, r *http.Request) (metaimport.Import, bool) {
+	imp, err := s.imports.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, metaimport.ErrNotFound) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return metaimport.Import{}, false
+	}
+	if imp.Owner != currentUser(r) {
+		http.NotFound(w, r)
+		return metaimport.Import{}, false
+	}
+	return imp, true
+}
@@ -0,0 +1,124 @@
+package httpui
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+)
+
+func importRequest(sess auth.Session, filename, content string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fileWriter, _ := writer.CreateFormFile("file", filename)
+	fileWriter.Write([]byte(content))
+	writer.WriteField("csrf", sess.CSRFToken)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/imports", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	return req
+}
+
+func TestImportPreviewThenApply(t *testing.T) {
+	var mu sync.Mutex
+	assets := map[string]ganache.Asset{
+		"1": {ID: "1", Title: "One", Caption: "old caption"},
+		"2": {ID: "2", Title: "Two", Caption: "kept"},
+	}
+	var updates []string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+		a, ok := assets[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "not found"})
+			return
+		}
+		if r.Method == http.MethodPatch || r.Method == http.MethodPut {
+			var u ganache.AssetUpdate
+			json.NewDecoder(r.Body).Decode(&u)
+			a.Caption = u.Caption
+			assets[id] = a
+			updates = append(updates, id)
+		}
+		json.NewEncoder(w).Encode(a)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, importRequest(sess, "captions.csv", "ID,Caption\n1,New caption\n2,kept\n3,Ghost\n"))
+	if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "/imports/") {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body.String())
+	}
+	location := rec.Header().Get("Location")
+
+	get := func(s auth.Session) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, location, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: s.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	body := get(sess).Body.String()
+	for _, want := range []string{"1 to change", "1 unchanged", "1 failed", "old caption", "New caption", "asset not found", "Apply 1 changes"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("preview missing %q: %s", want, body)
+		}
+	}
+	if len(updates) != 0 {
+		t.Fatalf("preview must not write: %v", updates)
+	}
+	other, _ := sessions.Create("someone")
+	if rec := get(other); rec.Code != http.StatusNotFound {
+		t.Fatalf("imports should be private, got %d", rec.Code)
+	}
+
+	apply := func() *httptest.ResponseRecorder {
+		form := url.Values{"csrf": {sess.CSRFToken}}
+		req := httptest.NewRequest(http.MethodPost, location+"/apply", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := apply(); rec.Code != http.StatusFound {
+		t.Fatalf("apply: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(updates) != 1 || updates[0] != "1" || assets["1"].Caption != "New caption" {
+		t.Fatalf("unexpected updates %v", updates)
+	}
+	if body := get(sess).Body.String(); !strings.Contains(body, "1 applied") || strings.Contains(body, "Apply 1 changes") {
+		t.Fatalf("report missing: %s", body)
+	}
+	if rec := apply(); rec.Code != http.StatusConflict {
+		t.Fatalf("second apply should conflict, got %d", rec.Code)
+	}
+	revs, _ := srv.revisions.List("1")
+	if len(revs) != 1 || revs[0].Note != "CSV import of captions.csv" {
+		t.Fatalf("import should be recorded in history: %+v", revs)
+	}
+}
+
+func TestImportRejectsBadFile(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, importRequest(sess, "bad.csv", "Title,Colour\nx,y\n"))
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "unknown column") {
+		t.Fatalf("expected 422 with message, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,95 @@
+package httpui
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/index"
+)
+
+type facetLink struct {
+	Value string
+	Count int
+	Href  string
+}
+
+type facetGroup struct {
+	Label string
+	Links []facetLink
+}
+
+// indexSync keeps the local index current: a full sync when the last one
+// is older than the configured interval, an incremental one otherwise.
+func (s *Server) indexSync() {
+	run := func() {
+		full := time.Since(s.index.Status().FullSyncAt) >= s.cfg.Index.FullSyncInterval
+		n, err := s.index.Sync(context.Background(), s.client, full)
+		if err != nil {
+			slog.Error("index: sync failed", "full", full, "assets", n, "error", err)
+		}
+		if err := s.index.Save(); err != nil {
+			slog.Error("index: save", "error", err)
+		}
+	}
+	run()
+	ticker := time.NewTicker(s.cfg.Index.SyncInterval)
+	for range ticker.C {
+		run()
+	}
+}
+
+// facetGroups turns index facets into links that narrow the current search
+// by adding the matching query term.
+func facetGroups(base, q string, tags []string, sort string, f index.Facets) []facetGroup {
+	link := func(term string) string {
+		v := url.Values{}
+		v.Set("q", strings.TrimSpace(q+" "+term))
+		for _, t := range tags {
+			v.Add("tag", t)
+		}
+		if sort != "" {
+			v.Set("sort", sort)
+		}
+		return base + "/assets?" + v.Encode()
+	}
+	field := func(label, key string, counts []index.Count) facetGroup {
+		g := facetGroup{Label: label}
+		for _, c := range counts {
+			term := key + `:"` + c.Value + `"`
+			if strings.Contains(c.Value, `"`) || strings.Contains(q, term) {
+				continue
+			}
+			g.Links = append(g.Links, facetLink{Value: c.Value, Count: c.Count, Href: link(term)})
+		}
+		return g
+	}
+	groups := []facetGroup{
+		field("Tags", "tag", f.Tags),
+		field("Credit", "credit", f.Credits),
+		field("Source", "source", f.Sources),
+	}
+	months := facetGroup{Label: "Created"}
+	for _, c := range f.Months {
+		start, err := time.Parse("2006-01", c.Value)
+		if err != nil {
+			continue
+		}
+		term := "after:" + start.Format("2006-01-02") + " before:" + start.AddDate(0, 1, 0).Format("2006-01-02")
+		if strings.Contains(q, term) {
+			continue
+		}
+		months.Links = append(months.Links, facetLink{Value: start.Format("Jan 2006"), Count: c.Count, Href: link(term)})
+	}
+	groups = append(groups, months)
+
+	var out []facetGroup
+	for _, g := range groups {
+		if len(g.Links) > 0 {
+			out = append(out, g)
+		}
+	}
+	return out
+}
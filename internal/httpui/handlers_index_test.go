@@ -0,0 +1,83 @@
+package httpui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/index"
+)
+
+func TestAssetsSearchUsesFreshIndex(t *testing.T) {
+	var searches int32
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/assets":
+			atomic.AddInt32(&searches, 1)
+			json.NewEncoder(w).Encode(ganache.SearchResponse{
+				Assets: []ganache.Asset{
+					{ID: "1", Title: "Championship final", Credit: "AP", Tags: []string{"football"}, CreatedAt: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+					{ID: "2", Title: "Harbour", Credit: "Reuters", Tags: []string{"sea"}, CreatedAt: time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC)},
+				},
+				Page: 1, PageSize: 100, Total: 2,
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/assets/2":
+			json.NewEncoder(w).Encode(ganache.Asset{ID: "2", Title: "Harbour", Tags: []string{"sea"}})
+		case r.Method == http.MethodPatch:
+			json.NewEncoder(w).Encode(ganache.Asset{ID: "2"})
+		}
+	})
+	srv.cfg.Index.MaxAge = time.Hour
+	ix, err := index.Open(filepath.Join(srv.cfg.DataDir, "index.json"))
+	if err != nil {
+		t.Fatalf("index: %v", err)
+	}
+	router := srv.Router()
+	srv.index = ix // after Router, so no background sync runs
+	sess, _ := sessions.Create("tester")
+	get := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	// Not yet synced: the search goes to Ganache.
+	get("/assets/results?q=harbour")
+	if atomic.LoadInt32(&searches) != 1 {
+		t.Fatalf("stale index should fall back to Ganache")
+	}
+
+	if _, err := ix.Sync(context.Background(), srv.client, true); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	before := atomic.LoadInt32(&searches)
+	body := get("/assets/results?q=champoinship")
+	if atomic.LoadInt32(&searches) != before {
+		t.Fatalf("fresh index should answer without Ganache")
+	}
+	if !strings.Contains(body, `href="/assets/1"`) || strings.Contains(body, `href="/assets/2"`) {
+		t.Fatalf("expected typo-tolerant match: %s", body)
+	}
+	if !strings.Contains(body, "Credit") || !strings.Contains(body, "credit%3A%22AP%22") {
+		t.Fatalf("expected credit facet link: %s", body)
+	}
+
+	form := "title=Harbour&tags=boats&csrf=" + sess.CSRFToken
+	req := httptest.NewRequest(http.MethodPost, "/assets/2/edit", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if body := get("/assets/results?q=tag:boats"); !strings.Contains(body, `href="/assets/2"`) {
+		t.Fatalf("expected edit reflected in index: %s", body)
+	}
+}
@@ -0,0 +1,344 @@
+package httpui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/tagpolicy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	jobKindUpload   = "upload"
+	jobKindBulkEdit = "bulk-edit"
+
+	maxBatchUploadSize = 1 << 30
+	sseHeartbeat       = 20 * time.Second
+)
+
+type uploadJobPayload struct {
+	Path     string            `json:"path"`
+	Filename string            `json:"filename"`
+	Fields   map[string]string `json:"fields"`
+	Tags     []string          `json:"tags"`
+	// Error marks an item that was rejected while staging, such as an
+	// unsafe entry in a ZIP import; it fails without being uploaded.
+	Error string `json:"error,omitempty"`
+}
+
+type bulkEditPayload struct {
+	AddTags    []string `json:"addTags,omitempty"`
+	RemoveTags []string `json:"removeTags,omitempty"`
+	Credit     string   `json:"credit,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	UsageNotes string   `json:"usageNotes,omitempty"`
+}
+
+type jobEvent struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Percent   int    `json:"percent"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Total     int    `json:"total"`
+}
+
+func (s *Server) registerJobs() {
+	s.jobs.Register(jobKindUpload, s.runUploadItem, s.cleanupUploadJob)
+	s.jobs.Register(jobKindBulkEdit, s.runBulkEditItem, nil)
+	s.jobs.Register(jobKindCopy, s.runCopyItem, nil)
+}
+
+func (s *Server) jobsIndex(w http.ResponseWriter, r *http.Request) {
+	s.templates.Render(w, "jobs.html", TemplateData{
+		Title: "Jobs",
+		Extra: map[string]any{"jobs": s.jobs.List(currentUser(r))},
+	}, r)
+}
+
+// jobsEvents streams progress for the user's jobs as Server-Sent Events.
+func (s *Server) jobsEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	user := currentUser(r)
+	updates, cancel := s.jobs.Subscribe()
+	defer cancel()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case job := <-updates:
+			if job.Owner != user {
+				continue
+			}
+			p := job.Progress()
+			data, _ := json.Marshal(jobEvent{
+				ID:        job.ID,
+				Status:    string(job.Status),
+				Percent:   p.Percent(),
+				Succeeded: p.Succeeded,
+				Failed:    p.Failed,
+				Total:     p.Total,
+			})
+			if _, err := fmt.Fprintf(w, "event: job\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) jobRetry(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(chi.URLParam(r, "id"))
+	if !ok || job.Owner != currentUser(r) {
+		http.NotFound(w, r)
+		return
+	}
+	if err := s.jobs.Retry(job.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+// jobsUpload stages every submitted file on disk and queues one upload per
+// file, sharing the submitted metadata.
+func (s *Server) jobsUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+
+	fields := formFields(r)
+	tags := parseTags(r)
+
+	var items []jobs.Item
+	for _, fh := range files {
+		path, err := s.stageJobFile(fh)
+		if err != nil {
+			for _, it := range items {
+				removeStagedUpload(it)
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		itemFields := make(map[string]string, len(fields))
+		for k, v := range fields {
+			itemFields[k] = v
+		}
+		if itemFields["title"] == "" {
+			itemFields["title"] = strings.TrimSuffix(fh.Filename, filepath.Ext(fh.Filename))
+		}
+		payload, _ := json.Marshal(uploadJobPayload{Path: path, Filename: fh.Filename, Fields: itemFields, Tags: tags})
+		items = append(items, jobs.Item{Key: fh.Filename, Payload: payload})
+	}
+
+	title := fmt.Sprintf("Upload %d files", len(items))
+	if len(items) == 1 {
+		title = "Upload " + items[0].Key
+	}
+	if _, err := s.jobs.Enqueue(jobKindUpload, currentUser(r), title, items); err != nil {
+		for _, it := range items {
+			removeStagedUpload(it)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+func (s *Server) stageJobFile(fh *multipart.FileHeader) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return s.stageJobReader(src)
+}
+
+// stageJobReader copies src to a new file under the backend's job-files.
+// The name is always generated, never taken from the upload.
+func (s *Server) stageJobReader(src io.Reader) (string, error) {
+	if err := os.MkdirAll(s.jobFiles, 0o700); err != nil {
+		return "", err
+	}
+	dst, err := os.CreateTemp(s.jobFiles, "upload-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), dst.Close()
+}
+
+func (s *Server) jobsBulkEdit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var ids []string
+	for _, id := range r.Form["ids"] {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "select at least one asset", http.StatusBadRequest)
+		return
+	}
+	edit := bulkEditPayload{
+		AddTags:    tagpolicy.Split(r.FormValue("addTags")),
+		RemoveTags: tagpolicy.Split(r.FormValue("removeTags")),
+		Credit:     strings.TrimSpace(r.FormValue("credit")),
+		Source:     strings.TrimSpace(r.FormValue("source")),
+		UsageNotes: strings.TrimSpace(r.FormValue("usageNotes")),
+	}
+	if len(edit.AddTags) == 0 && len(edit.RemoveTags) == 0 && edit.Credit == "" && edit.Source == "" && edit.UsageNotes == "" {
+		http.Error(w, "nothing to change", http.StatusBadRequest)
+		return
+	}
+	payload, _ := json.Marshal(edit)
+	items := make([]jobs.Item, len(ids))
+	for i, id := range ids {
+		items[i] = jobs.Item{Key: id, Payload: payload}
+	}
+	title := fmt.Sprintf("Bulk edit %d assets", len(ids))
+	if _, err := s.jobs.Enqueue(jobKindBulkEdit, currentUser(r), title, items); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+func (s *Server) runUploadItem(ctx context.Context, job jobs.Job, item jobs.Item) (string, error) {
+	var p uploadJobPayload
+	if err := json.Unmarshal(item.Payload, &p); err != nil {
+		return "", jobs.Permanent(err)
+	}
+	if p.Error != "" {
+		return "", jobs.Permanent(errors.New(p.Error))
+	}
+	if item.Interrupted {
+		return "", jobs.Permanent(errors.New("interrupted by a restart; not retried, as Ganache may have created the asset"))
+	}
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return "", jobs.Permanent(fmt.Errorf("staged file missing: %w", err))
+	}
+	defer file.Close()
+
+	if _, err := media.Validate(file, s.uploadLimits()); err != nil {
+		return "", jobs.Permanent(err)
+	}
+	if err := s.scanUpload(ctx, job.Owner, file, p.Filename); err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			return "", jobs.Permanent(err)
+		}
+		return "", err
+	}
+	asset, err := s.createAsset(ctx, file, p.Filename, p.Fields, p.Tags)
+	if err != nil {
+		var verr *media.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			return "", jobs.Permanent(err)
+		case ganache.IsUnreachable(err):
+			// Nothing was sent, so the upload is tried again.
+			return "", err
+		}
+		// Once the file has been sent, a failure such as a timeout does not
+		// mean Ganache rejected it, and retrying could create a duplicate.
+		// The item fails instead, for the owner to check and retry by hand.
+		return "", jobs.Permanent(fmt.Errorf("%w (not retried, as Ganache may have created the asset)", err))
+	}
+	file.Close()
+	if err := os.Remove(p.Path); err != nil {
+		slog.Warn("jobs: remove staged upload", "path", p.Path, "error", err)
+	}
+	return string(asset.ID), nil
+}
+
+func (s *Server) cleanupUploadJob(job jobs.Job) {
+	for _, it := range job.Items {
+		removeStagedUpload(it)
+	}
+}
+
+func removeStagedUpload(it jobs.Item) {
+	var p uploadJobPayload
+	if json.Unmarshal(it.Payload, &p) == nil && p.Path != "" {
+		if err := os.Remove(p.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("jobs: remove staged upload", "path", p.Path, "error", err)
+		}
+	}
+}
+
+func (s *Server) runBulkEditItem(ctx context.Context, job jobs.Job, item jobs.Item) (string, error) {
+	var p bulkEditPayload
+	if err := json.Unmarshal(item.Payload, &p); err != nil {
+		return "", jobs.Permanent(err)
+	}
+	asset, err := s.client.GetAsset(ctx, item.Key)
+	if err != nil {
+		return "", err
+	}
+	update := asset.AsUpdate()
+	update.Tags = tagpolicy.Edit(update.Tags, p.AddTags, p.RemoveTags)
+	if p.Credit != "" {
+		update.Credit = p.Credit
+	}
+	if p.Source != "" {
+		update.Source = p.Source
+	}
+	if p.UsageNotes != "" {
+		update.UsageNotes = p.UsageNotes
+	}
+	if _, err := s.updateAsset(ctx, job.Owner, job.Title, asset, update); err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			return "", jobs.Permanent(err)
+		}
+		return "", err
+	}
+	return "updated", nil
+}
@@ -0,0 +1,259 @@
+package httpui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+)
+
+func waitForJob(t *testing.T, srv *Server, user string, want jobs.Status) jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if list := srv.jobs.List(user); len(list) > 0 && list[0].Status == want {
+			return list[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job for %s did not reach %s: %+v", user, want, srv.jobs.List(user))
+	return jobs.Job{}
+}
+
+func TestJobsBulkEditUpdatesSelectedAssets(t *testing.T) {
+	var mu sync.Mutex
+	updates := map[string]ganache.AssetUpdate{}
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(ganache.Asset{ID: ganache.StringID(id), Title: "T" + id, Credit: "Old", Tags: []string{"soccer", "keep"}})
+		case http.MethodPatch:
+			var u ganache.AssetUpdate
+			json.NewDecoder(r.Body).Decode(&u)
+			mu.Lock()
+			updates[id] = u
+			mu.Unlock()
+			json.NewEncoder(w).Encode(ganache.Asset{ID: ganache.StringID(id)})
+		}
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	form := "ids=1&ids=2&addTags=football&removeTags=soccer&credit=AP&csrf=" + sess.CSRFToken
+	req := httptest.NewRequest(http.MethodPost, "/jobs/bulk-edit", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/jobs" {
+		t.Fatalf("expected redirect to /jobs, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range []string{"1", "2"} {
+		u := updates[id]
+		if u.Title != "T"+id || u.Credit != "AP" || strings.Join(u.Tags, ",") != "keep,football" {
+			t.Fatalf("asset %s update wrong: %+v", id, u)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "Bulk edit 2 assets") {
+		t.Fatalf("expected job listed on /jobs")
+	}
+}
+
+func TestJobsUploadQueuesEachFile(t *testing.T) {
+	var mu sync.Mutex
+	var titles []string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		titles = append(titles, r.FormValue("title"))
+		mu.Unlock()
+		io.WriteString(w, `{"id":"new"}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, name := range []string{"one.png", "two.png"} {
+		fw, _ := writer.CreateFormFile("file", name)
+		fw.Write(testPNG(t))
+	}
+	bad, _ := writer.CreateFormFile("file", "three.png")
+	bad.Write([]byte("plain text"))
+	writer.WriteField("credit", "AP")
+	writer.WriteField("csrf", sess.CSRFToken)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/jobs/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	job := waitForJob(t, srv, "tester", jobs.StatusFailed)
+	p := job.Progress()
+	if p.Succeeded != 2 || p.Failed != 1 {
+		t.Fatalf("expected 2 uploads and 1 rejection, got %+v", p)
+	}
+	if job.Items[2].Attempts != 1 || !strings.Contains(job.Items[2].Error, "not allowed") {
+		t.Fatalf("invalid file should fail permanently: %+v", job.Items[2])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(titles) != 2 || titles[0] == "" {
+		t.Fatalf("expected titles derived from filenames, got %v", titles)
+	}
+}
+
+func TestJobsEventsStreamsProgress(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.Asset{ID: "1"})
+	})
+	ts := httptest.NewServer(srv.Router())
+	t.Cleanup(ts.Close)
+	sess, _ := sessions.Create("tester")
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/jobs/events", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	payload, _ := json.Marshal(bulkEditPayload{AddTags: []string{"x"}})
+	if _, err := srv.jobs.Enqueue(jobKindBulkEdit, "tester", "stream", []jobs.Item{{Key: "1", Payload: payload}}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				lines <- string(buf[:n])
+			}
+			if err != nil {
+				close(lines)
+				return
+			}
+		}
+	}()
+	timeout := time.After(5 * time.Second)
+	var seen strings.Builder
+	for {
+		select {
+		case chunk, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed early: %s", seen.String())
+			}
+			seen.WriteString(chunk)
+			if strings.Contains(seen.String(), `"status":"succeeded"`) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no completion event: %s", seen.String())
+		}
+	}
+}
+
+func TestJobsUploadStagesPerBackendAndIsNotRetried(t *testing.T) {
+	users, _ := auth.NewUserStore([]auth.User{{Username: "tester", PasswordHash: "hash"}})
+	var mu sync.Mutex
+	var creates int
+	srv, sessions := newBackendsServer(t, users, map[string]http.HandlerFunc{
+		"production": func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			creates++
+			mu.Unlock()
+			// Ganache may have stored the file before the gateway gave up.
+			http.Error(w, "upstream timed out", http.StatusGatewayTimeout)
+		},
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	production := srv.lookup("production")
+	if want := filepath.Join(srv.cfg.DataDir, "backends", "production", "job-files"); production.jobFiles != want {
+		t.Fatalf("expected files staged in %s, got %s", want, production.jobFiles)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fw, _ := writer.CreateFormFile("file", "one.png")
+	fw.Write(testPNG(t))
+	writer.WriteField("csrf", sess.CSRFToken)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/b/production/jobs/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	job := waitForJob(t, production, "tester", jobs.StatusFailed)
+	if job.Items[0].Attempts != 1 || !strings.Contains(job.Items[0].Error, "not retried") {
+		t.Fatalf("a failed create should not be retried: %+v", job.Items[0])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if creates != 1 {
+		t.Fatalf("expected one create, got %d", creates)
+	}
+}
+
+func TestJobsUploadRetriesOnlyWhenNothingWasSent(t *testing.T) {
+	srv, _ := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.Asset{ID: "1"})
+	})
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	srv.client = ganache.NewClient(down.URL, "key", time.Second)
+	item := func(interrupted bool) jobs.Item {
+		path, err := srv.stageJobReader(bytes.NewReader(testPNG(t)))
+		if err != nil {
+			t.Fatalf("stage: %v", err)
+		}
+		payload, _ := json.Marshal(uploadJobPayload{Path: path, Filename: "one.png"})
+		return jobs.Item{Key: "one.png", Payload: payload, Interrupted: interrupted}
+	}
+	job := jobs.Job{Owner: "tester"}
+
+	_, err := srv.runUploadItem(context.Background(), job, item(false))
+	if err == nil || !ganache.IsUnreachable(err) || strings.Contains(err.Error(), "not retried") {
+		t.Fatalf("expected a retryable error while Ganache is down, got %v", err)
+	}
+	_, err = srv.runUploadItem(context.Background(), job, item(true))
+	if err == nil || !strings.Contains(err.Error(), "not retried") {
+		t.Fatalf("an upload interrupted by a restart should not run again, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package httpui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/migrate"
+)
+
+const jobKindCopy = "copy"
+
+// copyPayload is shared by every item of a copy job; the item key is the
+// source asset ID.
+type copyPayload struct {
+	Target string `json:"target"`
+	Sync   bool   `json:"sync,omitempty"`
+}
+
+// assetCopy is a copy of an asset on another backend, for the asset page.
+type assetCopy struct {
+	Label string
+	Href  string
+}
+
+// canWrite reports whether user may change assets on this backend.
+func (s *Server) canWrite(user string) bool {
+	role := s.users.RoleOn(user, s.backend.Name)
+	return role == auth.RoleAdmin || role == auth.RoleEditor
+}
+
+// copyTargets lists the other backends user may copy assets to.
+func (s *Server) copyTargets(user string) []BackendOption {
+	var targets []BackendOption
+	for _, b := range s.backends {
+		if b != s && b.canWrite(user) {
+			targets = append(targets, BackendOption{Name: b.backend.Name, Label: b.backend.Label})
+		}
+	}
+	return targets
+}
+
+// migrations returns the mapping of the assets copied from this backend
+// to target. Stores are opened once, as copy jobs run concurrently.
+func (s *Server) migrations(target string) (*migrate.Store, error) {
+	s.migrationsMu.Lock()
+	defer s.migrationsMu.Unlock()
+	if st, ok := s.migrationStores[target]; ok {
+		return st, nil
+	}
+	st, err := migrate.NewStore(migrate.Path(s.cfg.DataDir, s.backend.Name, target))
+	if err != nil {
+		return nil, err
+	}
+	if s.migrationStores == nil {
+		s.migrationStores = map[string]*migrate.Store{}
+	}
+	s.migrationStores[target] = st
+	return st, nil
+}
+
+// copier copies from this backend to target on behalf of user. The target
+// is written like any other change made in the UI, so its tag policy,
+// index and revision history apply.
+func (s *Server) copier(target *Server, user string) (*migrate.Copier, error) {
+	st, err := s.migrations(target.backend.Name)
+	if err != nil {
+		return nil, err
+	}
+	note := "Synced from " + s.backend.Label
+	return &migrate.Copier{
+		Get:       s.client.GetAsset,
+		Download:  s.client.Download,
+		GetTarget: target.client.GetAsset,
+		Create: func(ctx context.Context, file io.Reader, filename string, meta ganache.AssetUpdate) (ganache.Asset, error) {
+			return target.createAsset(ctx, file, filename, map[string]string{
+				"title":      meta.Title,
+				"caption":    meta.Caption,
+				"credit":     meta.Credit,
+				"source":     meta.Source,
+				"usageNotes": meta.UsageNotes,
+			}, meta.Tags)
+		},
+		Update: func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+			_, err := target.updateAsset(ctx, user, note, before, after)
+			return err
+		},
+		Check: target.checkTags,
+		Map:   st,
+		User:  user,
+	}, nil
+}
+
+// assetCopies lists the copies of an asset on the other backends.
+func (s *Server) assetCopies(id string) []assetCopy {
+	var copies []assetCopy
+	for _, b := range s.backends {
+		if b == s {
+			continue
+		}
+		st, err := s.migrations(b.backend.Name)
+		if err != nil {
+			continue
+		}
+		if m, ok := st.Get(id); ok {
+			copies = append(copies, assetCopy{Label: b.backend.Label, Href: b.path("/assets/" + m.TargetID)})
+		}
+	}
+	return copies
+}
+
+// jobsCopy queues a copy of the selected assets to another backend.
+func (s *Server) jobsCopy(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var ids []string
+	for _, id := range r.Form["ids"] {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "select at least one asset", http.StatusBadRequest)
+		return
+	}
+	target := s.lookup(r.FormValue("target"))
+	if target == nil || target == s || !target.canWrite(currentUser(r)) {
+		http.Error(w, "choose a backend you can upload to", http.StatusBadRequest)
+		return
+	}
+	payload, _ := json.Marshal(copyPayload{Target: target.backend.Name, Sync: r.FormValue("sync") != ""})
+	items := make([]jobs.Item, len(ids))
+	for i, id := range ids {
+		items[i] = jobs.Item{Key: id, Payload: payload}
+	}
+	title := fmt.Sprintf("Copy %d assets to %s", len(ids), target.backend.Label)
+	if len(ids) == 1 {
+		title = fmt.Sprintf("Copy %s to %s", ids[0], target.backend.Label)
+	}
+	if _, err := s.jobs.Enqueue(jobKindCopy, currentUser(r), title, items); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+func (s *Server) runCopyItem(ctx context.Context, job jobs.Job, item jobs.Item) (string, error) {
+	var p copyPayload
+	if err := json.Unmarshal(item.Payload, &p); err != nil {
+		return "", jobs.Permanent(err)
+	}
+	target := s.lookup(p.Target)
+	if target == nil || !target.canWrite(job.Owner) {
+		return "", jobs.Permanent(fmt.Errorf("cannot copy to backend %q", p.Target))
+	}
+	c, err := s.copier(target, job.Owner)
+	if err != nil {
+		return "", err
+	}
+	res := c.Copy(ctx, item.Key, migrate.Options{Sync: p.Sync})
+	if res.Status == migrate.Failed {
+		// A copy the target may have received is not retried, as that
+		// could copy the asset twice; nor is one the target refused.
+		var verr *media.ValidationError
+		if res.Uploaded || errors.As(res.Cause, &verr) {
+			return "", jobs.Permanent(res.Cause)
+		}
+		return "", res.Cause
+	}
+	return fmt.Sprintf("%s: %s %s", res.Status, target.backend.Label, res.TargetID), nil
+}
@@ -0,0 +1,132 @@
+package httpui
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+)
+
+func TestCopyToAnotherBackend(t *testing.T) {
+	var mu sync.Mutex
+	source := ganache.Asset{ID: "7", Title: "Kickoff", Credit: "AP", Tags: []string{"cup"}}
+	source.Variants.Original = "/files/7.jpg"
+	var uploads []string
+	copies := map[string]ganache.Asset{}
+	handlers := map[string]http.HandlerFunc{
+		"staging": func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.URL.Path == "/files/7.jpg" {
+				io.WriteString(w, "pixels")
+				return
+			}
+			json.NewEncoder(w).Encode(source)
+		},
+		"production": func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+			switch r.Method {
+			case http.MethodPost:
+				file, header, err := r.FormFile("file")
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				data, _ := io.ReadAll(file)
+				uploads = append(uploads, header.Filename+":"+string(data))
+				a := ganache.Asset{ID: "p1", Title: r.FormValue("title"), Credit: r.FormValue("credit"), Tags: r.Form["tags[]"]}
+				copies["p1"] = a
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(a)
+			case http.MethodPatch:
+				var u ganache.AssetUpdate
+				json.NewDecoder(r.Body).Decode(&u)
+				a := copies[id]
+				a.Title, a.Caption, a.Credit, a.Tags = u.Title, u.Caption, u.Credit, u.Tags
+				copies[id] = a
+				json.NewEncoder(w).Encode(a)
+			default:
+				a, ok := copies[id]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewEncoder(w).Encode(a)
+			}
+		},
+	}
+	users, _ := auth.NewUserStore([]auth.User{
+		{Username: "tester", PasswordHash: "hash"},
+		{Username: "reader", PasswordHash: "hash", Backends: map[string]string{"production": auth.RoleViewer}},
+	})
+	srv, sessions := newBackendsServer(t, users, handlers)
+	router := srv.Router()
+	tester, _ := sessions.Create("tester")
+	reader, _ := sessions.Create("reader")
+	copyTo := func(sess auth.Session, target string, sync bool) *httptest.ResponseRecorder {
+		form := url.Values{"csrf": {sess.CSRFToken}, "ids": {"7"}, "target": {target}}
+		if sync {
+			form.Set("sync", "1")
+		}
+		req := httptest.NewRequest(http.MethodPost, "/b/staging/jobs/copy", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := copyTo(reader, "production", false); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected a viewer of the target to be refused, got %d", rec.Code)
+	}
+	if rec := copyTo(tester, "production", false); rec.Code != http.StatusFound {
+		t.Fatalf("expected the copy to be queued, got %d: %s", rec.Code, rec.Body.String())
+	}
+	job := waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	if job.Items[0].Result != "copied: PRODUCTION p1" {
+		t.Fatalf("unexpected result %q", job.Items[0].Result)
+	}
+	mu.Lock()
+	if len(uploads) != 1 || uploads[0] != "7.jpg:pixels" || copies["p1"].Credit != "AP" {
+		t.Fatalf("expected the original uploaded with its metadata, got %v %+v", uploads, copies["p1"])
+	}
+	mu.Unlock()
+
+	req := httptest.NewRequest(http.MethodGet, "/b/staging/assets/7", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: tester.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `href="/b/production/assets/p1"`) {
+		t.Fatal("expected the asset page to link to the copy")
+	}
+
+	mu.Lock()
+	source.Caption = "At noon"
+	mu.Unlock()
+	copyTo(tester, "production", false)
+	job = waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	if job.Items[0].Result != "already copied: PRODUCTION p1" {
+		t.Fatalf("expected copying again to be skipped, got %q", job.Items[0].Result)
+	}
+	copyTo(tester, "production", true)
+	job = waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	mu.Lock()
+	defer mu.Unlock()
+	if job.Items[0].Result != "synced: PRODUCTION p1" || copies["p1"].Caption != "At noon" || len(uploads) != 1 {
+		t.Fatalf("expected the caption synced without a new upload, got %q %+v", job.Items[0].Result, copies["p1"])
+	}
+	revs, _ := srv.lookup("production").revisions.List("p1")
+	if len(revs) != 1 || revs[0].Note != "Synced from STAGING" {
+		t.Fatalf("expected the sync in the target's history, got %+v", revs)
+	}
+}
@@ -0,0 +1,62 @@
+package httpui
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/revisions"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// assetHistory renders the History tab of the detail page.
+func (s *Server) assetHistory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	list, err := s.revisions.List(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.templates.Render(w, "asset_history_partial.html", TemplateData{
+		Extra: map[string]any{"assetID": id, "revisions": list},
+	}, r)
+}
+
+// assetRevert writes a revision's previous metadata back to Ganache. The
+// revert is itself recorded, so it can be undone the same way.
+func (s *Server) assetRevert(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	rev, err := s.revisions.Get(id, chi.URLParam(r, "rev"))
+	if errors.Is(err, revisions.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current, err := s.client.GetAsset(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	note := fmt.Sprintf("Reverted %s's change of %s", rev.User, rev.Time.Local().Format("2006-01-02 15:04"))
+	if _, err := s.updateAsset(r.Context(), currentUser(r), note, current, rev.Before); err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	target := s.path("/assets/" + id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
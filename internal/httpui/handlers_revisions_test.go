@@ -0,0 +1,80 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestAssetHistoryAndRevert(t *testing.T) {
+	var mu sync.Mutex
+	current := ganache.Asset{ID: "7", Title: "Derby", Caption: "Old caption", Tags: []string{"football"}}
+	var patches []ganache.AssetUpdate
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPatch {
+			var update ganache.AssetUpdate
+			json.NewDecoder(r.Body).Decode(&update)
+			patches = append(patches, update)
+			current.Title, current.Caption, current.Tags = update.Title, update.Caption, update.Tags
+		}
+		json.NewEncoder(w).Encode(current)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	do := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			form.Set("csrf", sess.CSRFToken)
+			req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	do(http.MethodPost, "/assets/7/edit", url.Values{"title": {"Derby"}, "caption": {"New caption"}, "tags": {"football, derby"}})
+
+	body := do(http.MethodGet, "/assets/7/history", nil).Body.String()
+	for _, want := range []string{"tester", "<del>Old caption</del>", "<ins>New caption</ins>", `diff-added">derby`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("history missing %q: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "<th>Title</th>") {
+		t.Fatalf("unchanged title listed in diff")
+	}
+
+	list, _ := srv.revisions.List("7")
+	if len(list) != 1 {
+		t.Fatalf("expected one revision, got %d", len(list))
+	}
+	rec := do(http.MethodPost, "/assets/7/revisions/"+list[0].ID+"/revert", url.Values{})
+	if rec.Header().Get("HX-Redirect") != "/assets/7" {
+		t.Fatalf("unexpected revert response %d %q", rec.Code, rec.Body.String())
+	}
+	last := patches[len(patches)-1]
+	if last.Caption != "Old caption" || len(last.Tags) != 1 || last.Tags[0] != "football" {
+		t.Fatalf("revert sent %+v", last)
+	}
+	list, _ = srv.revisions.List("7")
+	if len(list) != 2 || !strings.HasPrefix(list[0].Note, "Reverted tester's change") {
+		t.Fatalf("revert should be recorded: %+v", list)
+	}
+
+	if rec := do(http.MethodPost, "/assets/7/revisions/nope/revert", url.Values{}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown revision, got %d", rec.Code)
+	}
+}
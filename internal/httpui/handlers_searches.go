@@ -0,0 +1,175 @@
+package httpui
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/searches"
+	"ganache-admin-ui/internal/tagpolicy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newSinceWindow caps how many of the newest results are inspected when
+// counting assets added since the last visit.
+const newSinceWindow = 100
+
+func (s *Server) searchesIndex(w http.ResponseWriter, r *http.Request) {
+	s.templates.Render(w, "searches.html", TemplateData{
+		Title: "Saved searches",
+		Extra: map[string]any{"searches": s.searches.List(currentUser(r))},
+	}, r)
+}
+
+func (s *Server) searchCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	saved, err := s.searches.Create(searches.Search{
+		Owner:     currentUser(r),
+		Name:      r.FormValue("name"),
+		Query:     strings.TrimSpace(r.FormValue("q")),
+		Tags:      tagpolicy.Split(r.Form["tag"]...),
+		Sort:      r.FormValue("sort"),
+		Pinned:    r.FormValue("pinned") != "",
+		NotifyNew: r.FormValue("notifyNew") != "",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target := s.path("/searches/" + saved.ID)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// searchOpen runs a saved search. Any signed-in user may open a search,
+// which is how searches are shared; only the owner's visit is recorded.
+func (s *Server) searchOpen(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.searches.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if saved.Owner == currentUser(r) {
+		if err := s.searches.MarkVisited(saved.Owner, saved.ID, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	values := saved.Values()
+	values.Set("saved", saved.ID)
+	http.Redirect(w, r, s.path("/assets?"+values.Encode()), http.StatusFound)
+}
+
+func (s *Server) searchUpdate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	_, err := s.searches.Update(currentUser(r), chi.URLParam(r, "id"), func(sr *searches.Search) {
+		if name := r.FormValue("name"); name != "" {
+			sr.Name = name
+		}
+		sr.Pinned = r.FormValue("pinned") != ""
+		sr.NotifyNew = r.FormValue("notifyNew") != ""
+	})
+	if !s.searchWriteOK(w, r, err) {
+		return
+	}
+	http.Redirect(w, r, s.path("/searches"), http.StatusFound)
+}
+
+func (s *Server) searchDelete(w http.ResponseWriter, r *http.Request) {
+	err := s.searches.Delete(currentUser(r), chi.URLParam(r, "id"))
+	if !s.searchWriteOK(w, r, err) {
+		return
+	}
+	http.Redirect(w, r, s.path("/searches"), http.StatusFound)
+}
+
+// searchCopy saves a shared search into the current user's own list.
+func (s *Server) searchCopy(w http.ResponseWriter, r *http.Request) {
+	shared, err := s.searches.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	shared.Owner = currentUser(r)
+	shared.Pinned = true
+	saved, err := s.searches.Create(shared)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/searches/"+saved.ID), http.StatusFound)
+}
+
+// searchNewCount renders the "new since last visit" badge for the sidebar.
+func (s *Server) searchNewCount(w http.ResponseWriter, r *http.Request) {
+	saved, err := s.searches.Get(chi.URLParam(r, "id"))
+	if err != nil || saved.Owner != currentUser(r) || !saved.NotifyNew {
+		return
+	}
+	parsed, err := query.Parse(saved.Query)
+	if err != nil {
+		return
+	}
+	parsed.Tags = append(parsed.Tags, saved.Tags...)
+	parsed.Sort = "newest"
+	parsed.ExcludeIDs = s.trash.IDs()
+	res, err := query.Search(r.Context(), s.client, parsed, 1, newSinceWindow, s.cfg.Search.ScanLimit)
+	if err != nil {
+		return
+	}
+	count := 0
+	for _, a := range res.Assets {
+		if a.CreatedAt.After(saved.LastVisitedAt) {
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	label := fmt.Sprintf("%d", count)
+	if count == len(res.Assets) && res.HasNext {
+		label += "+"
+	}
+	fmt.Fprintf(w, `<span class="badge" title="new since last visit">%s</span>`, label)
+}
+
+func (s *Server) searchWriteOK(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, searches.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, searches.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		return true
+	}
+	return false
+}
+
+// savedSearchBanner describes the saved search the index was opened from.
+func (s *Server) savedSearchBanner(r *http.Request) map[string]any {
+	id := r.URL.Query().Get("saved")
+	if id == "" {
+		return nil
+	}
+	saved, err := s.searches.Get(id)
+	if err != nil {
+		return nil
+	}
+	return map[string]any{"search": saved, "owned": saved.Owner == currentUser(r)}
+}
@@ -0,0 +1,114 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/searches"
+)
+
+func TestSavedSearchSaveOpenAndPin(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.SearchResponse{Page: 1, PageSize: 20})
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	form := "name=Football&q=final&tag=football&sort=newest&pinned=1&csrf=" + sess.CSRFToken
+	req := httptest.NewRequest(http.MethodPost, "/searches", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	target := rec.Header().Get("HX-Redirect")
+	if !strings.HasPrefix(target, "/searches/") {
+		t.Fatalf("expected HX-Redirect to saved search, got %d %q", rec.Code, target)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	loc := rec.Header().Get("Location")
+	if rec.Code != http.StatusFound || !strings.Contains(loc, "q=final") || !strings.Contains(loc, "tag=football") || !strings.Contains(loc, "saved=") {
+		t.Fatalf("unexpected redirect %d %q", rec.Code, loc)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, loc, nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "Pinned searches") || !strings.Contains(body, `href="`+target+`"`) {
+		t.Fatalf("expected pinned search in sidebar")
+	}
+	if !strings.Contains(body, "Saved search <strong>Football</strong>") {
+		t.Fatalf("expected saved search banner")
+	}
+}
+
+func TestSavedSearchSharedWithOtherUsers(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.SearchResponse{Page: 1, PageSize: 20})
+	})
+	router := srv.Router()
+	owned, _ := srv.searches.Create(searches.Search{Owner: "alice", Name: "Alice's", Query: "cup"})
+	sess, _ := sessions.Create("tester")
+
+	req := httptest.NewRequest(http.MethodPost, "/searches/"+owned.ID+"/delete", strings.NewReader("csrf="+sess.CSRFToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected forbidden, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/searches/"+owned.ID+"/copy", strings.NewReader("csrf="+sess.CSRFToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect after copy, got %d", rec.Code)
+	}
+	mine := srv.searches.List("tester")
+	if len(mine) != 1 || mine[0].Query != "cup" || mine[0].ID == owned.ID {
+		t.Fatalf("expected a copy owned by tester, got %+v", mine)
+	}
+	if orig, _ := srv.searches.Get(owned.ID); !orig.LastVisitedAt.Equal(owned.LastVisitedAt) {
+		t.Fatalf("opening a shared search must not touch the owner's visit time")
+	}
+}
+
+func TestSavedSearchNewCount(t *testing.T) {
+	now := time.Now()
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.SearchResponse{
+			Assets: []ganache.Asset{
+				{ID: "3", CreatedAt: now.Add(time.Hour)},
+				{ID: "2", CreatedAt: now.Add(time.Minute)},
+				{ID: "1", CreatedAt: now.Add(-time.Hour)},
+			},
+			Total: 3, Page: 1, PageSize: newSinceWindow,
+		})
+	})
+	router := srv.Router()
+	s, _ := srv.searches.Create(searches.Search{Owner: "tester", Name: "watch", NotifyNew: true})
+	srv.searches.MarkVisited("tester", s.ID, now)
+	sess, _ := sessions.Create("tester")
+
+	req := httptest.NewRequest(http.MethodGet, "/searches/"+s.ID+"/new-count", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), ">2</span>") {
+		t.Fatalf("expected two new assets, got %q", rec.Body.String())
+	}
+}
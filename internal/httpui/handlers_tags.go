@@ -1,12 +1,68 @@
 package httpui
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+
+	"ganache-admin-ui/internal/audit"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/query"
+	"ganache-admin-ui/internal/tagpolicy"
+)
+
+const (
+	tagsPageSize       = 50
+	tagPreviewSample   = 50
+	tagCountConcurrent = 4
 )
 
+// tagChange describes a rename, merge or delete. Rename is a merge with a
+// single source tag; delete has no target.
+type tagChange struct {
+	Action string
+	From   []string
+	To     string
+}
+
+func (c tagChange) String() string {
+	from := `"` + strings.Join(c.From, `", "`) + `"`
+	switch c.Action {
+	case "rename":
+		return fmt.Sprintf("Rename tag %s to %q", from, c.To)
+	case "merge":
+		return fmt.Sprintf("Merge tags %s into %q", from, c.To)
+	default:
+		return "Delete tag " + from
+	}
+}
+
+type tagPreviewRow struct {
+	ID     string
+	Title  string
+	Before []string
+	After  []string
+}
+
+type tagRow struct {
+	Name  string
+	Count int
+}
+
+// tagsList serves the management page, or autocomplete suggestions for
+// HTMX requests from the metadata form.
 func (s *Server) tagsList(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") != "true" {
+		s.tagsIndex(w, r, nil)
+		return
+	}
 	prefix := r.URL.Query().Get("prefix")
 	if prefix == "" {
 		prefix = r.URL.Query().Get("tags")
@@ -18,8 +74,257 @@ func (s *Server) tagsList(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	for _, tag := range resp.Tags {
-		name := html.EscapeString(tag.Name)
+	for _, name := range s.tagSuggestions(prefix, resp.Tags, pageSize) {
+		name = html.EscapeString(name)
 		fmt.Fprintf(w, "<button type=\"button\" class=\"tag-suggestion\" data-tag=\"%s\">%s</button>", name, name)
 	}
 }
+
+// tagSuggestions puts vocabulary terms matching prefix first, then the
+// Ganache tags, with aliases replaced by their canonical name.
+func (s *Server) tagSuggestions(prefix string, tags []ganache.Tag, limit int) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	add := func(name string) {
+		key := strings.ToLower(name)
+		if _, ok := seen[key]; ok || len(out) == limit {
+			return
+		}
+		seen[key] = struct{}{}
+		out = append(out, name)
+	}
+	for _, name := range s.policy.Suggest(prefix, limit) {
+		add(name)
+	}
+	for _, tag := range tags {
+		if name, ok := s.policy.Canonical(tag.Name); ok {
+			add(name)
+			continue
+		}
+		add(tag.Name)
+	}
+	return out
+}
+
+func (s *Server) tagsIndex(w http.ResponseWriter, r *http.Request, extra map[string]any) {
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	resp, err := s.client.ListTags(r.Context(), prefix, page, tagsPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if extra == nil {
+		extra = map[string]any{}
+	}
+	extra["tags"] = s.tagUsage(r.Context(), resp.Tags)
+	extra["prefix"] = prefix
+	extra["page"] = page
+	extra["prevPage"] = page - 1
+	extra["nextPage"] = page + 1
+	extra["hasNext"] = len(resp.Tags) == tagsPageSize
+	entries, err := s.audit.Recent("tag.", 20)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "audit: read", "error", err)
+	}
+	extra["audit"] = entries
+	s.templates.Render(w, "tags.html", TemplateData{Title: "Tags", Extra: extra}, r)
+}
+
+// tagUsage fills in usage counts Ganache did not report, from the local
+// index when it is fresh and otherwise from a one-result tag search.
+func (s *Server) tagUsage(ctx context.Context, tags []ganache.Tag) []tagRow {
+	rows := make([]tagRow, len(tags))
+	useIndex := s.index != nil && s.index.Fresh(s.cfg.Index.MaxAge)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, tagCountConcurrent)
+	for i, t := range tags {
+		rows[i] = tagRow{Name: t.Name, Count: t.Count}
+		if t.Count > 0 {
+			continue
+		}
+		if useIndex {
+			rows[i].Count = s.index.Search(query.Query{Tags: []string{t.Name}}, 1, 0).Total
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			resp, err := s.client.SearchAssets(ctx, "", []string{name}, 1, 1, "")
+			if err != nil {
+				rows[i].Count = -1
+				return
+			}
+			rows[i].Count = resp.Total
+		}(i, t.Name)
+	}
+	wg.Wait()
+	return rows
+}
+
+func (s *Server) tagsPreview(w http.ResponseWriter, r *http.Request) {
+	change, err := parseTagChange(r)
+	if err != nil {
+		s.tagsIndex(w, r, map[string]any{"error": err.Error()})
+		return
+	}
+	rows, err := s.tagChangeRows(r.Context(), change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	sample := rows
+	if len(sample) > tagPreviewSample {
+		sample = sample[:tagPreviewSample]
+	}
+	s.tagsIndex(w, r, map[string]any{
+		"change":  change,
+		"preview": sample,
+		"total":   len(rows),
+		"from":    strings.Join(change.From, ", "),
+	})
+}
+
+func (s *Server) tagsApply(w http.ResponseWriter, r *http.Request) {
+	change, err := parseTagChange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := s.tagChangeRows(r.Context(), change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(rows) == 0 {
+		http.Redirect(w, r, s.path("/tags"), http.StatusFound)
+		return
+	}
+	items := make([]jobs.Item, len(rows))
+	for i, row := range rows {
+		edit := bulkEditPayload{RemoveTags: removedTags(row.Before, row.After)}
+		if change.To != "" {
+			edit.AddTags = []string{change.To}
+		}
+		payload, _ := json.Marshal(edit)
+		items[i] = jobs.Item{Key: row.ID, Payload: payload}
+	}
+	job, err := s.jobs.Enqueue(jobKindBulkEdit, currentUser(r), change.String(), items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.audit.Record(audit.Entry{
+		User:   currentUser(r),
+		Action: "tag." + change.Action,
+		Detail: fmt.Sprintf("%s (job %s)", change, job.ID),
+		Assets: len(rows),
+	}); err != nil {
+		slog.ErrorContext(r.Context(), "audit: record", "error", err)
+	}
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+func parseTagChange(r *http.Request) (tagChange, error) {
+	if err := r.ParseForm(); err != nil {
+		return tagChange{}, errors.New("invalid form")
+	}
+	c := tagChange{
+		Action: r.FormValue("action"),
+		From:   tagpolicy.Split(r.FormValue("from")),
+		To:     strings.TrimSpace(r.FormValue("to")),
+	}
+	switch c.Action {
+	case "rename", "merge":
+		if c.To == "" {
+			return c, errors.New("enter the tag to " + c.Action + " into")
+		}
+		if c.Action == "rename" && len(c.From) != 1 {
+			return c, errors.New("rename takes exactly one tag; use merge for several")
+		}
+		for _, f := range c.From {
+			if f == c.To {
+				return c, fmt.Errorf("%q cannot be merged into itself", f)
+			}
+		}
+	case "delete":
+		c.To = ""
+	default:
+		return c, errors.New("unknown tag action")
+	}
+	if len(c.From) == 0 {
+		return c, errors.New("choose at least one tag")
+	}
+	return c, nil
+}
+
+// tagChangeRows finds every asset carrying one of the source tags and
+// works out its tags after the change. Source tags match case-insensitively
+// so "Soccer" and "soccer" merge together. The target spelling itself is
+// never dropped, so a rename that only changes case keeps the tag.
+func (s *Server) tagChangeRows(ctx context.Context, c tagChange) ([]tagPreviewRow, error) {
+	seen := make(map[string]struct{})
+	var rows []tagPreviewRow
+	for _, tag := range c.From {
+		for page := 1; ; page++ {
+			resp, err := s.client.SearchAssets(ctx, "", []string{tag}, page, 100, "newest")
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range resp.Assets {
+				if _, ok := seen[string(a.ID)]; ok {
+					continue
+				}
+				seen[string(a.ID)] = struct{}{}
+				var drop []string
+				for _, t := range a.Tags {
+					if t == c.To {
+						continue
+					}
+					for _, f := range c.From {
+						if strings.EqualFold(t, f) {
+							drop = append(drop, t)
+						}
+					}
+				}
+				if len(drop) == 0 {
+					continue
+				}
+				var add []string
+				if c.To != "" {
+					add = []string{c.To}
+				}
+				rows = append(rows, tagPreviewRow{
+					ID:     string(a.ID),
+					Title:  a.Title,
+					Before: a.Tags,
+					After:  tagpolicy.Edit(a.Tags, add, drop),
+				})
+			}
+			size := resp.PageSize
+			if size <= 0 {
+				size = 100
+			}
+			if len(resp.Assets) < size || (resp.Total > 0 && page*size >= resp.Total) {
+				break
+			}
+		}
+	}
+	return rows, nil
+}
+
+func removedTags(before, after []string) []string {
+	keep := make(map[string]struct{}, len(after))
+	for _, t := range after {
+		keep[t] = struct{}{}
+	}
+	var out []string
+	for _, t := range before {
+		if _, ok := keep[t]; !ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
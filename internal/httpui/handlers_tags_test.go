@@ -0,0 +1,193 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/tagpolicy"
+)
+
+func TestTagsMergePreviewAndApply(t *testing.T) {
+	var mu sync.Mutex
+	assets := map[string]ganache.Asset{
+		"1": {ID: "1", Title: "One", Tags: []string{"soccer", "cup"}},
+		"2": {ID: "2", Title: "Two", Tags: []string{"Soccer", "football"}},
+		"3": {ID: "3", Title: "Three", Tags: []string{"footy"}},
+	}
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/tags":
+			json.NewEncoder(w).Encode(ganache.TagResponse{Tags: []ganache.Tag{{Name: "soccer", Count: 2}, {Name: "footy"}}})
+		case r.URL.Path == "/api/assets":
+			tag := r.URL.Query().Get("tag")
+			var resp ganache.SearchResponse
+			for _, id := range []string{"1", "2", "3"} {
+				for _, t := range assets[id].Tags {
+					if strings.EqualFold(t, tag) {
+						resp.Assets = append(resp.Assets, assets[id])
+						break
+					}
+				}
+			}
+			resp.Total, resp.Page, resp.PageSize = len(resp.Assets), 1, 100
+			json.NewEncoder(w).Encode(resp)
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(assets[strings.TrimPrefix(r.URL.Path, "/api/assets/")])
+		case r.Method == http.MethodPatch:
+			id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+			var u ganache.AssetUpdate
+			json.NewDecoder(r.Body).Decode(&u)
+			a := assets[id]
+			a.Tags = u.Tags
+			assets[id] = a
+			json.NewEncoder(w).Encode(a)
+		}
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	post := func(path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form+"&csrf="+sess.CSRFToken))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "<td>2</td>") || !strings.Contains(rec.Body.String(), "<td>1</td>") {
+		t.Fatalf("expected usage counts from Ganache and from a tag search: %s", rec.Body.String())
+	}
+
+	rec = post("/tags/preview", "action=merge&from=soccer&to=football")
+	body := rec.Body.String()
+	if !strings.Contains(body, "2 assets would change") || !strings.Contains(body, "<td>cup, football</td>") {
+		t.Fatalf("unexpected preview: %s", body)
+	}
+	mu.Lock()
+	if strings.Join(assets["1"].Tags, ",") != "soccer,cup" {
+		t.Fatalf("preview must not change assets")
+	}
+	mu.Unlock()
+
+	rec = post("/tags/apply", "action=merge&from=soccer&to=football")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/jobs" {
+		t.Fatalf("expected redirect to jobs, got %d", rec.Code)
+	}
+	waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	mu.Lock()
+	if strings.Join(assets["1"].Tags, ",") != "cup,football" || strings.Join(assets["2"].Tags, ",") != "football" {
+		t.Fatalf("unexpected tags after merge: %v %v", assets["1"].Tags, assets["2"].Tags)
+	}
+	mu.Unlock()
+
+	entries, _ := srv.audit.Recent("tag.", 10)
+	if len(entries) != 1 || entries[0].User != "tester" || entries[0].Assets != 2 {
+		t.Fatalf("expected audit entry, got %+v", entries)
+	}
+
+	if rec := post("/tags/preview", "action=rename&from=soccer&to="); !strings.Contains(rec.Body.String(), "enter the tag to rename into") {
+		t.Fatalf("expected validation error")
+	}
+}
+
+func TestTagsCaseOnlyRenameKeepsTag(t *testing.T) {
+	var mu sync.Mutex
+	assets := map[string]ganache.Asset{
+		"1": {ID: "1", Tags: []string{"Football", "cup"}},
+		"2": {ID: "2", Tags: []string{"football"}},
+		"3": {ID: "3", Tags: []string{"Football", "football"}},
+	}
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+		switch {
+		case r.URL.Path == "/api/assets":
+			// Ganache matches tags case-insensitively.
+			var resp ganache.SearchResponse
+			for _, id := range []string{"1", "2", "3"} {
+				resp.Assets = append(resp.Assets, assets[id])
+			}
+			resp.Total, resp.Page, resp.PageSize = len(resp.Assets), 1, 100
+			json.NewEncoder(w).Encode(resp)
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(assets[id])
+		case r.Method == http.MethodPatch:
+			var u ganache.AssetUpdate
+			json.NewDecoder(r.Body).Decode(&u)
+			a := assets[id]
+			a.Tags = u.Tags
+			assets[id] = a
+			json.NewEncoder(w).Encode(a)
+		}
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	req := httptest.NewRequest(http.MethodPost, "/tags/apply", strings.NewReader("action=rename&from=Football&to=football&csrf="+sess.CSRFToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	job := waitForJob(t, srv, "tester", jobs.StatusSucceeded)
+	if len(job.Items) != 2 {
+		t.Fatalf("expected only assets with the old spelling to change, got %+v", job.Items)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for id, want := range map[string]string{"1": "cup,football", "2": "football", "3": "football"} {
+		if got := strings.Join(assets[id].Tags, ","); got != want {
+			t.Fatalf("asset %s: expected %q, got %q", id, want, got)
+		}
+	}
+}
+
+func TestTagsAutocompleteStillFragment(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.TagResponse{Tags: []ganache.Tag{{Name: "football"}}})
+	})
+	sess, _ := sessions.Create("tester")
+	req := httptest.NewRequest(http.MethodGet, "/tags?prefix=foo", nil)
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+	if got := rec.Body.String(); !strings.HasPrefix(got, `<button type="button" class="tag-suggestion" data-tag="football">`) {
+		t.Fatalf("unexpected autocomplete response %q", got)
+	}
+}
+
+func TestTagsAutocompletePrefersCanonical(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ganache.TagResponse{Tags: []ganache.Tag{{Name: "soccer"}, {Name: "socks"}}})
+	})
+	router := srv.Router()
+	srv.policy, _ = tagpolicy.New(tagpolicy.File{Tags: []tagpolicy.Term{{Name: "football", Aliases: []string{"soccer"}}}})
+	sess, _ := sessions.Create("tester")
+	req := httptest.NewRequest(http.MethodGet, "/tags?prefix=soc", nil)
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if strings.Count(body, "<button") != 2 || !strings.Contains(body, `data-tag="football"`) || strings.Contains(body, `data-tag="soccer"`) {
+		t.Fatalf("unexpected suggestions %q", body)
+	}
+	if strings.Index(body, "football") > strings.Index(body, "socks") {
+		t.Fatalf("vocabulary term should come first: %q", body)
+	}
+}
@@ -2,11 +2,17 @@ package httpui
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +20,14 @@ import (
 	"ganache-admin-ui/internal/auth"
 	"ganache-admin-ui/internal/config"
 	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/logging"
+	"ganache-admin-ui/internal/scan"
+	"ganache-admin-ui/internal/tagpolicy"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func newTestServer(t *testing.T, ganacheHandler http.HandlerFunc) (*Server, *auth.SessionStore) {
@@ -24,27 +38,60 @@ func newTestServer(t *testing.T, ganacheHandler http.HandlerFunc) (*Server, *aut
 	cfg := &config.Config{
 		ListenAddr:    ":0",
 		UsersFile:     "./users.yaml",
+		DataDir:       t.TempDir(),
 		SessionSecret: []byte("secret"),
 		CSRFSecret:    []byte("csrf"),
-		Ganache: config.GanacheConfig{
+		Backends: []config.GanacheConfig{{
+			Name:    config.DefaultBackend,
+			Label:   "Ganache",
 			BaseURL: backend.URL,
 			APIKey:  "key",
 			Timeout: time.Second,
-		},
+		}},
+		Tus: config.TusConfig{MaxSize: 1 << 20, Expiry: time.Hour, AllowedTypes: []string{"image/*", "video/*"}},
 	}
-	users, err := auth.NewUserStore([]auth.User{{Username: "tester", PasswordHash: "hash"}})
+	users, err := auth.NewUserStore([]auth.User{
+		{Username: "tester", PasswordHash: "hash"},
+		{Username: "intruder", PasswordHash: "hash"},
+		{Username: "someone", PasswordHash: "hash"},
+	})
 	if err != nil {
 		t.Fatalf("users: %v", err)
 	}
 	sessions := auth.NewSessionStore(time.Hour)
-	client := ganache.NewClient(cfg.Ganache.BaseURL, cfg.Ganache.APIKey, cfg.Ganache.Timeout)
-	srv, err := NewServer(cfg, users, sessions, client)
+	srv, err := NewServer(cfg, users, sessions)
 	if err != nil {
 		t.Fatalf("server: %v", err)
 	}
 	return srv, sessions
 }
 
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func uploadRequest(t *testing.T, sess auth.Session, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fileWriter, _ := writer.CreateFormFile("file", filename)
+	fileWriter.Write(content)
+	writer.WriteField("title", "Cover")
+	writer.WriteField("tags[]", "one")
+	writer.WriteField("csrf", sess.CSRFToken)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/assets/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	return req
+}
+
 func TestAssetsIndexCallsSearch(t *testing.T) {
 	var captured *http.Request
 	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
@@ -85,6 +132,46 @@ func TestAssetsIndexCallsSearch(t *testing.T) {
 	}
 }
 
+func TestAssetsResultsStructuredQuery(t *testing.T) {
+	var captured *http.Request
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		captured = r
+		io.WriteString(w, `{"assets":[{"id":"1","title":"Keep","credit":"AP","tags":["football"]},{"id":"2","title":"Old","credit":"AP","tags":["football","archive"]}],"page":1,"pageSize":100,"total":2}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	params := url.Values{"q": {`final tag:football -tag:archive credit:"AP"`}}
+	req := httptest.NewRequest(http.MethodGet, "/assets/results?"+params.Encode(), nil)
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	q := captured.URL.Query()
+	if q.Get("q") != "final" || q.Get("tag") != "football" || q.Get("pageSize") != "100" {
+		t.Fatalf("unexpected ganache params: %v", q)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `href="/assets/1"`) || strings.Contains(body, `href="/assets/2"`) {
+		t.Fatalf("expected archived asset filtered out: %s", body)
+	}
+
+	captured = nil
+	params.Set("q", "tag:football after:soon")
+	req = httptest.NewRequest(http.MethodGet, "/assets/results?"+params.Encode(), nil)
+	req.Header.Set("HX-Request", "true")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if captured != nil {
+		t.Fatalf("ganache should not be called for an invalid query")
+	}
+	if !strings.Contains(rec.Body.String(), "<mark>after:soon</mark>") {
+		t.Fatalf("expected highlighted syntax error: %s", rec.Body.String())
+	}
+}
+
 func TestAssetsUploadForwardsMultipart(t *testing.T) {
 	var filename, title string
 	var tags []string
@@ -106,20 +193,8 @@ func TestAssetsUploadForwardsMultipart(t *testing.T) {
 	router := srv.Router()
 
 	sess, _ := sessions.Create("tester")
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
-	fileWriter, _ := writer.CreateFormFile("file", "pic.png")
-	io.Copy(fileWriter, strings.NewReader("hello"))
-	writer.WriteField("title", "Cover")
-	writer.WriteField("tags[]", "one")
-	writer.WriteField("csrf", sess.CSRFToken)
-	writer.Close()
-
-	req := httptest.NewRequest(http.MethodPost, "/assets/upload", &body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
 	rec := httptest.NewRecorder()
-	router.ServeHTTP(rec, req)
+	router.ServeHTTP(rec, uploadRequest(t, sess, "pic.png", testPNG(t)))
 
 	if rec.Code != http.StatusFound {
 		t.Fatalf("expected redirect, got %d", rec.Code)
@@ -135,9 +210,95 @@ func TestAssetsUploadForwardsMultipart(t *testing.T) {
 	}
 }
 
+func TestAssetsUploadRejectsInvalidContent(t *testing.T) {
+	called := false
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		io.WriteString(w, `{"id":"xyz"}`)
+	})
+	router := srv.Router()
+
+	sess, _ := sessions.Create("tester")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, uploadRequest(t, sess, "pic.png", []byte("not really a png")))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	if called {
+		t.Fatalf("ganache should not receive rejected uploads")
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "text/plain is not allowed") || !strings.Contains(body, `value="Cover"`) {
+		t.Fatalf("expected field error and preserved form values: %s", body)
+	}
+}
+
+type stubScanner struct {
+	result scan.Result
+	err    error
+}
+
+func (s stubScanner) Scan(ctx context.Context, r io.Reader) (scan.Result, error) {
+	io.Copy(io.Discard, r)
+	return s.result, s.err
+}
+
+func TestAssetsUploadScanPolicy(t *testing.T) {
+	cases := []struct {
+		name     string
+		scanner  stubScanner
+		failOpen bool
+		wantCode int
+		wantBody string
+	}{
+		{"infected", stubScanner{result: scan.Result{Infected: true, Signature: "Eicar-Test-Signature"}}, true, http.StatusUnprocessableEntity, "malware detected (Eicar-Test-Signature)"},
+		{"unavailable fail closed", stubScanner{err: errors.New("dial refused")}, false, http.StatusServiceUnavailable, "scanner unavailable"},
+		{"unavailable fail open", stubScanner{err: errors.New("dial refused")}, true, http.StatusFound, ""},
+		{"clean", stubScanner{}, false, http.StatusFound, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var uploaded []byte
+			srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				r.ParseMultipartForm(1 << 20)
+				if f, _, err := r.FormFile("file"); err == nil {
+					uploaded, _ = io.ReadAll(f)
+				}
+				io.WriteString(w, `{"id":"xyz"}`)
+			})
+			srv.scanner = tc.scanner
+			srv.cfg.Scan.FailOpen = tc.failOpen
+			router := srv.Router()
+
+			sess, _ := sessions.Create("tester")
+			content := testPNG(t)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, uploadRequest(t, sess, "pic.png", content))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+			if tc.wantBody != "" && !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("expected %q in body", tc.wantBody)
+			}
+			if tc.wantCode == http.StatusFound && !bytes.Equal(uploaded, content) {
+				t.Fatalf("scanned file not forwarded intact")
+			}
+			if tc.wantCode != http.StatusFound && uploaded != nil {
+				t.Fatalf("rejected upload reached ganache")
+			}
+		})
+	}
+}
+
 func TestAssetEditSendsPatchAndRendersPartial(t *testing.T) {
 	var update ganache.AssetUpdate
 	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/assets/123" && r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(ganache.Asset{ID: "123", Title: "Original"})
+			return
+		}
 		if r.URL.Path != "/api/assets/123" || r.Method != http.MethodPatch {
 			http.NotFound(w, r)
 			return
@@ -170,3 +331,181 @@ func TestAssetEditSendsPatchAndRendersPartial(t *testing.T) {
 		t.Fatalf("expected updated partial")
 	}
 }
+
+func TestAssetEditAppliesTagPolicy(t *testing.T) {
+	var updates []ganache.AssetUpdate
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(ganache.Asset{ID: "123"})
+			return
+		}
+		var update ganache.AssetUpdate
+		json.NewDecoder(r.Body).Decode(&update)
+		updates = append(updates, update)
+		json.NewEncoder(w).Encode(ganache.Asset{ID: "123", Title: update.Title, Tags: update.Tags})
+	})
+	router := srv.Router()
+	policy, err := tagpolicy.New(tagpolicy.File{Tags: []tagpolicy.Term{
+		{Name: "football"},
+		{Name: "Premier League", Parent: "football", Aliases: []string{"EPL"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.policy = policy
+	sess, _ := sessions.Create("tester")
+	edit := func(tags string) *httptest.ResponseRecorder {
+		form := url.Values{"title": {"Derby"}, "tags": {tags}, "csrf": {sess.CSRFToken}}
+		req := httptest.NewRequest(http.MethodPost, "/assets/123/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := edit("epl, footbal")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := updates[0].Tags; strings.Join(got, "|") != "Premier League|football|footbal" {
+		t.Fatalf("tags sent = %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "Not in the tag vocabulary") {
+		t.Fatalf("expected vocabulary warning")
+	}
+
+	strict, _ := tagpolicy.New(tagpolicy.File{Strict: true, Tags: []tagpolicy.Term{{Name: "football"}}})
+	srv.policy = strict
+	rec = edit("footbal")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("rejected tags reached Ganache")
+	}
+	if !strings.Contains(rec.Body.String(), "did you mean") {
+		t.Fatalf("expected field error, got %q", rec.Body.String())
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id":"xyz"}`)
+	})
+	srv.cfg.Metrics.Token = "s3cret"
+	router := srv.Router()
+
+	sess, _ := sessions.Create("tester")
+	router.ServeHTTP(httptest.NewRecorder(), uploadRequest(t, sess, "pic.png", testPNG(t)))
+	form := url.Values{"username": {"tester"}, "password": {"wrong"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the token to be required, got %d", rec.Code)
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`ganache_admin_http_requests_total{method="POST",route="/assets/upload",status="302"} 1`,
+		`ganache_admin_ganache_request_duration_seconds_count{backend="default",method="create"} 1`,
+		`ganache_admin_uploads_total{backend="default",result="success"} 1`,
+		`ganache_admin_logins_total{result="failure"} 1`,
+		`ganache_admin_active_sessions 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestTracingSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	var traceparent string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		io.WriteString(w, `{"id":"7","title":"Kickoff"}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	req := httptest.NewRequest(http.MethodGet, "/assets/7", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	route, call, render := spans["GET /assets/{id}"], spans["ganache get"], spans["render asset_detail.html"]
+	if route == nil || call == nil || render == nil {
+		t.Fatalf("expected route, Ganache and render spans, got %v", spans)
+	}
+	if call.Parent().SpanID() != route.SpanContext().SpanID() || render.Parent().SpanID() != route.SpanContext().SpanID() {
+		t.Fatal("expected the Ganache call and the render within the route span")
+	}
+	if !strings.Contains(traceparent, route.SpanContext().TraceID().String()) {
+		t.Fatalf("expected the trace to be propagated to Ganache, got %q", traceparent)
+	}
+}
+
+func TestRequestIDReachesGanacheAndLogs(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	var forwarded string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(logging.Header)
+		http.Error(w, "storage offline", http.StatusInternalServerError)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	req := httptest.NewRequest(http.MethodGet, "/assets/7", nil)
+	req.Header.Set(logging.Header, "support-42")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+	if forwarded != "support-42" {
+		t.Fatalf("expected the request ID to be forwarded to Ganache, got %q", forwarded)
+	}
+	if !strings.Contains(rec.Body.String(), "request id: support-42") {
+		t.Fatalf("expected the request ID in the error page, got %q", rec.Body.String())
+	}
+	var line struct {
+		RequestID string `json:"request_id"`
+		User      string `json:"user"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", logs.String(), err)
+	}
+	if line.RequestID != "support-42" || line.User != "tester" || line.Route != "/assets/{id}" || line.Status != http.StatusBadGateway {
+		t.Fatalf("unexpected log line %+v", line)
+	}
+	if !strings.Contains(line.Error, "storage offline") {
+		t.Fatalf("expected Ganache's error to be logged, got %q", line.Error)
+	}
+}
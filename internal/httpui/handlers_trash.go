@@ -0,0 +1,125 @@
+package httpui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/trash"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.users.RoleOn(currentUser(r), s.backend.Name) != auth.RoleAdmin {
+			http.Error(w, "admins only", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trashAsset moves an asset to the trash, keeping a copy of its metadata.
+// The asset stays in Ganache until the purge.
+func (s *Server) trashAsset(ctx context.Context, user, id string) error {
+	asset, err := s.client.GetAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = s.trash.Add(trash.Entry{
+		Asset:     asset,
+		DeletedBy: user,
+		DeletedAt: now,
+		PurgeAt:   now.Add(s.cfg.Trash.Retention),
+	})
+	return err
+}
+
+// purgeAsset deletes a trashed asset from Ganache for good. An asset that
+// is already gone there only leaves the trash.
+func (s *Server) purgeAsset(ctx context.Context, id string) error {
+	if err := s.deleteAsset(ctx, id); err != nil && !ganache.IsNotFound(err) {
+		return err
+	}
+	if err := s.trash.Remove(id); err != nil && !errors.Is(err, trash.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// trashPurge deletes assets whose restore window has ended. Failures are
+// retried on the next run.
+func (s *Server) trashPurge() {
+	run := func() {
+		for _, e := range s.trash.Due(time.Now()) {
+			if err := s.purgeAsset(context.Background(), e.ID()); err != nil {
+				slog.Error("trash: purge", "asset", e.ID(), "error", err)
+				continue
+			}
+			slog.Info("trash: purged", "asset", e.ID(), "deleted_by", e.DeletedBy, "deleted_at", e.DeletedAt)
+		}
+	}
+	run()
+	ticker := time.NewTicker(s.cfg.Trash.PurgeInterval)
+	for range ticker.C {
+		run()
+	}
+}
+
+func (s *Server) trashIndex(w http.ResponseWriter, r *http.Request) {
+	s.templates.Render(w, "trash.html", TemplateData{
+		Title: "Trash",
+		Extra: map[string]any{"entries": s.trash.List(), "retention": retentionText(s.cfg.Trash.Retention)},
+	}, r)
+}
+
+func retentionText(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d == day:
+		return "1 day"
+	case d%day == 0:
+		return fmt.Sprintf("%d days", d/day)
+	default:
+		return d.String()
+	}
+}
+
+func (s *Server) trashRestore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := s.trash.Remove(id); err != nil {
+		if errors.Is(err, trash.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "trash: restored", "asset", id)
+	target := s.path("/trash")
+	if r.FormValue("back") == "asset" {
+		target = s.path("/assets/" + id)
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (s *Server) trashPurgeNow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := s.trash.Get(id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := s.purgeAsset(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	slog.InfoContext(r.Context(), "trash: purged", "asset", id)
+	http.Redirect(w, r, s.path("/trash"), http.StatusFound)
+}
@@ -0,0 +1,129 @@
+package httpui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestTrashHidesRestoresAndPurges(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/api/assets/"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/assets":
+			json.NewEncoder(w).Encode(ganache.SearchResponse{
+				Assets: []ganache.Asset{{ID: "5", Title: "Five"}, {ID: "6", Title: "Six"}},
+				Page:   1, PageSize: 20, Total: 2,
+			})
+		default:
+			id := strings.TrimPrefix(r.URL.Path, "/api/assets/")
+			json.NewEncoder(w).Encode(ganache.Asset{ID: ganache.StringID(id), Title: "Asset " + id})
+		}
+	})
+	srv.cfg.Trash.Retention = time.Hour
+	users, _ := auth.NewUserStore([]auth.User{
+		{Username: "tester", PasswordHash: "hash"},
+		{Username: "boss", PasswordHash: "hash", Role: auth.RoleAdmin},
+	})
+	srv.users = users
+	router := srv.Router()
+	editor, _ := sessions.Create("tester")
+	admin, _ := sessions.Create("boss")
+	do := func(sess auth.Session, method, path string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if method == http.MethodPost {
+			form := url.Values{"csrf": {sess.CSRFToken}}
+			req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(editor, http.MethodPost, "/assets/5/delete"); rec.Code != http.StatusFound {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("delete should not reach Ganache before the purge")
+	}
+	body := do(editor, http.MethodGet, "/assets").Body.String()
+	if strings.Contains(body, `href="/assets/5"`) || !strings.Contains(body, `href="/assets/6"`) {
+		t.Fatalf("trashed asset should be hidden from search")
+	}
+	if body := do(editor, http.MethodGet, "/assets/5").Body.String(); !strings.Contains(body, "In the trash") || strings.Contains(body, "Restore asset") {
+		t.Fatalf("detail page should show trash state without restore for editors")
+	}
+	if body := do(editor, http.MethodGet, "/assets").Body.String(); strings.Contains(body, `href="/trash"`) {
+		t.Fatalf("trash link shown to editor")
+	}
+	if rec := do(editor, http.MethodGet, "/trash"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for editor, got %d", rec.Code)
+	}
+
+	body = do(admin, http.MethodGet, "/trash").Body.String()
+	if !strings.Contains(body, "Asset 5") || !strings.Contains(body, "Deleted by tester") {
+		t.Fatalf("trash page missing entry: %s", body)
+	}
+	do(admin, http.MethodPost, "/trash/5/restore")
+	if body := do(editor, http.MethodGet, "/assets").Body.String(); !strings.Contains(body, `href="/assets/5"`) {
+		t.Fatalf("restored asset should be searchable again")
+	}
+
+	do(editor, http.MethodPost, "/assets/6/delete")
+	if rec := do(admin, http.MethodPost, "/trash/6/purge"); rec.Code != http.StatusFound {
+		t.Fatalf("purge: %d", rec.Code)
+	}
+	if len(deleted) != 1 || deleted[0] != "6" {
+		t.Fatalf("expected DeleteAsset for 6, got %v", deleted)
+	}
+	if len(srv.trash.List()) != 0 {
+		t.Fatalf("purged asset should leave the trash")
+	}
+}
+
+func TestTrashPurgeDue(t *testing.T) {
+	var deleted []string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(ganache.Asset{ID: "1"})
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	form := url.Values{"csrf": {sess.CSRFToken}}
+	req := httptest.NewRequest(http.MethodPost, "/assets/1/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Retention is zero in the test config, so the entry is due at once.
+	for _, e := range srv.trash.Due(time.Now()) {
+		if err := srv.purgeAsset(req.Context(), e.ID()); err != nil {
+			t.Fatalf("purge: %v", err)
+		}
+	}
+	if len(deleted) != 1 || len(srv.trash.List()) != 0 {
+		t.Fatalf("asset already gone from Ganache should still leave the trash: %v", deleted)
+	}
+}
@@ -0,0 +1,240 @@
+package httpui
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ganache-admin-ui/internal/media"
+	"ganache-admin-ui/internal/tagpolicy"
+	"ganache-admin-ui/internal/tus"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const tusVersion = "1.0.0"
+
+// uploadMetaFields are the Upload-Metadata keys forwarded to Ganache as
+// form fields. "filename" and "tags" are handled separately.
+var uploadMetaFields = []string{"title", "caption", "credit", "source", "usageNotes"}
+
+func (s *Server) tusOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,expiration,termination")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.cfg.Tus.MaxSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) tusCreate(w http.ResponseWriter, r *http.Request) {
+	if !tusPreamble(w, r) {
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Upload-Length is required", http.StatusBadRequest)
+		return
+	}
+	if length > s.cfg.Tus.MaxSize {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	meta, err := tus.ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u, err := s.uploads.Create(currentUser(r), length, meta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", s.path("/uploads/"+u.ID))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) tusHead(w http.ResponseWriter, r *http.Request) {
+	if !tusPreamble(w, r) {
+		return
+	}
+	u, ok := s.ownedUpload(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) tusPatch(w http.ResponseWriter, r *http.Request) {
+	if !tusPreamble(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset is required", http.StatusBadRequest)
+		return
+	}
+	u, ok := s.ownedUpload(w, r)
+	if !ok {
+		return
+	}
+
+	// A zero-length PATCH at the end retries forwarding a completed upload
+	// whose earlier attempt to reach Ganache failed.
+	if !(u.Complete() && offset == u.Length) {
+		u, err = s.uploads.Append(u.ID, offset, r.Body)
+		switch {
+		case errors.Is(err, tus.ErrOffsetMismatch):
+			http.Error(w, "offset mismatch", http.StatusConflict)
+			return
+		case errors.Is(err, tus.ErrLocked):
+			http.Error(w, "upload is busy", http.StatusLocked)
+			return
+		case errors.Is(err, tus.ErrTooLarge):
+			s.uploads.Remove(u.ID)
+			http.Error(w, "upload exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			// The client went away mid-chunk; the partial data is kept and
+			// the client resumes after a HEAD.
+			slog.WarnContext(r.Context(), "tus: append interrupted", "upload", u.ID, "offset", u.Offset, "error", err)
+			http.Error(w, "upload interrupted", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+	if !u.Complete() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.tusFinish(w, r, u)
+}
+
+func (s *Server) tusDelete(w http.ResponseWriter, r *http.Request) {
+	if !tusPreamble(w, r) {
+		return
+	}
+	u, ok := s.ownedUpload(w, r)
+	if !ok {
+		return
+	}
+	if !s.uploads.TryLock(u.ID) {
+		http.Error(w, "upload is busy", http.StatusLocked)
+		return
+	}
+	defer s.uploads.Unlock(u.ID)
+	if err := s.uploads.Remove(u.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tusFinish validates, scans and forwards a completed upload to Ganache.
+// The staged file is kept if Ganache fails so the client can retry.
+func (s *Server) tusFinish(w http.ResponseWriter, r *http.Request, u tus.Upload) {
+	if !s.uploads.TryLock(u.ID) {
+		http.Error(w, "upload is busy", http.StatusLocked)
+		return
+	}
+	defer s.uploads.Unlock(u.ID)
+
+	file, err := s.uploads.Open(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	filename := u.Metadata["filename"]
+	if filename == "" {
+		filename = u.Metadata["name"]
+	}
+	if filename == "" {
+		filename = u.ID
+	}
+	if _, err := media.Validate(file, s.tusLimits()); err != nil {
+		s.uploads.Remove(u.ID)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := s.scanUpload(r.Context(), u.Owner, file, filename); err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			s.uploads.Remove(u.ID)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	fields := make(map[string]string, len(uploadMetaFields))
+	for _, k := range uploadMetaFields {
+		fields[k] = u.Metadata[k]
+	}
+	tags := tagpolicy.Split(u.Metadata["tags"])
+	asset, err := s.createAsset(r.Context(), file, filename, fields, tags)
+	if err != nil {
+		var verr *media.ValidationError
+		if errors.As(err, &verr) {
+			s.uploads.Remove(u.ID)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := s.uploads.Remove(u.ID); err != nil {
+		slog.ErrorContext(r.Context(), "tus: remove", "upload", u.ID, "error", err)
+	}
+	w.Header().Set("X-Asset-Location", s.path(fmt.Sprintf("/assets/%s", asset.ID)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ownedUpload(w http.ResponseWriter, r *http.Request) (tus.Upload, bool) {
+	u, err := s.uploads.Get(chi.URLParam(r, "id"))
+	if errors.Is(err, tus.ErrNotFound) || (err == nil && u.Owner != currentUser(r)) {
+		http.NotFound(w, r)
+		return tus.Upload{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return tus.Upload{}, false
+	}
+	return u, true
+}
+
+func tusPreamble(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (s *Server) uploadCleanup() {
+	ticker := time.NewTicker(10 * time.Minute)
+	for range ticker.C {
+		if n, err := s.uploads.CleanupExpired(); err != nil {
+			slog.Error("tus: cleanup", "error", err)
+		} else if n > 0 {
+			slog.Info("tus: cleanup", "expired", n)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package httpui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func tusRequest(method, target string, body io.Reader, csrf, session string) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("X-CSRF-Token", csrf)
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+	return req
+}
+
+func TestTusUploadResumesAndForwards(t *testing.T) {
+	var filename, title string
+	var received []byte
+	var tags []string
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/assets" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseMultipartForm(1 << 20)
+		f, fh, _ := r.FormFile("file")
+		filename = fh.Filename
+		received, _ = io.ReadAll(f)
+		title = r.FormValue("title")
+		tags = r.MultipartForm.Value["tags[]"]
+		io.WriteString(w, `{"id":"big1"}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	content := testPNG(t)
+
+	meta := "filename " + base64.StdEncoding.EncodeToString([]byte("huge.png")) +
+		",title " + base64.StdEncoding.EncodeToString([]byte("Stadium")) +
+		",tags " + base64.StdEncoding.EncodeToString([]byte("football, night"))
+	req := tusRequest(http.MethodPost, "/uploads", nil, sess.CSRFToken, sess.ID)
+	req.Header.Set("Upload-Length", strconv.Itoa(len(content)))
+	req.Header.Set("Upload-Metadata", meta)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location := rec.Header().Get("Location")
+
+	half := len(content) / 2
+	patch := func(offset int, chunk []byte) *httptest.ResponseRecorder {
+		req := tusRequest(http.MethodPatch, location, bytes.NewReader(chunk), sess.CSRFToken, sess.ID)
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := patch(0, content[:half]); rec.Code != http.StatusNoContent || rec.Header().Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("first chunk: %d offset=%s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, tusRequest(http.MethodHead, location, nil, "", sess.ID))
+	if rec.Header().Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("head: expected offset %d, got %s", half, rec.Header().Get("Upload-Offset"))
+	}
+
+	if rec := patch(0, content); rec.Code != http.StatusConflict {
+		t.Fatalf("stale offset: expected 409, got %d", rec.Code)
+	}
+	rec = patch(half, content[half:])
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("final chunk: expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if loc := rec.Header().Get("X-Asset-Location"); loc != "/assets/big1" {
+		t.Fatalf("unexpected asset location %q", loc)
+	}
+	if filename != "huge.png" || title != "Stadium" || !bytes.Equal(received, content) {
+		t.Fatalf("upload not forwarded intact: %q %q %d bytes", filename, title, len(received))
+	}
+	if len(tags) != 2 || tags[1] != "night" {
+		t.Fatalf("tags not forwarded: %v", tags)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, tusRequest(http.MethodHead, location, nil, "", sess.ID))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected staged upload removed, got %d", rec.Code)
+	}
+}
+
+func TestTusUploadAcceptsVideo(t *testing.T) {
+	var received []byte
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		f, _, _ := r.FormFile("file")
+		received, _ = io.ReadAll(f)
+		io.WriteString(w, `{"id":"clip1"}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+	// An MP4 ftyp box followed by an empty mdat box.
+	content := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom\x00\x00\x00\x08mdat")
+
+	req := tusRequest(http.MethodPost, "/uploads", nil, sess.CSRFToken, sess.ID)
+	req.Header.Set("Upload-Length", strconv.Itoa(len(content)))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("goal.mp4")))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	location := rec.Header().Get("Location")
+
+	req = tusRequest(http.MethodPatch, location, bytes.NewReader(content), sess.CSRFToken, sess.ID)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the video to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Asset-Location") != "/assets/clip1" || !bytes.Equal(received, content) {
+		t.Fatalf("video not forwarded intact: %d bytes", len(received))
+	}
+}
+
+func TestTusRejectsOtherUsersAndBadVersion(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	router := srv.Router()
+	owner, _ := sessions.Create("tester")
+	other, _ := sessions.Create("intruder")
+
+	req := tusRequest(http.MethodPost, "/uploads", nil, owner.CSRFToken, owner.ID)
+	req.Header.Set("Upload-Length", "10")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	location := rec.Header().Get("Location")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, tusRequest(http.MethodHead, location, nil, "", other.ID))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other user, got %d", rec.Code)
+	}
+
+	req = tusRequest(http.MethodHead, location, nil, "", owner.ID)
+	req.Header.Del("Tus-Resumable")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 without Tus-Resumable, got %d", rec.Code)
+	}
+
+	req = tusRequest(http.MethodPost, "/uploads", nil, owner.CSRFToken, owner.ID)
+	req.Header.Set("Upload-Length", strconv.Itoa(2<<20))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 above max size, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package httpui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/zipimport"
+)
+
+// importZip stages the images of an uploaded ZIP and queues them as an
+// upload job. Entries that cannot be uploaded, and manifest rows that name
+// no file, are queued as failed items so the job page reports every entry.
+func (s *Server) importZip(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		s.renderImportError(w, r, http.StatusRequestEntityTooLarge, "The archive is larger than 1GB.")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.renderImportError(w, r, http.StatusBadRequest, "Choose a ZIP file to import.")
+		return
+	}
+	defer file.Close()
+	entries, err := zipimport.Read(file, header.Size, zipimport.Limits{
+		MaxFileSize:  maxUploadSize,
+		MaxTotalSize: maxBatchUploadSize,
+	})
+	if err != nil {
+		s.renderImportError(w, r, http.StatusUnprocessableEntity, header.Filename+": "+err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		s.renderImportError(w, r, http.StatusUnprocessableEntity, header.Filename+" has no images.")
+		return
+	}
+
+	items := make([]jobs.Item, 0, len(entries))
+	for _, e := range entries {
+		p := uploadJobPayload{Filename: e.Filename(), Fields: e.Fields, Tags: e.Tags, Error: e.Err}
+		if p.Error == "" {
+			if p.Path, err = s.stageZipEntry(e); err != nil {
+				p.Error = err.Error()
+			}
+		}
+		payload, _ := json.Marshal(p)
+		items = append(items, jobs.Item{Key: e.Name, Payload: payload})
+	}
+	title := fmt.Sprintf("Import %s (%d files)", filepath.Base(header.Filename), len(items))
+	if _, err := s.jobs.Enqueue(jobKindUpload, currentUser(r), title, items); err != nil {
+		for _, it := range items {
+			removeStagedUpload(it)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "import: queued", "file", header.Filename, "entries", len(items))
+	http.Redirect(w, r, s.path("/jobs"), http.StatusFound)
+}
+
+func (s *Server) stageZipEntry(e zipimport.Item) (string, error) {
+	src, err := e.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return s.stageJobReader(src)
+}
@@ -0,0 +1,109 @@
+package httpui
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/jobs"
+)
+
+func zipImportRequest(t *testing.T, sess auth.Session, files map[string][]byte) *http.Request {
+	t.Helper()
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, data := range files {
+		fw, _ := zw.Create(name)
+		fw.Write(data)
+	}
+	zw.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fw, _ := writer.CreateFormFile("file", "shoot.zip")
+	fw.Write(archive.Bytes())
+	writer.WriteField("csrf", sess.CSRFToken)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/imports/zip", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+	return req
+}
+
+func TestImportZipQueuesEachImage(t *testing.T) {
+	var mu sync.Mutex
+	uploads := map[string]string{}
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		mu.Lock()
+		uploads[r.FormValue("title")] = r.FormValue("credit") + "|" + strings.Join(r.Form["tags[]"], ",")
+		mu.Unlock()
+		io.WriteString(w, `{"id":"new"}`)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, zipImportRequest(t, sess, map[string][]byte{
+		"manifest.csv": []byte("file,title,credit,tags\none.png,Kick-off,AP,\"cup, final\"\nghost.png,Ghost,,\n"),
+		"one.png":      testPNG(t),
+		"two.png":      testPNG(t),
+		"notes.txt":    []byte("plain text"),
+		"../evil.png":  testPNG(t),
+	}))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/jobs" {
+		t.Fatalf("expected redirect to /jobs, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	job := waitForJob(t, srv, "tester", jobs.StatusFailed)
+	if !strings.HasPrefix(job.Title, "Import shoot.zip") {
+		t.Fatalf("unexpected title %q", job.Title)
+	}
+	p := job.Progress()
+	if p.Total != 5 || p.Succeeded != 2 || p.Failed != 3 {
+		t.Fatalf("expected 2 uploads and 3 failures, got %+v", p)
+	}
+	errs := map[string]string{}
+	for _, it := range job.Items {
+		errs[it.Key] = it.Error
+	}
+	if !strings.Contains(errs["../evil.png"], "unsafe") || !strings.Contains(errs["ghost.png"], "no such file") ||
+		!strings.Contains(errs["notes.txt"], "not allowed") {
+		t.Fatalf("unexpected item errors %v", errs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if uploads["Kick-off"] != "AP|cup,final" {
+		t.Fatalf("manifest metadata not sent: %v", uploads)
+	}
+	if _, ok := uploads["two"]; !ok {
+		t.Fatalf("title should default to the file name: %v", uploads)
+	}
+}
+
+func TestImportZipRejectsBadArchive(t *testing.T) {
+	srv, sessions := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected ganache call %s", r.URL.Path)
+	})
+	router := srv.Router()
+	sess, _ := sessions.Create("tester")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, zipImportRequest(t, sess, map[string][]byte{
+		"manifest.csv": []byte("file,colour\none.png,red\n"),
+		"one.png":      testPNG(t),
+	}))
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "unknown column") {
+		t.Fatalf("expected 422 with the manifest error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(srv.jobs.List("tester")) != 0 {
+		t.Fatal("no job should be queued")
+	}
+}
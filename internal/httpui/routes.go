@@ -1,14 +1,33 @@
 package httpui
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"ganache-admin-ui/internal/audit"
 	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/collections"
 	"ganache-admin-ui/internal/config"
 	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/index"
+	"ganache-admin-ui/internal/jobs"
+	"ganache-admin-ui/internal/logging"
+	"ganache-admin-ui/internal/metaimport"
+	"ganache-admin-ui/internal/metrics"
+	"ganache-admin-ui/internal/migrate"
+	"ganache-admin-ui/internal/revisions"
+	"ganache-admin-ui/internal/scan"
+	"ganache-admin-ui/internal/searches"
 	"ganache-admin-ui/internal/security"
+	"ganache-admin-ui/internal/tagpolicy"
+	"ganache-admin-ui/internal/tracing"
+	"ganache-admin-ui/internal/trash"
+	"ganache-admin-ui/internal/tus"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -17,29 +36,133 @@ import (
 const maxUploadSize = 25 * 1024 * 1024
 
 type Server struct {
-	cfg       *config.Config
-	users     *auth.UserStore
-	sessions  *auth.SessionStore
-	client    *ganache.Client
-	templates *Templates
+	cfg         *config.Config
+	users       *auth.UserStore
+	sessions    *auth.SessionStore
+	client      *ganache.Client
+	templates   *Templates
+	scanner     scan.Scanner
+	uploads     *tus.Store
+	jobs        *jobs.Queue
+	searches    *searches.Store
+	index       *index.Index
+	audit       *audit.Log
+	policy      *tagpolicy.Policy
+	collections *collections.Store
+	revisions   *revisions.Store
+	trash       *trash.Store
+	imports     *metaimport.Store
+	metrics     *metrics.Metrics
+	// jobFiles is where files queued for upload are staged.
+	jobFiles string
+
+	// backend is the Ganache backend this server talks to and base the
+	// path its pages are served under. backends holds the servers of every
+	// configured backend, the default first; see backends.go.
+	backend  config.GanacheConfig
+	base     string
+	backends []*Server
+	handler  http.Handler
+
+	// migrationStores holds the mappings of assets copied from this
+	// backend, by target; see handlers_migrate.go.
+	migrationsMu    sync.Mutex
+	migrationStores map[string]*migrate.Store
 }
 
-func NewServer(cfg *config.Config, users *auth.UserStore, sessions *auth.SessionStore, client *ganache.Client) (*Server, error) {
+// NewServer returns the server of the default backend, which also routes
+// requests to the others.
+func NewServer(cfg *config.Config, users *auth.UserStore, sessions *auth.SessionStore) (*Server, error) {
 	tmpls, err := ParseTemplates()
 	if err != nil {
 		return nil, err
 	}
-	return &Server{cfg: cfg, users: users, sessions: sessions, client: client, templates: tmpls}, nil
+	saved, err := searches.NewStore(filepath.Join(cfg.DataDir, "searches.json"))
+	if err != nil {
+		return nil, err
+	}
+	policy, err := tagpolicy.Load(cfg.TagPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	var scanner scan.Scanner
+	if cfg.Scan.ClamdAddr != "" {
+		clamd, err := scan.NewClamdScanner(cfg.Scan.ClamdAddr, cfg.Scan.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		scanner = clamd
+	}
+	m := metrics.New(sessions.Count)
+	var backends []*Server
+	for _, b := range cfg.Backends {
+		srv := &Server{cfg: cfg, users: users, sessions: sessions, templates: tmpls, scanner: scanner, searches: saved, policy: policy, metrics: m, backend: b}
+		if len(cfg.Backends) > 1 {
+			srv.base = "/b/" + b.Name
+		}
+		if err := srv.openStores(cfg.BackendDataDir(b.Name)); err != nil {
+			return nil, fmt.Errorf("backend %s: %w", b.Name, err)
+		}
+		srv.client = ganache.NewClient(b.BaseURL, b.APIKey, b.Timeout)
+		srv.client.Observe(m.Ganache(b.Name))
+		srv.client.WrapTransport(tracing.Transport(b.Name))
+		srv.client.WrapTransport(logging.Transport)
+		srv.registerJobs()
+		backends = append(backends, srv)
+	}
+	for _, srv := range backends {
+		srv.backends = backends
+	}
+	srv := backends[0]
+	tmpls.sidebar = func(user string) any { return saved.Pinned(user) }
+	tmpls.scope = srv.scope
+	return srv, nil
+}
+
+// openStores opens the stores kept per backend in dir.
+func (s *Server) openStores(dir string) error {
+	var err error
+	if s.uploads, err = tus.NewStore(filepath.Join(dir, "uploads"), s.cfg.Tus.Expiry); err != nil {
+		return err
+	}
+	s.jobs, err = jobs.NewQueue(filepath.Join(dir, "jobs.json"), jobs.Options{
+		Concurrency: s.cfg.Jobs.Concurrency,
+		MaxAttempts: s.cfg.Jobs.MaxAttempts,
+	})
+	if err != nil {
+		return err
+	}
+	if s.collections, err = collections.NewStore(filepath.Join(dir, "collections.json")); err != nil {
+		return err
+	}
+	if s.trash, err = trash.NewStore(filepath.Join(dir, "trash.json")); err != nil {
+		return err
+	}
+	s.audit = audit.NewLog(filepath.Join(dir, "audit.jsonl"))
+	s.revisions = revisions.NewStore(filepath.Join(dir, "revisions"))
+	s.imports = metaimport.NewStore(filepath.Join(dir, "imports"))
+	s.jobFiles = filepath.Join(dir, "job-files")
+	if s.cfg.Index.Enabled {
+		if s.index, err = index.Open(filepath.Join(dir, "index.json")); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *Server) Router() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware)
+	r.Use(s.metrics.Middleware)
 
 	r.Get("/", s.rootRedirect)
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
 	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	if s.cfg.Metrics.Addr == "" && s.cfg.Metrics.Token != "" {
+		r.Handle("/metrics", s.MetricsHandler())
+	}
 
 	fs := http.FileServer(http.Dir("web/static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fs))
@@ -49,24 +172,107 @@ func (s *Server) Router() http.Handler {
 
 	r.Group(func(pr chi.Router) {
 		pr.Use(auth.RequireAuth(s.sessions))
+		pr.Use(logUser)
 		pr.Use(security.Middleware())
 
 		pr.Post("/logout", s.handleLogout)
-		pr.Get("/assets", s.assetsIndex)
-		pr.Get("/assets/results", s.assetsResults)
-		pr.Get("/assets/new", s.assetsNew)
-		pr.Post("/assets/upload", s.assetsUpload)
-		pr.Get("/assets/{id}", s.assetDetail)
-		pr.Post("/assets/{id}/edit", s.assetEdit)
-		pr.Post("/assets/{id}/delete", s.assetDelete)
-		pr.Get("/tags", s.tagsList)
+		pr.Post("/backend", s.switchBackend)
+		for _, b := range s.backends {
+			b.handler = b.enter(b.routes())
+			if b.base != "" {
+				pr.Mount(b.base, b.handler)
+			}
+		}
+		pr.Handle("/*", http.HandlerFunc(s.serveActive))
 	})
 
 	go s.sessionCleanup()
+	for _, b := range s.backends {
+		go b.uploadCleanup()
+		go b.jobs.Run(context.Background())
+		if b.index != nil {
+			go b.indexSync()
+		}
+		if b.cfg.Trash.PurgeInterval > 0 {
+			go b.trashPurge()
+		}
+	}
 
 	return r
 }
 
+// MetricsHandler serves the Prometheus metrics, for UI_METRICS_ADDR.
+func (s *Server) MetricsHandler() http.Handler {
+	return s.metrics.Handler(s.cfg.Metrics.Token)
+}
+
+// routes returns the pages of one backend, relative to its base.
+func (s *Server) routes() http.Handler {
+	pr := chi.NewRouter()
+	pr.Get("/assets", s.assetsIndex)
+	pr.Get("/assets/results", s.assetsResults)
+	pr.Get("/assets/export", s.assetsExport)
+	pr.Get("/assets/new", s.assetsNew)
+	pr.Post("/assets/upload", s.assetsUpload)
+	pr.Get("/assets/{id}", s.assetDetail)
+	pr.Post("/assets/{id}/edit", s.assetEdit)
+	pr.Post("/assets/{id}/delete", s.assetDelete)
+	pr.Get("/assets/{id}/history", s.assetHistory)
+	pr.Post("/assets/{id}/revisions/{rev}/revert", s.assetRevert)
+	pr.Get("/tags", s.tagsList)
+	pr.Post("/tags/preview", s.tagsPreview)
+	pr.Post("/tags/apply", s.tagsApply)
+
+	pr.Options("/uploads", s.tusOptions)
+	pr.Post("/uploads", s.tusCreate)
+	pr.Head("/uploads/{id}", s.tusHead)
+	pr.Patch("/uploads/{id}", s.tusPatch)
+	pr.Delete("/uploads/{id}", s.tusDelete)
+
+	pr.Get("/jobs", s.jobsIndex)
+	pr.Get("/jobs/events", s.jobsEvents)
+	pr.Post("/jobs/uploads", s.jobsUpload)
+	pr.Post("/jobs/bulk-edit", s.jobsBulkEdit)
+	pr.Post("/jobs/copy", s.jobsCopy)
+	pr.Post("/jobs/{id}/retry", s.jobRetry)
+
+	pr.Get("/searches", s.searchesIndex)
+	pr.Post("/searches", s.searchCreate)
+	pr.Get("/searches/{id}", s.searchOpen)
+	pr.Get("/searches/{id}/new-count", s.searchNewCount)
+	pr.Post("/searches/{id}/update", s.searchUpdate)
+	pr.Post("/searches/{id}/delete", s.searchDelete)
+	pr.Post("/searches/{id}/copy", s.searchCopy)
+	pr.Get("/searches/{id}/download.zip", s.downloadSearch)
+
+	pr.Post("/downloads", s.downloadSelection)
+
+	pr.Get("/imports", s.importsNew)
+	pr.Post("/imports", s.importCreate)
+	pr.Post("/imports/zip", s.importZip)
+	pr.Get("/imports/{id}", s.importShow)
+	pr.Post("/imports/{id}/apply", s.importApply)
+
+	pr.Get("/collections", s.collectionsIndex)
+	pr.Post("/collections", s.collectionCreate)
+	pr.Post("/collections/add", s.collectionAdd)
+	pr.Get("/collections/{id}", s.collectionShow)
+	pr.Get("/collections/{id}/export.json", s.collectionExport)
+	pr.Get("/collections/{id}/download.zip", s.downloadCollection)
+	pr.Post("/collections/{id}/update", s.collectionUpdate)
+	pr.Post("/collections/{id}/remove", s.collectionRemove)
+	pr.Post("/collections/{id}/reorder", s.collectionReorder)
+	pr.Post("/collections/{id}/delete", s.collectionDelete)
+
+	pr.Group(func(ar chi.Router) {
+		ar.Use(s.requireAdmin)
+		ar.Get("/trash", s.trashIndex)
+		ar.Post("/trash/{id}/restore", s.trashRestore)
+		ar.Post("/trash/{id}/purge", s.trashPurgeNow)
+	})
+	return pr
+}
+
 func (s *Server) rootRedirect(w http.ResponseWriter, r *http.Request) {
 	if cookie, err := r.Cookie("session"); err == nil {
 		if sess, ok := s.sessions.Get(cookie.Value); ok {
@@ -78,6 +284,14 @@ func (s *Server) rootRedirect(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
+// logUser adds the signed-in user to the lines logged for the request.
+func logUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.SetUser(r.Context(), currentUser(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) sessionCleanup() {
 	ticker := time.NewTicker(30 * time.Minute)
 	for range ticker.C {
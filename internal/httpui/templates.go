@@ -8,12 +8,24 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"ganache-admin-ui/internal/auth"
+	"ganache-admin-ui/internal/logging"
+	"ganache-admin-ui/internal/tracing"
+
+	"go.opentelemetry.io/otel/codes"
 )
 
 type Templates struct {
 	t *template.Template
+
+	// sidebar, when set, returns the pinned entries shown beside every page
+	// for the signed-in user.
+	sidebar func(user string) any
+	// scope, when set, fills in what depends on the backend the page
+	// shows: the base of its links, the switcher and the user's role.
+	scope func(r *http.Request, data *TemplateData)
 }
 
 type TemplateData struct {
@@ -28,7 +40,18 @@ type TemplateData struct {
 	Asset   any
 	Assets  any
 	Extra   map[string]any
+	Pinned  any
+	Admin   bool
 	Content template.HTML
+	// RequestID is shown with errors, for users to quote to support.
+	RequestID string
+
+	// Base prefixes every link to a backend's pages; empty with a single
+	// backend. Backends lists the switcher entries when there are several.
+	Base     string
+	Backend  BackendOption
+	Backends []BackendOption
+	ReadOnly bool
 }
 
 func ParseTemplates() (*Templates, error) {
@@ -41,6 +64,12 @@ func ParseTemplates() (*Templates, error) {
 		"join": func(list []string, sep string) string {
 			return template.HTMLEscapeString(strings.Join(list, sep))
 		},
+		"datetime": func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Local().Format("2006-01-02 15:04")
+		},
 	}).ParseFiles(files...)
 	if err != nil {
 		return nil, err
@@ -68,10 +97,19 @@ func templateFiles() ([]string, error) {
 }
 
 func (t *Templates) Render(w http.ResponseWriter, name string, data TemplateData, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "render "+name)
+	defer span.End()
+	data.RequestID = logging.RequestID(r.Context())
 	sess, ok := auth.SessionFromContext(r.Context())
 	if ok {
 		data.User = sess.Username
 		data.CSRF = sess.CSRFToken
+		if t.sidebar != nil {
+			data.Pinned = t.sidebar(sess.Username)
+		}
+		if t.scope != nil {
+			t.scope(r, &data)
+		}
 	}
 
 	contentName := string(data.Content)
@@ -86,12 +124,14 @@ func (t *Templates) Render(w http.ResponseWriter, name string, data TemplateData
 
 	var buf bytes.Buffer
 	if err := t.t.ExecuteTemplate(&buf, contentName, data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	data.Content = template.HTML(buf.String())
 
 	if err := t.t.ExecuteTemplate(w, name, data); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
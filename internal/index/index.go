@@ -0,0 +1,291 @@
+// Package index keeps a local full-text index of asset metadata so the
+// library can offer facets, typo-tolerant matching and field-scoped search
+// that Ganache does not. It is filled from Ganache by Sync and patched by the
+// admin UI's own uploads and edits in between.
+package index
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"ganache-admin-ui/internal/filestore"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/query"
+)
+
+// syncPageSize is the Ganache page size used while syncing.
+const syncPageSize = 100
+
+type field uint8
+
+const (
+	fieldTitle field = 1 << iota
+	fieldCaption
+	fieldCredit
+	fieldSource
+	fieldTags
+	fieldNotes
+
+	allFields = fieldTitle | fieldCaption | fieldCredit | fieldSource | fieldTags | fieldNotes
+)
+
+// weight ranks a token hit by the fields it was found in.
+func (f field) weight() int {
+	w := 0
+	if f&fieldTitle != 0 {
+		w += 3
+	}
+	if f&fieldTags != 0 {
+		w += 2
+	}
+	if f&(fieldCaption|fieldCredit|fieldSource|fieldNotes) != 0 {
+		w++
+	}
+	return w
+}
+
+type Status struct {
+	Assets     int
+	SyncedAt   time.Time
+	FullSyncAt time.Time
+}
+
+type Index struct {
+	path string
+
+	mu         sync.RWMutex
+	docs       map[string]ganache.Asset
+	postings   map[string]map[string]field
+	syncedAt   time.Time
+	fullSyncAt time.Time
+	// newest is the creation time of the newest asset read by Sync. Assets
+	// added with Put do not move it, as Ganache may hold older assets the
+	// index has not read yet.
+	newest time.Time
+	dirty  bool
+}
+
+type snapshot struct {
+	SyncedAt   time.Time       `json:"syncedAt"`
+	FullSyncAt time.Time       `json:"fullSyncAt"`
+	Newest     time.Time       `json:"newest,omitempty"`
+	Assets     []ganache.Asset `json:"assets"`
+}
+
+// Open loads the index saved at path, or returns an empty index that needs
+// a full sync before it is Fresh.
+func Open(path string) (*Index, error) {
+	var snap snapshot
+	if err := filestore.ReadJSON(path, &snap); err != nil {
+		return nil, err
+	}
+	ix := &Index{
+		path:       path,
+		docs:       make(map[string]ganache.Asset, len(snap.Assets)),
+		postings:   make(map[string]map[string]field),
+		syncedAt:   snap.SyncedAt,
+		fullSyncAt: snap.FullSyncAt,
+		newest:     snap.Newest,
+	}
+	for _, a := range snap.Assets {
+		ix.putLocked(a)
+	}
+	return ix, nil
+}
+
+func (ix *Index) Status() Status {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return Status{Assets: len(ix.docs), SyncedAt: ix.syncedAt, FullSyncAt: ix.fullSyncAt}
+}
+
+// Fresh reports whether the index has been fully built and synced within
+// maxAge. Callers fall back to Ganache otherwise.
+func (ix *Index) Fresh(maxAge time.Duration) bool {
+	st := ix.Status()
+	return !st.FullSyncAt.IsZero() && time.Since(st.SyncedAt) <= maxAge
+}
+
+func (ix *Index) Get(id string) (ganache.Asset, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	a, ok := ix.docs[id]
+	return a, ok
+}
+
+// Put adds or replaces an asset.
+func (ix *Index) Put(a ganache.Asset) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.putLocked(a)
+	ix.dirty = true
+}
+
+// Update applies an edit to an indexed asset. Assets the index has not seen
+// yet are left for the next sync.
+func (ix *Index) Update(id string, u ganache.AssetUpdate) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	a, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	a.Title, a.Caption, a.Credit, a.Source, a.UsageNotes = u.Title, u.Caption, u.Credit, u.Source, u.UsageNotes
+	a.Tags = append([]string(nil), u.Tags...)
+	ix.putLocked(a)
+	ix.dirty = true
+}
+
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(id)
+	ix.dirty = true
+}
+
+func (ix *Index) putLocked(a ganache.Asset) {
+	id := string(a.ID)
+	ix.removeLocked(id)
+	ix.docs[id] = a
+	for tok, f := range docTokens(a) {
+		ids := ix.postings[tok]
+		if ids == nil {
+			ids = make(map[string]field)
+			ix.postings[tok] = ids
+		}
+		ids[id] = f
+	}
+}
+
+func (ix *Index) removeLocked(id string) {
+	old, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for tok := range docTokens(old) {
+		delete(ix.postings[tok], id)
+		if len(ix.postings[tok]) == 0 {
+			delete(ix.postings, tok)
+		}
+	}
+	delete(ix.docs, id)
+}
+
+func docTokens(a ganache.Asset) map[string]field {
+	toks := make(map[string]field)
+	add := func(text string, f field) {
+		for _, t := range tokenize(text) {
+			toks[t] |= f
+		}
+	}
+	add(a.Title, fieldTitle)
+	add(a.Caption, fieldCaption)
+	add(a.Credit, fieldCredit)
+	add(a.Source, fieldSource)
+	add(a.UsageNotes, fieldNotes)
+	for _, t := range a.Tags {
+		add(t, fieldTags)
+	}
+	return toks
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Sync pages through Ganache newest first. An incremental sync stops at the
+// first asset created before the newest one an earlier sync read, skipping
+// assets the index already holds, such as the admin UI's own uploads; a
+// full sync reads everything and drops assets Ganache no longer returns.
+// Edits made outside the admin UI to older assets are only picked up by a
+// full sync.
+func (ix *Index) Sync(ctx context.Context, s query.Searcher, full bool) (int, error) {
+	started := time.Now()
+	seen := make(map[string]struct{})
+	count := 0
+	ix.mu.RLock()
+	since, newest := ix.newest, ix.newest
+	ix.mu.RUnlock()
+	for page := 1; ; page++ {
+		resp, err := s.SearchAssets(ctx, "", nil, page, syncPageSize, "newest")
+		if err != nil {
+			return count, err
+		}
+		caughtUp := false
+		ix.mu.Lock()
+		for _, a := range resp.Assets {
+			id := string(a.ID)
+			seen[id] = struct{}{}
+			if a.CreatedAt.After(newest) {
+				newest = a.CreatedAt
+			}
+			if !full {
+				if a.CreatedAt.Before(since) {
+					caughtUp = true
+					break
+				}
+				if old, ok := ix.docs[id]; ok && old.CreatedAt.Equal(a.CreatedAt) {
+					continue
+				}
+			}
+			ix.putLocked(a)
+			count++
+		}
+		ix.mu.Unlock()
+
+		size := resp.PageSize
+		if size <= 0 {
+			size = syncPageSize
+		}
+		if caughtUp || len(resp.Assets) < size || (resp.Total > 0 && page*size >= resp.Total) {
+			break
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if full {
+		for id, a := range ix.docs {
+			// Assets uploaded while the sync was running may not have been
+			// paged yet.
+			if _, ok := seen[id]; !ok && a.CreatedAt.Before(started) {
+				ix.removeLocked(id)
+			}
+		}
+		ix.fullSyncAt = time.Now()
+	}
+	if newest.After(ix.newest) {
+		ix.newest = newest
+	}
+	ix.syncedAt = time.Now()
+	ix.dirty = true
+	return count, nil
+}
+
+// Save writes the index to disk if it changed since the last save.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	if !ix.dirty {
+		ix.mu.Unlock()
+		return nil
+	}
+	snap := snapshot{SyncedAt: ix.syncedAt, FullSyncAt: ix.fullSyncAt, Newest: ix.newest, Assets: make([]ganache.Asset, 0, len(ix.docs))}
+	for _, a := range ix.docs {
+		snap.Assets = append(snap.Assets, a)
+	}
+	ix.dirty = false
+	ix.mu.Unlock()
+
+	if err := filestore.WriteJSON(ix.path, snap); err != nil {
+		ix.mu.Lock()
+		ix.dirty = true
+		ix.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// library serves assets newest first, as Ganache does for sort=newest.
+type library struct {
+	assets []ganache.Asset
+	pages  int
+}
+
+func (l *library) SearchAssets(ctx context.Context, q string, tags []string, page, pageSize int, sort string) (ganache.SearchResponse, error) {
+	l.pages++
+	resp := ganache.SearchResponse{Page: page, PageSize: pageSize, Total: len(l.assets)}
+	for i := (page - 1) * pageSize; i < page*pageSize && i < len(l.assets); i++ {
+		resp.Assets = append(resp.Assets, l.assets[i])
+	}
+	return resp, nil
+}
+
+func newLibrary(n int) *library {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &library{}
+	for i := n; i > 0; i-- {
+		l.assets = append(l.assets, ganache.Asset{ID: ganache.StringID(fmt.Sprint(i)), Title: fmt.Sprintf("Asset %d", i), CreatedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+	return l
+}
+
+func TestSyncIncrementalAndFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	ix, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if ix.Fresh(time.Hour) {
+		t.Fatalf("empty index must not be fresh")
+	}
+	lib := newLibrary(250)
+	if n, err := ix.Sync(context.Background(), lib, true); err != nil || n != 250 {
+		t.Fatalf("full sync: %d %v", n, err)
+	}
+	if !ix.Fresh(time.Hour) {
+		t.Fatalf("expected fresh index after full sync")
+	}
+
+	newest := lib.assets[0].CreatedAt
+	lib.assets = append([]ganache.Asset{{ID: "new", Title: "Fresh upload", CreatedAt: newest.Add(time.Hour)}}, lib.assets...)
+	lib.pages = 0
+	if n, err := ix.Sync(context.Background(), lib, false); err != nil || n != 1 || lib.pages != 1 {
+		t.Fatalf("incremental sync should stop at known assets: %d assets, %d pages, %v", n, lib.pages, err)
+	}
+
+	lib.assets = lib.assets[:100]
+	if _, err := ix.Sync(context.Background(), lib, true); err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	if st := ix.Status(); st.Assets != 100 {
+		t.Fatalf("full sync should drop deleted assets, have %d", st.Assets)
+	}
+
+	if err := ix.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, ok := reopened.Get("new"); !ok || !reopened.Fresh(time.Hour) {
+		t.Fatalf("expected saved index to reload")
+	}
+	if res := reopened.Search(mustParse(t, "fresh"), 1, 10); res.Total != 1 {
+		t.Fatalf("expected tokens rebuilt on load, got %d", res.Total)
+	}
+}
+
+func TestIncrementalSyncReadsPastOwnUploads(t *testing.T) {
+	ix, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	lib := newLibrary(10)
+	if _, err := ix.Sync(context.Background(), lib, true); err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	newest := lib.assets[0].CreatedAt
+	// Uploaded outside the admin UI, then one through it, which is Put.
+	external := ganache.Asset{ID: "external", Title: "Sent by the API", CreatedAt: newest.Add(time.Hour)}
+	own := ganache.Asset{ID: "own", Title: "Sent from the UI", CreatedAt: newest.Add(2 * time.Hour)}
+	ix.Put(own)
+	lib.assets = append([]ganache.Asset{own, external}, lib.assets...)
+
+	if n, err := ix.Sync(context.Background(), lib, false); err != nil || n != 1 {
+		t.Fatalf("expected only the external asset to be read: %d %v", n, err)
+	}
+	if _, ok := ix.Get("external"); !ok {
+		t.Fatalf("incremental sync stopped at the admin UI's own upload")
+	}
+}
+
+func TestPutUpdateRemove(t *testing.T) {
+	ix, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	ix.Put(ganache.Asset{ID: "1", Title: "Harbour at dawn", Tags: []string{"sea"}})
+	ix.Update("1", ganache.AssetUpdate{Title: "Harbour at dusk", Tags: []string{"evening"}})
+	ix.Update("missing", ganache.AssetUpdate{Title: "ignored"})
+
+	if res := ix.Search(mustParse(t, "dawn"), 1, 10); res.Total != 0 {
+		t.Fatalf("old title tokens should be gone")
+	}
+	if res := ix.Search(mustParse(t, "tag:evening dusk"), 1, 10); res.Total != 1 {
+		t.Fatalf("expected updated asset to match")
+	}
+	if _, ok := ix.Get("missing"); ok {
+		t.Fatalf("update must not create unknown assets")
+	}
+	ix.Remove("1")
+	if st := ix.Status(); st.Assets != 0 || len(ix.postings) != 0 {
+		t.Fatalf("expected empty index, got %+v with %d tokens", st, len(ix.postings))
+	}
+}
@@ -0,0 +1,281 @@
+package index
+
+import (
+	"sort"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/query"
+)
+
+const (
+	matchExact  = 3
+	matchPrefix = 2
+	matchFuzzy  = 1
+
+	maxTagFacets     = 20
+	maxFieldFacets   = 10
+	maxMonthFacets   = 12
+	monthFacetFormat = "2006-01"
+)
+
+type Count struct {
+	Value string
+	Count int
+}
+
+type Facets struct {
+	Tags    []Count
+	Credits []Count
+	Sources []Count
+	// Months are creation months formatted as 2006-01, newest first.
+	Months []Count
+}
+
+type Result struct {
+	Assets []ganache.Asset
+	Total  int
+	Facets Facets
+}
+
+// Search answers q from the index. Free text and title:/caption: terms
+// match tokens exactly, by prefix, or within one or two typos depending on
+// length; everything else in q is applied as in query.Match. Facets count
+// every match, not just the returned page. Results are ordered by q.Sort, or
+// by relevance when q has text and no sort.
+func (ix *Index) Search(q query.Query, page, pageSize int) Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	scores, restricted := map[string]int(nil), false
+	narrow := func(text string, mask field) {
+		for _, tok := range tokenize(text) {
+			hits := ix.lookup(tok, mask)
+			if !restricted {
+				scores, restricted = hits, true
+				continue
+			}
+			for id := range scores {
+				if s, ok := hits[id]; ok {
+					scores[id] += s
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+	}
+	for _, t := range q.Terms {
+		narrow(t, allFields)
+	}
+	for _, t := range q.Title {
+		narrow(t, fieldTitle)
+	}
+	for _, t := range q.Caption {
+		narrow(t, fieldCaption)
+	}
+
+	rest := q
+	rest.Title, rest.Caption = nil, nil
+	var matches []ganache.Asset
+	consider := func(a ganache.Asset) {
+		if hasTags(a, q.Tags) && rest.Match(a) && hasPhrases(a, q.Terms) {
+			matches = append(matches, a)
+		}
+	}
+	if restricted {
+		for id := range scores {
+			consider(ix.docs[id])
+		}
+	} else {
+		for _, a := range ix.docs {
+			consider(a)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if q.Sort == "" && restricted {
+			if sa, sb := scores[string(a.ID)], scores[string(b.ID)]; sa != sb {
+				return sa > sb
+			}
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			if q.Sort == "oldest" {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	res := Result{Total: len(matches), Facets: facets(matches)}
+	start := (page - 1) * pageSize
+	if page < 1 || start >= len(matches) {
+		return res
+	}
+	end := start + pageSize
+	if end > len(matches) {
+		end = len(matches)
+	}
+	res.Assets = append([]ganache.Asset(nil), matches[start:end]...)
+	return res
+}
+
+// lookup scores every document holding a token close to tok in one of the
+// fields in mask.
+func (ix *Index) lookup(tok string, mask field) map[string]int {
+	hits := make(map[string]int)
+	for term, ids := range ix.postings {
+		kind := matchKind(tok, term)
+		if kind == 0 {
+			continue
+		}
+		for id, f := range ids {
+			if f&mask == 0 {
+				continue
+			}
+			if s := kind * (f & mask).weight(); s > hits[id] {
+				hits[id] = s
+			}
+		}
+	}
+	return hits
+}
+
+func matchKind(tok, term string) int {
+	switch {
+	case tok == term:
+		return matchExact
+	case len(tok) >= 3 && strings.HasPrefix(term, tok):
+		return matchPrefix
+	}
+	allowed := 0
+	switch n := len([]rune(tok)); {
+	case n >= 8:
+		allowed = 2
+	case n >= 4:
+		allowed = 1
+	}
+	if allowed > 0 && withinDistance(tok, term, allowed) {
+		return matchFuzzy
+	}
+	return 0
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b
+// is at most max.
+func withinDistance(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return false
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			best = min(best, cur[j])
+		}
+		if best > max {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= max
+}
+
+func hasTags(a ganache.Asset, tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, t := range a.Tags {
+			if strings.EqualFold(t, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPhrases checks multi-word terms appear as typed in some field; the
+// token lookup alone only guarantees each word is present somewhere.
+func hasPhrases(a ganache.Asset, terms []string) bool {
+	for _, t := range terms {
+		if len(tokenize(t)) < 2 {
+			continue
+		}
+		phrase := strings.Join(tokenize(t), " ")
+		fields := append([]string{a.Title, a.Caption, a.Credit, a.Source, a.UsageNotes}, a.Tags...)
+		found := false
+		for _, f := range fields {
+			if strings.Contains(strings.Join(tokenize(f), " "), phrase) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func facets(assets []ganache.Asset) Facets {
+	tags, credits, sources, months := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+	for _, a := range assets {
+		for _, t := range a.Tags {
+			tags[t]++
+		}
+		if a.Credit != "" {
+			credits[a.Credit]++
+		}
+		if a.Source != "" {
+			sources[a.Source]++
+		}
+		if !a.CreatedAt.IsZero() {
+			months[a.CreatedAt.UTC().Format(monthFacetFormat)]++
+		}
+	}
+	f := Facets{
+		Tags:    topCounts(tags, maxTagFacets),
+		Credits: topCounts(credits, maxFieldFacets),
+		Sources: topCounts(sources, maxFieldFacets),
+	}
+	for m, n := range months {
+		f.Months = append(f.Months, Count{Value: m, Count: n})
+	}
+	sort.Slice(f.Months, func(i, j int) bool { return f.Months[i].Value > f.Months[j].Value })
+	if len(f.Months) > maxMonthFacets {
+		f.Months = f.Months[:maxMonthFacets]
+	}
+	return f
+}
+
+func topCounts(counts map[string]int, limit int) []Count {
+	list := make([]Count, 0, len(counts))
+	for v, n := range counts {
+		list = append(list, Count{Value: v, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/query"
+)
+
+func mustParse(t *testing.T, s string) query.Query {
+	t.Helper()
+	q, err := query.Parse(s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return q
+}
+
+func testIndex(t *testing.T) *Index {
+	ix, _ := Open(filepath.Join(t.TempDir(), "index.json"))
+	at := func(month int) time.Time { return time.Date(2024, time.Month(month), 10, 0, 0, 0, 0, time.UTC) }
+	ix.Put(ganache.Asset{ID: "1", Title: "Championship final", Caption: "Penalty shootout", Credit: "AP", Source: "Wire", Tags: []string{"football", "final"}, CreatedAt: at(1)})
+	ix.Put(ganache.Asset{ID: "2", Title: "Harbour", Caption: "Championship trophy on display", Credit: "Reuters", Tags: []string{"football"}, CreatedAt: at(2)})
+	ix.Put(ganache.Asset{ID: "3", Title: "Marathon start", Credit: "AP", Source: "Staff", Tags: []string{"athletics"}, CreatedAt: at(3)})
+	return ix
+}
+
+func ids(res Result) string {
+	s := ""
+	for _, a := range res.Assets {
+		s += string(a.ID)
+	}
+	return s
+}
+
+func TestSearchTypoTolerantAndRanked(t *testing.T) {
+	ix := testIndex(t)
+	cases := map[string]string{
+		"championship":             "12", // title hit ranks above caption hit
+		"champoinship":             "12", // two transposed letters
+		"marthon":                  "3",
+		"champ":                    "12",
+		"title:championship":       "1",
+		"caption:championship":     "2",
+		`"penalty shootout"`:       "1",
+		`"shootout penalty"`:       "",
+		"credit:ap -tag:final":     "3",
+		"tag:football sort:oldest": "12",
+		"after:2024-02-01":         "32",
+	}
+	for q, want := range cases {
+		if got := ids(ix.Search(mustParse(t, q), 1, 10)); got != want {
+			t.Errorf("%q: got %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestSearchFacetsAndPaging(t *testing.T) {
+	ix := testIndex(t)
+	res := ix.Search(mustParse(t, ""), 2, 2)
+	if res.Total != 3 || ids(res) != "1" {
+		t.Fatalf("unexpected page 2: total %d ids %q", res.Total, ids(res))
+	}
+	f := res.Facets
+	if f.Tags[0] != (Count{Value: "football", Count: 2}) || f.Credits[0] != (Count{Value: "AP", Count: 2}) {
+		t.Fatalf("unexpected facets %+v", f)
+	}
+	if len(f.Months) != 3 || f.Months[0].Value != "2024-03" || len(f.Sources) != 2 {
+		t.Fatalf("unexpected month/source facets %+v", f)
+	}
+}
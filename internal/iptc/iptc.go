@@ -0,0 +1,163 @@
+// Package iptc reads the IPTC-IIM caption fields that scanners and photo
+// desks embed in JPEG files, in the Photoshop APP13 segment.
+package iptc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// Application record (2) datasets that map to asset fields.
+const (
+	dsObjectName   = 5
+	dsKeywords     = 25
+	dsInstructions = 40
+	dsCredit       = 110
+	dsSource       = 115
+	dsCaption      = 120
+)
+
+var photoshopSig = []byte("Photoshop 3.0\x00")
+
+// Read returns the IPTC fields of a JPEG. Files that are not JPEGs, or
+// carry no IPTC block, yield an empty update rather than an error; an
+// error means the JPEG structure itself is broken.
+func Read(r io.Reader) (ganache.AssetUpdate, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return ganache.AssetUpdate{}, nil
+	}
+	for {
+		marker, err := nextMarker(br)
+		if err != nil {
+			return ganache.AssetUpdate{}, err
+		}
+		// Start of scan or end of image: metadata segments come before.
+		if marker == 0xDA || marker == 0xD9 {
+			return ganache.AssetUpdate{}, nil
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			return ganache.AssetUpdate{}, err
+		}
+		n := int(binary.BigEndian.Uint16(size[:])) - 2
+		if n < 0 {
+			return ganache.AssetUpdate{}, errors.New("iptc: bad segment length")
+		}
+		if marker != 0xED {
+			if _, err := br.Discard(n); err != nil {
+				return ganache.AssetUpdate{}, err
+			}
+			continue
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(br, seg); err != nil {
+			return ganache.AssetUpdate{}, err
+		}
+		if block := iptcBlock(seg); block != nil {
+			return parseIIM(block), nil
+		}
+	}
+}
+
+func nextMarker(br *bufio.Reader) (byte, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errors.New("iptc: expected a JPEG marker")
+	}
+	// Markers may be padded with extra 0xFF bytes.
+	for b == 0xFF {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// iptcBlock finds the IPTC resource (0x0404) among the Photoshop image
+// resources of an APP13 segment.
+func iptcBlock(seg []byte) []byte {
+	if !bytes.HasPrefix(seg, photoshopSig) {
+		return nil
+	}
+	p := seg[len(photoshopSig):]
+	for len(p) >= 12 && string(p[:4]) == "8BIM" {
+		id := binary.BigEndian.Uint16(p[4:6])
+		nameLen := int(p[6])
+		// The Pascal name, with its length byte, is padded to an even size.
+		skip := 6 + (1+nameLen+1)&^1
+		if len(p) < skip+4 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(p[skip : skip+4]))
+		data := p[skip+4:]
+		if size > len(data) {
+			return nil
+		}
+		if id == 0x0404 {
+			return data[:size]
+		}
+		next := (size + 1) &^ 1
+		if next > len(data) {
+			return nil
+		}
+		p = data[next:]
+	}
+	return nil
+}
+
+// parseIIM decodes the datasets of an IIM block. Values are read as UTF-8,
+// falling back to Latin-1, which older scanners write.
+func parseIIM(block []byte) ganache.AssetUpdate {
+	var m ganache.AssetUpdate
+	for len(block) >= 5 && block[0] == 0x1C {
+		record, dataset := block[1], block[2]
+		size := int(binary.BigEndian.Uint16(block[3:5]))
+		// Extended datasets (high bit set) are never caption fields.
+		if size&0x8000 != 0 || len(block) < 5+size {
+			break
+		}
+		v := strings.TrimSpace(decode(block[5 : 5+size]))
+		block = block[5+size:]
+		if record != 2 || v == "" {
+			continue
+		}
+		switch dataset {
+		case dsObjectName:
+			m.Title = v
+		case dsCaption:
+			m.Caption = v
+		case dsCredit:
+			m.Credit = v
+		case dsSource:
+			m.Source = v
+		case dsInstructions:
+			m.UsageNotes = v
+		case dsKeywords:
+			m.Tags = append(m.Tags, v)
+		}
+	}
+	return m
+}
+
+func decode(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,81 @@
+package iptc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func dataset(ds byte, v string) []byte {
+	b := []byte{0x1C, 2, ds, 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(v)))
+	return append(b, v...)
+}
+
+// jpegWithIPTC builds a minimal JPEG header with an APP1 segment, then an
+// APP13 segment holding an unrelated resource and the IPTC block.
+func jpegWithIPTC(iim []byte) []byte {
+	var res bytes.Buffer
+	res.WriteString("Photoshop 3.0\x00")
+	res.WriteString("8BIM")
+	res.Write([]byte{0x03, 0xED, 0, 0, 0, 0, 0, 3, 1, 2, 3, 0})
+	res.WriteString("8BIM")
+	res.Write([]byte{0x04, 0x04, 0, 0})
+	binary.Write(&res, binary.BigEndian, uint32(len(iim)))
+	res.Write(iim)
+
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 6, 'E', 'x', 'i', 'f'})
+	b.Write([]byte{0xFF, 0xED})
+	binary.Write(&b, binary.BigEndian, uint16(res.Len()+2))
+	b.Write(res.Bytes())
+	b.Write([]byte{0xFF, 0xDA, 0, 2, 0xFF, 0xD9})
+	return b.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	var iim []byte
+	iim = append(iim, 0x1C, 1, 90, 0, 3, 0x1B, '%', 'G')
+	iim = append(iim, dataset(dsObjectName, "Final")...)
+	iim = append(iim, dataset(dsCaption, "Fans celebrate")...)
+	iim = append(iim, dataset(dsCredit, "AP")...)
+	iim = append(iim, dataset(dsSource, "Scanner 2")...)
+	iim = append(iim, dataset(dsInstructions, "Editorial only")...)
+	iim = append(iim, dataset(dsKeywords, "cup")...)
+	iim = append(iim, dataset(dsKeywords, "final")...)
+	m, err := Read(bytes.NewReader(jpegWithIPTC(iim)))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if m.Title != "Final" || m.Caption != "Fans celebrate" || m.Credit != "AP" || m.Source != "Scanner 2" ||
+		m.UsageNotes != "Editorial only" || strings.Join(m.Tags, ",") != "cup,final" {
+		t.Fatalf("unexpected %+v", m)
+	}
+}
+
+func TestReadLatin1(t *testing.T) {
+	m, err := Read(bytes.NewReader(jpegWithIPTC(dataset(dsCredit, "Agence Fran\xe7aise"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Credit != "Agence Française" {
+		t.Fatalf("got %q", m.Credit)
+	}
+}
+
+func TestReadWithoutIPTC(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"png":       []byte("\x89PNG\r\n\x1a\n"),
+		"bare jpeg": {0xFF, 0xD8, 0xFF, 0xDA, 0, 2, 0xFF, 0xD9},
+		"empty":     nil,
+	} {
+		m, err := Read(bytes.NewReader(data))
+		if err != nil || m.Title != "" || m.Tags != nil {
+			t.Fatalf("%s: expected an empty update, got %+v, %v", name, m, err)
+		}
+	}
+	if _, err := Read(bytes.NewReader([]byte{0xFF, 0xD8, 0x00})); err == nil {
+		t.Fatal("expected an error for a broken JPEG")
+	}
+}
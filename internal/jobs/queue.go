@@ -0,0 +1,457 @@
+// Package jobs runs long-lived work, such as queued uploads and bulk
+// edits, in the background. Jobs are split into items that are processed by
+// a bounded worker pool and retried with backoff; the whole queue is
+// persisted so work survives a restart.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+const finishedRetention = 7 * 24 * time.Hour
+
+type Item struct {
+	Key           string          `json:"key"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        Status          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	Error         string          `json:"error,omitempty"`
+	Result        string          `json:"result,omitempty"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt,omitempty"`
+	// Interrupted is set on an item that was running when the process
+	// stopped without finishing it. The item runs again; a handler whose
+	// work is not safe to repeat can fail it instead.
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+type Job struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Owner     string    `json:"owner"`
+	Title     string    `json:"title"`
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Items     []Item    `json:"items"`
+}
+
+type Progress struct {
+	Total     int
+	Succeeded int
+	Failed    int
+}
+
+func (p Progress) Done() int {
+	return p.Succeeded + p.Failed
+}
+
+func (p Progress) Percent() int {
+	if p.Total == 0 {
+		return 100
+	}
+	return p.Done() * 100 / p.Total
+}
+
+func (j Job) Progress() Progress {
+	p := Progress{Total: len(j.Items)}
+	for _, it := range j.Items {
+		switch it.Status {
+		case StatusSucceeded:
+			p.Succeeded++
+		case StatusFailed:
+			p.Failed++
+		}
+	}
+	return p
+}
+
+// Handler processes one item. The returned string is kept as the item's
+// result (for example the ID of a created asset).
+type Handler func(ctx context.Context, job Job, item Item) (string, error)
+
+// Cleanup is called when a finished job is pruned from the queue, so the
+// job kind can release resources such as staged files kept for retries.
+type Cleanup func(job Job)
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a validation failure.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+type Options struct {
+	Concurrency int
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+type Queue struct {
+	path string
+	opts Options
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	handlers map[string]Handler
+	cleanups map[string]Cleanup
+	subs     map[chan Job]struct{}
+	wake     chan struct{}
+}
+
+func NewQueue(path string, opts Options) (*Queue, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 5 * time.Second
+	}
+	q := &Queue{
+		path:     path,
+		opts:     opts,
+		jobs:     make(map[string]*Job),
+		handlers: make(map[string]Handler),
+		cleanups: make(map[string]Cleanup),
+		subs:     make(map[chan Job]struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+	var saved []*Job
+	if err := filestore.ReadJSON(path, &saved); err != nil {
+		return nil, err
+	}
+	for _, j := range saved {
+		// Items interrupted by a restart are run again.
+		for i := range j.Items {
+			if j.Items[i].Status == StatusRunning {
+				j.Items[i].Status = StatusQueued
+				j.Items[i].Interrupted = true
+			}
+		}
+		q.jobs[j.ID] = j
+	}
+	return q, nil
+}
+
+func (q *Queue) Register(kind string, h Handler, cleanup Cleanup) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+	if cleanup != nil {
+		q.cleanups[kind] = cleanup
+	}
+}
+
+func (q *Queue) Enqueue(kind, owner, title string, items []Item) (Job, error) {
+	if len(items) == 0 {
+		return Job{}, errors.New("job has no items")
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	job := &Job{
+		ID:        hex.EncodeToString(buf),
+		Kind:      kind,
+		Owner:     owner,
+		Title:     title,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Items:     make([]Item, len(items)),
+	}
+	for i, it := range items {
+		it.Status = StatusQueued
+		job.Items[i] = it
+	}
+
+	q.mu.Lock()
+	if _, ok := q.handlers[kind]; !ok {
+		q.mu.Unlock()
+		return Job{}, fmt.Errorf("unknown job kind %q", kind)
+	}
+	q.jobs[job.ID] = job
+	err := q.saveLocked()
+	snapshot := cloneJob(job)
+	q.mu.Unlock()
+	if err != nil {
+		return Job{}, err
+	}
+	q.publish(snapshot)
+	q.notify()
+	return snapshot, nil
+}
+
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return cloneJob(j), true
+}
+
+// List returns the jobs owned by owner, newest first. An empty owner lists
+// every job.
+func (q *Queue) List(owner string) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var list []Job
+	for _, j := range q.jobs {
+		if owner == "" || j.Owner == owner {
+			list = append(list, cloneJob(j))
+		}
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.After(list[b].CreatedAt) })
+	return list
+}
+
+// Retry re-queues the failed items of a finished job.
+func (q *Queue) Retry(id string) error {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return errors.New("job not found")
+	}
+	retried := false
+	for i := range j.Items {
+		if j.Items[i].Status == StatusFailed {
+			j.Items[i].Status = StatusQueued
+			j.Items[i].Attempts = 0
+			j.Items[i].Error = ""
+			j.Items[i].NextAttemptAt = time.Time{}
+			j.Items[i].Interrupted = false
+			retried = true
+		}
+	}
+	if !retried {
+		q.mu.Unlock()
+		return errors.New("job has no failed items")
+	}
+	j.Status = StatusQueued
+	j.UpdatedAt = time.Now().UTC()
+	err := q.saveLocked()
+	snapshot := cloneJob(j)
+	q.mu.Unlock()
+	q.publish(snapshot)
+	q.notify()
+	return err
+}
+
+// Subscribe returns a channel of job snapshots, sent whenever a job
+// changes. Slow subscribers miss updates rather than blocking workers.
+func (q *Queue) Subscribe() (<-chan Job, func()) {
+	ch := make(chan Job, 32)
+	q.mu.Lock()
+	q.subs[ch] = struct{}{}
+	q.mu.Unlock()
+	return ch, func() {
+		q.mu.Lock()
+		delete(q.subs, ch)
+		q.mu.Unlock()
+	}
+}
+
+// Run starts the worker pool and blocks until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.worker(ctx)
+		}()
+	}
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-prune.C:
+			q.prune()
+		}
+	}
+}
+
+func (q *Queue) worker(ctx context.Context) {
+	for {
+		job, idx, h, ok := q.claim()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		result, err := h(ctx, job, job.Items[idx])
+		q.complete(job.ID, idx, result, err)
+		q.notify()
+	}
+}
+
+func (q *Queue) claim() (Job, int, Handler, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var oldest *Job
+	idx := -1
+	for _, j := range q.jobs {
+		if oldest != nil && !j.CreatedAt.Before(oldest.CreatedAt) {
+			continue
+		}
+		if _, ok := q.handlers[j.Kind]; !ok {
+			continue
+		}
+		for i, it := range j.Items {
+			if it.Status == StatusQueued && !now.Before(it.NextAttemptAt) {
+				oldest, idx = j, i
+				break
+			}
+		}
+	}
+	if oldest == nil {
+		return Job{}, 0, nil, false
+	}
+	oldest.Items[idx].Status = StatusRunning
+	oldest.Items[idx].Attempts++
+	oldest.Status = StatusRunning
+	oldest.UpdatedAt = time.Now().UTC()
+	return cloneJob(oldest), idx, q.handlers[oldest.Kind], true
+}
+
+func (q *Queue) complete(id string, idx int, result string, err error) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return
+	}
+	it := &j.Items[idx]
+	it.Interrupted = false
+	switch {
+	case err == nil:
+		it.Status = StatusSucceeded
+		it.Result = result
+		it.Error = ""
+	case errors.As(err, new(permanentError)) || it.Attempts >= q.opts.MaxAttempts:
+		it.Status = StatusFailed
+		it.Error = err.Error()
+	default:
+		it.Status = StatusQueued
+		it.Error = err.Error()
+		it.NextAttemptAt = time.Now().Add(q.opts.RetryDelay << (it.Attempts - 1))
+	}
+	j.UpdatedAt = time.Now().UTC()
+	j.refreshStatus()
+	if serr := q.saveLocked(); serr != nil {
+		slog.Error("jobs: save", "error", serr)
+	}
+	snapshot := cloneJob(j)
+	q.mu.Unlock()
+	q.publish(snapshot)
+}
+
+// refreshStatus derives the job status from its items.
+func (j *Job) refreshStatus() {
+	p := j.Progress()
+	switch {
+	case p.Done() < p.Total:
+		j.Status = StatusRunning
+	case p.Failed > 0:
+		j.Status = StatusFailed
+	default:
+		j.Status = StatusSucceeded
+	}
+}
+
+func (q *Queue) prune() {
+	q.mu.Lock()
+	cutoff := time.Now().Add(-finishedRetention)
+	var removed []Job
+	for id, j := range q.jobs {
+		if (j.Status == StatusSucceeded || j.Status == StatusFailed) && j.UpdatedAt.Before(cutoff) {
+			removed = append(removed, cloneJob(j))
+			delete(q.jobs, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := q.saveLocked(); err != nil {
+			slog.Error("jobs: save", "error", err)
+		}
+	}
+	cleanups := q.cleanups
+	q.mu.Unlock()
+
+	for _, j := range removed {
+		if cleanup := cleanups[j.Kind]; cleanup != nil {
+			cleanup(j)
+		}
+	}
+}
+
+func (q *Queue) saveLocked() error {
+	list := make([]*Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		list = append(list, j)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return filestore.WriteJSON(q.path, list)
+}
+
+func (q *Queue) publish(j Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ch := range q.subs {
+		select {
+		case ch <- j:
+		default:
+		}
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func cloneJob(j *Job) Job {
+	c := *j
+	c.Items = append([]Item(nil), j.Items...)
+	return c
+}
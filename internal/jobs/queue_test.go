@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, q *Queue, id string, want Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := q.Get(id); ok && j.Status == want {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	j, _ := q.Get(id)
+	t.Fatalf("job %s did not reach %s: %+v", id, want, j)
+	return Job{}
+}
+
+func TestQueueRunsItemsWithBoundedConcurrency(t *testing.T) {
+	q, err := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), Options{Concurrency: 2, MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	var running, peak int32
+	q.Register("work", func(ctx context.Context, job Job, item Item) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "done " + item.Key, nil
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	items := make([]Item, 6)
+	for i := range items {
+		items[i] = Item{Key: string(rune('a' + i))}
+	}
+	job, err := q.Enqueue("work", "alice", "six things", items)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	done := waitFor(t, q, job.ID, StatusSucceeded)
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent items, saw %d", peak)
+	}
+	if done.Items[0].Result != "done a" || done.Progress().Percent() != 100 {
+		t.Fatalf("unexpected result: %+v", done.Items[0])
+	}
+}
+
+func TestQueueRetriesTransientAndStopsOnPermanent(t *testing.T) {
+	q, _ := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), Options{Concurrency: 1, MaxAttempts: 3, RetryDelay: time.Millisecond})
+	var calls int32
+	q.Register("flaky", func(ctx context.Context, job Job, item Item) (string, error) {
+		if item.Key == "bad" {
+			return "", Permanent(errors.New("invalid file"))
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return "", errors.New("ganache unavailable")
+		}
+		return "ok", nil
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	job, _ := q.Enqueue("flaky", "alice", "mixed", []Item{{Key: "good"}, {Key: "bad"}})
+	done := waitFor(t, q, job.ID, StatusFailed)
+	if done.Items[0].Status != StatusSucceeded || done.Items[0].Attempts != 3 {
+		t.Fatalf("expected success on third attempt: %+v", done.Items[0])
+	}
+	if done.Items[1].Attempts != 1 || done.Items[1].Error != "invalid file" {
+		t.Fatalf("permanent error should not retry: %+v", done.Items[1])
+	}
+
+	if err := q.Retry(job.ID); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	again := waitFor(t, q, job.ID, StatusFailed)
+	if again.Items[1].Attempts != 1 {
+		t.Fatalf("expected retried item to run again: %+v", again.Items[1])
+	}
+}
+
+func TestQueuePersistsAndResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, _ := NewQueue(path, Options{})
+	q.Register("work", func(ctx context.Context, job Job, item Item) (string, error) { return "ok", nil }, nil)
+	job, err := q.Enqueue("work", "alice", "later", []Item{{Key: "x"}})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	restarted, err := NewQueue(path, Options{})
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := restarted.List("alice"); len(list) != 1 || list[0].ID != job.ID {
+		t.Fatalf("expected persisted job, got %+v", list)
+	}
+	if list := restarted.List("bob"); len(list) != 0 {
+		t.Fatalf("expected jobs filtered by owner")
+	}
+	restarted.Register("work", func(ctx context.Context, job Job, item Item) (string, error) { return "ok", nil }, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+	waitFor(t, restarted, job.ID, StatusSucceeded)
+}
+
+func TestQueueSubscribeReceivesUpdates(t *testing.T) {
+	q, _ := NewQueue(filepath.Join(t.TempDir(), "jobs.json"), Options{})
+	q.Register("work", func(ctx context.Context, job Job, item Item) (string, error) { return "", nil }, nil)
+	updates, cancelSub := q.Subscribe()
+	defer cancelSub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	job, _ := q.Enqueue("work", "alice", "watch", []Item{{Key: "x"}})
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case j := <-updates:
+			if j.ID == job.ID && j.Status == StatusSucceeded {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no completion update received")
+		}
+	}
+}
+
+func TestQueueMarksItemsInterruptedByARestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, _ := NewQueue(path, Options{})
+	q.Register("work", func(ctx context.Context, job Job, item Item) (string, error) { return "ok", nil }, nil)
+	job, _ := q.Enqueue("work", "alice", "crash", []Item{{Key: "x"}})
+	// Stop as if the process died while the item was running.
+	q.claim()
+	q.mu.Lock()
+	q.saveLocked()
+	q.mu.Unlock()
+
+	restarted, _ := NewQueue(path, Options{})
+	var seen Item
+	restarted.Register("work", func(ctx context.Context, job Job, item Item) (string, error) {
+		seen = item
+		return "ok", nil
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+	done := waitFor(t, restarted, job.ID, StatusSucceeded)
+	if !seen.Interrupted {
+		t.Fatalf("expected the handler to be told about the restart")
+	}
+	if done.Items[0].Interrupted {
+		t.Fatalf("flag should clear once the item has run: %+v", done.Items[0])
+	}
+}
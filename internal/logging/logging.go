@@ -0,0 +1,196 @@
+// Package logging writes the UI's logs as JSON through log/slog. Every
+// request gets an ID, accepted from X-Request-ID or generated, which is
+// sent back in the response, forwarded to Ganache and added to each line
+// logged with the request's context, along with the signed-in user.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Header carries the request ID, both from clients and to Ganache.
+const Header = "X-Request-ID"
+
+// maxErrorLength bounds the part of an error response that is logged.
+const maxErrorLength = 1024
+
+// New returns a JSON logger writing to w that adds the request ID and
+// user to records logged with a request's context.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := requestFrom(ctx); info != nil {
+		r.AddAttrs(slog.String("request_id", info.id))
+		if info.user != "" && !hasAttr(r, "user") {
+			r.AddAttrs(slog.String("user", info.user))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// hasAttr reports whether r has an attribute named key. Code shared with
+// background jobs logs the user it acts for itself.
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestKey struct{}
+
+// request is shared by everything that handles one request. The user is
+// only known once the session has been read, deeper in the router than
+// where the request is logged, so it is set in place.
+type request struct {
+	id   string
+	user string
+}
+
+func requestFrom(ctx context.Context) *request {
+	info, _ := ctx.Value(requestKey{}).(*request)
+	return info
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	if info := requestFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetUser records the signed-in user of the request ctx belongs to.
+func SetUser(ctx context.Context, user string) {
+	if info := requestFrom(ctx); info != nil {
+		info.user = user
+	}
+}
+
+// validID reports whether a client-supplied request ID is safe to log and
+// forward: up to 128 letters, digits and the punctuation of common ID
+// formats.
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware gives each request an ID and logs it once served with its
+// route, status, size, latency and user. It must be used on a chi router,
+// since it logs the pattern of the route that served the request. The
+// body of a server error is logged, so errors from Ganache are kept after
+// they are sent to the browser, and plain-text error responses end with
+// the request ID for users to quote.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(Header)
+		if !validID(id) {
+			id = rand.Text()
+		}
+		info := &request{id: id}
+		r = r.WithContext(context.WithValue(r.Context(), requestKey{}, info))
+		w.Header().Set(Header, id)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		errBody := &errorBody{ww: ww}
+		ww.Tee(errBody)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(errBody.buf.String())))
+		}
+		if status >= 400 && strings.HasPrefix(ww.Header().Get("Content-Type"), "text/plain") {
+			fmt.Fprintf(ww, "request id: %s\n", id)
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// errorBody keeps the start of a server error response.
+type errorBody struct {
+	ww  middleware.WrapResponseWriter
+	buf bytes.Buffer
+}
+
+func (e *errorBody) Write(p []byte) (int, error) {
+	if e.ww.Status() >= 500 && e.buf.Len() < maxErrorLength {
+		e.buf.Write(p[:min(len(p), maxErrorLength-e.buf.Len())])
+	}
+	return len(p), nil
+}
+
+// Transport forwards the request ID to Ganache, for
+// ganache.Client.WrapTransport.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		if id := RequestID(req.Context()); id != "" && req.Header.Get(Header) == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set(Header, id)
+		}
+		return rt.RoundTrip(req)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
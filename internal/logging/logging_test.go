@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// capture makes the default logger write to a buffer for the test.
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(New(&buf, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	return &buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("not JSON: %q: %v", l, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestMiddlewareLogsRequest(t *testing.T) {
+	buf := capture(t)
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r.Context(), "tester")
+		slog.InfoContext(r.Context(), "looked up")
+		io.WriteString(w, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/7", nil)
+	req.Header.Set(Header, "abc-123")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(Header); got != "abc-123" {
+		t.Fatalf("expected the client's request ID back, got %q", got)
+	}
+	logged := lines(t, buf)
+	if len(logged) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(logged))
+	}
+	for _, l := range logged {
+		if l["request_id"] != "abc-123" || l["user"] != "tester" {
+			t.Fatalf("expected the request ID and user on every line, got %v", l)
+		}
+	}
+	served := logged[1]
+	if served["msg"] != "request" || served["route"] != "/assets/{id}" || served["status"] != float64(200) || served["level"] != "INFO" {
+		t.Fatalf("unexpected request line %v", served)
+	}
+	if _, ok := served["duration_ms"].(float64); !ok {
+		t.Fatalf("expected a latency, got %v", served)
+	}
+}
+
+func TestMiddlewareGeneratesID(t *testing.T) {
+	capture(t)
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	var seen string
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) { seen = RequestID(r.Context()) })
+
+	for _, sent := range []string{"", "bad id\nwith newline", strings.Repeat("x", 200)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if sent != "" {
+			req.Header.Set(Header, sent)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		got := rec.Header().Get(Header)
+		if got == "" || got == sent || got != seen {
+			t.Fatalf("sent %q: expected a new ID, got %q (handler saw %q)", sent, got, seen)
+		}
+	}
+}
+
+func TestMiddlewareLogsServerErrors(t *testing.T) {
+	buf := capture(t)
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ganache: 500 storage offline", http.StatusBadGateway)
+	})
+	r.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(Header, "incident-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if body := rec.Body.String(); body != "ganache: 500 storage offline\nrequest id: incident-1\n" {
+		t.Fatalf("expected the request ID after the error, got %q", body)
+	}
+	l := lines(t, buf)[0]
+	if l["level"] != "ERROR" || l["error"] != "ganache: 500 storage offline" {
+		t.Fatalf("expected the error to be logged, got %v", l)
+	}
+
+	buf.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	l = lines(t, buf)[0]
+	if l["level"] != "INFO" || l["error"] != nil {
+		t.Fatalf("client errors should not be logged as errors, got %v", l)
+	}
+}
+
+func TestTransportForwardsID(t *testing.T) {
+	var got string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(Header)
+	}))
+	t.Cleanup(backend.Close)
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	ctx := context.WithValue(context.Background(), requestKey{}, &request{id: "abc-123"})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if got != "abc-123" {
+		t.Fatalf("expected the request ID to be forwarded, got %q", got)
+	}
+	if req.Header.Get(Header) != "" {
+		t.Fatal("the caller's request should not be modified")
+	}
+}
@@ -0,0 +1,345 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errMalformed = errors.New("malformed structure")
+
+// checkComplete reports whether an image of the given format, as named by
+// image.DecodeConfig, runs to its end. Rather than decoding the pixels,
+// which would allocate the whole raster, it walks the file's structure to
+// the format's end marker or checks the sizes its header declares. A
+// truncated file gives io.ErrUnexpectedEOF. Formats without a check pass.
+func checkComplete(r io.ReadSeeker, format string) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if err := rewind(r); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, 64<<10)
+	switch format {
+	case "png":
+		err = pngComplete(br)
+	case "jpeg":
+		err = jpegComplete(br)
+	case "gif":
+		err = gifComplete(br)
+	case "webp":
+		err = webpComplete(br, size)
+	case "bmp":
+		err = bmpComplete(br, size)
+	case "tiff":
+		err = tiffComplete(r, size)
+	}
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// pngComplete skips chunk by chunk to IEND.
+func pngComplete(r *bufio.Reader) error {
+	if _, err := r.Discard(8); err != nil {
+		return err
+	}
+	var head [8]byte
+	for {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return err
+		}
+		if string(head[4:]) == "IEND" {
+			return nil
+		}
+		// Chunk data is followed by a 4-byte CRC.
+		if _, err := r.Discard(int(binary.BigEndian.Uint32(head[:4])) + 4); err != nil {
+			return err
+		}
+	}
+}
+
+// jpegComplete skips marker segments and scans entropy-coded data up to
+// the EOI marker. Data appended after EOI, such as the video of a motion
+// photo, is not read.
+func jpegComplete(r *bufio.Reader) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return err
+	}
+	if soi != [2]byte{0xFF, 0xD8} {
+		return errMalformed
+	}
+	marker, err := nextMarker(r)
+	for err == nil {
+		switch {
+		case marker == 0xD9: // EOI
+			return nil
+		case marker >= 0xD0 && marker <= 0xD7, marker == 0x01:
+			// Restart markers and TEM carry no length.
+			marker, err = nextMarker(r)
+			continue
+		}
+		var length [2]byte
+		if _, err = io.ReadFull(r, length[:]); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(length[:]))
+		if n < 2 {
+			return errMalformed
+		}
+		if _, err = r.Discard(n - 2); err != nil {
+			return err
+		}
+		if marker == 0xDA { // SOS: entropy-coded data follows
+			marker, err = scanEntropy(r)
+		} else {
+			marker, err = nextMarker(r)
+		}
+	}
+	return err
+}
+
+// nextMarker reads the marker that must come next, after any fill bytes.
+func nextMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errMalformed
+	}
+	for b == 0xFF {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// scanEntropy reads past entropy-coded data to the next marker that is not
+// a stuffed zero byte or a restart marker.
+func scanEntropy(r *bufio.Reader) (byte, error) {
+	for {
+		if _, err := r.ReadSlice(0xFF); err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				continue
+			}
+			return 0, err
+		}
+		b, err := r.ReadByte()
+		for err == nil && b == 0xFF {
+			b, err = r.ReadByte()
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b != 0x00 && (b < 0xD0 || b > 0xD7) {
+			return b, nil
+		}
+	}
+}
+
+// gifComplete skips blocks to the trailer.
+func gifComplete(r *bufio.Reader) error {
+	var head [13]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return err
+	}
+	if err := skipColorTable(r, head[10]); err != nil {
+		return err
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case 0x3B: // trailer
+			return nil
+		case 0x21: // extension
+			if _, err := r.ReadByte(); err != nil {
+				return err
+			}
+		case 0x2C: // image descriptor
+			var desc [9]byte
+			if _, err := io.ReadFull(r, desc[:]); err != nil {
+				return err
+			}
+			if err := skipColorTable(r, desc[8]); err != nil {
+				return err
+			}
+			// LZW minimum code size.
+			if _, err := r.ReadByte(); err != nil {
+				return err
+			}
+		default:
+			return errMalformed
+		}
+		if err := skipSubBlocks(r); err != nil {
+			return err
+		}
+	}
+}
+
+func skipColorTable(r *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	_, err := r.Discard(3 << ((flags & 0x07) + 1))
+	return err
+}
+
+func skipSubBlocks(r *bufio.Reader) error {
+	for {
+		n, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if _, err := r.Discard(int(n)); err != nil {
+			return err
+		}
+	}
+}
+
+// webpComplete checks the file is as long as its RIFF header says.
+func webpComplete(r *bufio.Reader, size int64) error {
+	var head [12]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return err
+	}
+	if size < 8+int64(binary.LittleEndian.Uint32(head[4:8])) {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// bmpComplete checks the file holds every row its header declares.
+func bmpComplete(r *bufio.Reader, size int64) error {
+	var head [34]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return err
+	}
+	offset := int64(binary.LittleEndian.Uint32(head[10:14]))
+	width := int64(int32(binary.LittleEndian.Uint32(head[18:22])))
+	height := int64(int32(binary.LittleEndian.Uint32(head[22:26])))
+	bpp := int64(binary.LittleEndian.Uint16(head[28:30]))
+	if height < 0 {
+		height = -height
+	}
+	rowSize := (bpp*width + 31) / 32 * 4
+	if size < offset+rowSize*height {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// TIFF tags locating the image data in the first IFD.
+const (
+	tiffStripOffsets    = 273
+	tiffStripByteCounts = 279
+	tiffTileOffsets     = 324
+	tiffTileByteCounts  = 325
+)
+
+// tiffComplete checks that every strip or tile of the first image lies
+// within the file.
+func tiffComplete(r io.ReadSeeker, size int64) error {
+	var head [8]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if bytes.HasPrefix(head[:], []byte("MM")) {
+		order = binary.BigEndian
+	}
+	ifd := int64(order.Uint32(head[4:]))
+	count, err := readAt(r, size, ifd, 2)
+	if err != nil {
+		return err
+	}
+	entries, err := readAt(r, size, ifd+2, 12*int64(order.Uint16(count)))
+	if err != nil {
+		return err
+	}
+	values := map[uint16][]int64{}
+	for e := entries; len(e) >= 12; e = e[12:] {
+		tag := order.Uint16(e[:2])
+		switch tag {
+		case tiffStripOffsets, tiffStripByteCounts, tiffTileOffsets, tiffTileByteCounts:
+		default:
+			continue
+		}
+		v, err := tiffValues(r, size, order, e)
+		if err != nil {
+			return err
+		}
+		values[tag] = v
+	}
+	offsets, counts := values[tiffStripOffsets], values[tiffStripByteCounts]
+	if offsets == nil {
+		offsets, counts = values[tiffTileOffsets], values[tiffTileByteCounts]
+	}
+	if len(offsets) != len(counts) {
+		return errMalformed
+	}
+	for i := range offsets {
+		if offsets[i]+counts[i] > size {
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return nil
+}
+
+// tiffValues reads the SHORT or LONG values of an IFD entry, held in the
+// entry itself when they fit in four bytes.
+func tiffValues(r io.ReadSeeker, size int64, order binary.ByteOrder, entry []byte) ([]int64, error) {
+	width := int64(0)
+	switch order.Uint16(entry[2:4]) {
+	case 3:
+		width = 2
+	case 4:
+		width = 4
+	default:
+		return nil, errMalformed
+	}
+	n := int64(order.Uint32(entry[4:8]))
+	data := entry[8:12]
+	if n*width > 4 {
+		var err error
+		if data, err = readAt(r, size, int64(order.Uint32(entry[8:12])), n*width); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]int64, n)
+	for i := range values {
+		if width == 2 {
+			values[i] = int64(order.Uint16(data[2*i:]))
+		} else {
+			values[i] = int64(order.Uint32(data[4*i:]))
+		}
+	}
+	return values, nil
+}
+
+// readAt reads n bytes at off, which must lie within the file.
+func readAt(r io.ReadSeeker, size, off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > size {
+		return nil, fmt.Errorf("%w: data at %d beyond end of file", io.ErrUnexpectedEOF, off)
+	}
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
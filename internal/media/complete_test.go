@@ -0,0 +1,72 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func encoded(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for x := 0; x < 64; x++ {
+		img.Set(x, x%48, color.RGBA{R: uint8(x * 4), G: 90, B: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckComplete(t *testing.T) {
+	webp := append([]byte("RIFF\x1a\x00\x00\x00WEBPVP8L"), make([]byte, 18)...)
+	cases := map[string][]byte{
+		"png":  encoded(t, png.Encode),
+		"jpeg": encoded(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) }),
+		"gif":  encoded(t, func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) }),
+		"bmp":  encoded(t, bmp.Encode),
+		"tiff": encoded(t, func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) }),
+		"webp": webp,
+	}
+	for format, data := range cases {
+		if err := checkComplete(bytes.NewReader(data), format); err != nil {
+			t.Fatalf("%s: complete file rejected: %v", format, err)
+		}
+		cut := data[:len(data)/2]
+		if err := checkComplete(bytes.NewReader(cut), format); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%s: expected truncation, got %v", format, err)
+		}
+	}
+}
+
+func TestCheckCompleteStopsAtJPEGEnd(t *testing.T) {
+	data := encoded(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) })
+	// Motion photos append a video after the JPEG's EOI marker.
+	data = append(data, bytes.Repeat([]byte{0xFF, 0x00, 0x42}, 1000)...)
+	if err := checkComplete(bytes.NewReader(data), "jpeg"); err != nil {
+		t.Fatalf("data after EOI should be ignored: %v", err)
+	}
+}
+
+func TestCheckCompleteFindsMissingTIFFStrips(t *testing.T) {
+	data := encoded(t, func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) })
+	// Drop part of the pixel data, which the encoder writes before the IFD,
+	// and move the IFD offset back so the directory still reads.
+	const dropped = 4096
+	cut := append(append([]byte{}, data[:8]...), data[8+dropped:]...)
+	binary.LittleEndian.PutUint32(cut[4:], binary.LittleEndian.Uint32(data[4:])-dropped)
+	if err := checkComplete(bytes.NewReader(cut), "tiff"); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected missing strips to be found, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const sniffLen = 512
+
+// DefaultAllowedTypes is the upload allowlist unless configured otherwise.
+var DefaultAllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+type Limits struct {
+	AllowedTypes []string
+	MaxWidth     int
+	MaxHeight    int
+	MaxPixels    int64
+}
+
+type Info struct {
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// ValidationError maps form field names to human readable problems.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %s", k, e.Fields[k]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func fieldError(field, format string, args ...any) error {
+	return &ValidationError{Fields: map[string]string{field: fmt.Sprintf(format, args...)}}
+}
+
+// SniffContentType detects the content type from the leading bytes of r,
+// ignoring whatever the client claimed. TIFF and QuickTime are detected
+// here because http.DetectContentType does not recognise them.
+func SniffContentType(r io.Reader) (string, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	buf = buf[:n]
+	if bytes.HasPrefix(buf, []byte("II*\x00")) || bytes.HasPrefix(buf, []byte("MM\x00*")) {
+		return "image/tiff", nil
+	}
+	if len(buf) >= 12 && string(buf[4:8]) == "ftyp" && string(buf[8:12]) == "qt  " {
+		return "video/quicktime", nil
+	}
+	ct := http.DetectContentType(buf)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return ct, nil
+}
+
+// Validate checks the upload in field "file" against the limits. Image
+// dimensions are read from the header and pixel data is never decoded, so
+// oversized images are rejected without allocating them.
+func Validate(r io.ReadSeeker, limits Limits) (Info, error) {
+	if n, err := io.CopyN(io.Discard, r, 1); err != nil && n == 0 {
+		return Info{}, fieldError("file", "file is empty")
+	}
+	if err := rewind(r); err != nil {
+		return Info{}, err
+	}
+	ct, err := SniffContentType(r)
+	if err != nil {
+		return Info{}, fieldError("file", "unable to read file: %v", err)
+	}
+	if !limits.allows(ct) {
+		return Info{}, fieldError("file", "file type %s is not allowed", ct)
+	}
+	info := Info{ContentType: ct}
+	if !strings.HasPrefix(ct, "image/") {
+		return info, rewind(r)
+	}
+
+	if err := rewind(r); err != nil {
+		return info, err
+	}
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return info, fieldError("file", "image header %s", describeDecodeError(err))
+	}
+	info.Width, info.Height = cfg.Width, cfg.Height
+	if "image/"+format != ct {
+		return info, fieldError("file", "image data (%s) does not match detected type %s", format, ct)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return info, fieldError("file", "image has invalid dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+	if limits.MaxWidth > 0 && cfg.Width > limits.MaxWidth {
+		return info, fieldError("file", "image is %dpx wide; the maximum is %dpx", cfg.Width, limits.MaxWidth)
+	}
+	if limits.MaxHeight > 0 && cfg.Height > limits.MaxHeight {
+		return info, fieldError("file", "image is %dpx tall; the maximum is %dpx", cfg.Height, limits.MaxHeight)
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); limits.MaxPixels > 0 && pixels > limits.MaxPixels {
+		return info, fieldError("file", "image has %d pixels; the maximum is %d", pixels, limits.MaxPixels)
+	}
+
+	if err := checkComplete(r, format); err != nil {
+		return info, fieldError("file", "image %s", describeDecodeError(err))
+	}
+	return info, rewind(r)
+}
+
+func (l Limits) allows(ct string) bool {
+	allowed := l.AllowedTypes
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedTypes
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == ct {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(ct, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeDecodeError(err error) string {
+	// Some decoders flatten the EOF into a format error string.
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || strings.Contains(err.Error(), "unexpected EOF") {
+		return "is truncated"
+	}
+	return "is corrupt: " + err.Error()
+}
+
+func rewind(r io.Seeker) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewind upload: %w", err)
+	}
+	return nil
+}
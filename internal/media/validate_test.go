@@ -0,0 +1,110 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func fileError(t *testing.T, err error) string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	return verr.Fields["file"]
+}
+
+func TestValidateAcceptsPNG(t *testing.T) {
+	r := bytes.NewReader(pngBytes(t, 4, 3))
+	info, err := Validate(r, Limits{MaxWidth: 10, MaxHeight: 10, MaxPixels: 100})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if info.ContentType != "image/png" || info.Width != 4 || info.Height != 3 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if pos, _ := r.Seek(0, 1); pos != 0 {
+		t.Fatalf("expected reader rewound, at %d", pos)
+	}
+}
+
+func TestValidateRejectsDisallowedType(t *testing.T) {
+	_, err := Validate(strings.NewReader("hello world"), Limits{})
+	if msg := fileError(t, err); !strings.Contains(msg, "text/plain") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestValidateIgnoresFilenameAndSniffs(t *testing.T) {
+	data := pngBytes(t, 2, 2)
+	_, err := Validate(bytes.NewReader(data), Limits{AllowedTypes: []string{"image/jpeg"}})
+	if msg := fileError(t, err); !strings.Contains(msg, "image/png is not allowed") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+	if _, err := Validate(bytes.NewReader(data), Limits{AllowedTypes: []string{"image/*"}}); err != nil {
+		t.Fatalf("wildcard should allow png: %v", err)
+	}
+}
+
+func TestValidateEnforcesDimensions(t *testing.T) {
+	data := pngBytes(t, 20, 10)
+	cases := []struct {
+		limits Limits
+		want   string
+	}{
+		{Limits{MaxWidth: 19}, "20px wide"},
+		{Limits{MaxHeight: 9}, "10px tall"},
+		{Limits{MaxPixels: 199}, "200 pixels"},
+	}
+	for _, tc := range cases {
+		_, err := Validate(bytes.NewReader(data), tc.limits)
+		if msg := fileError(t, err); !strings.Contains(msg, tc.want) {
+			t.Fatalf("limits %+v: unexpected message %q", tc.limits, msg)
+		}
+	}
+}
+
+func TestValidateRejectsTruncatedImage(t *testing.T) {
+	data := pngBytes(t, 64, 64)
+	_, err := Validate(bytes.NewReader(data[:len(data)-20]), Limits{})
+	if msg := fileError(t, err); !strings.Contains(msg, "truncated") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestValidateRejectsEmptyFile(t *testing.T) {
+	_, err := Validate(bytes.NewReader(nil), Limits{})
+	if msg := fileError(t, err); msg != "file is empty" {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestValidateSkipsImageChecksForVideo(t *testing.T) {
+	mov := []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  ")
+	info, err := Validate(bytes.NewReader(mov), Limits{AllowedTypes: []string{"video/*"}, MaxPixels: 1})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if info.ContentType != "video/quicktime" {
+		t.Fatalf("unexpected content type %s", info.ContentType)
+	}
+	if _, err := Validate(bytes.NewReader(mov), Limits{}); err == nil {
+		t.Fatal("expected video to be rejected by the default allowlist")
+	}
+}
@@ -0,0 +1,161 @@
+// Package metaimport applies metadata changes from a CSV file keyed by
+// asset ID. Files use the column names of the export, so an exported sheet
+// can be edited and imported again.
+package metaimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"ganache-admin-ui/internal/export"
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/tagpolicy"
+)
+
+// MaxRows bounds the size of one import.
+const MaxRows = 5000
+
+// editable are the export columns an import may change. Other export
+// columns, such as createdAt and the variant URLs, are read-only and are
+// ignored so exported files import unchanged.
+var editable = map[string]bool{
+	"title": true, "caption": true, "credit": true,
+	"source": true, "usageNotes": true, "tags": true,
+}
+
+// Row is one line of the file. Fields holds the editable columns with a
+// value; an empty cell leaves the field as it is.
+type Row struct {
+	Line   int               `json:"line"`
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+	Err    string            `json:"error,omitempty"`
+}
+
+// Update returns the asset's metadata with the row's fields applied.
+func (r Row) Update(a ganache.Asset) ganache.AssetUpdate {
+	u := a.AsUpdate()
+	for key, v := range r.Fields {
+		switch key {
+		case "title":
+			u.Title = v
+		case "caption":
+			u.Caption = v
+		case "credit":
+			u.Credit = v
+		case "source":
+			u.Source = v
+		case "usageNotes":
+			u.UsageNotes = v
+		case "tags":
+			u.Tags = tagpolicy.Split(v)
+		}
+	}
+	return u
+}
+
+// Parse reads the header and every row. Problems with the file as a whole
+// are returned as an error; problems with a single row are reported in its
+// Err so the rest of the file can still be checked.
+func Parse(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys, err := columnKeys(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	seen := map[string]int{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blank(record) {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("the file has more than %d rows", MaxRows)
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line, Fields: map[string]string{}}
+		if len(record) != len(keys) {
+			row.Err = fmt.Sprintf("expected %d cells, found %d", len(keys), len(record))
+			rows = append(rows, row)
+			continue
+		}
+		for i, key := range keys {
+			v := strings.TrimSpace(unneutralize(record[i]))
+			switch {
+			case key == "id":
+				row.ID = v
+			case editable[key] && v != "":
+				row.Fields[key] = v
+			}
+		}
+		switch first, dup := seen[row.ID]; {
+		case row.ID == "":
+			row.Err = "missing ID"
+		case dup:
+			row.Err = fmt.Sprintf("asset %s is also on line %d", row.ID, first)
+		default:
+			seen[row.ID] = line
+		}
+		rows = append(rows, row)
+	}
+}
+
+// columnKeys maps header cells to export column keys. A cell may name a
+// column by key or by its export header, in any case.
+func columnKeys(header []string) ([]string, error) {
+	keys := make([]string, len(header))
+	hasID := false
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		for _, c := range export.Columns {
+			if strings.EqualFold(h, c.Key) || strings.EqualFold(h, c.Header) {
+				keys[i] = c.Key
+			}
+		}
+		if keys[i] == "" {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		hasID = hasID || keys[i] == "id"
+	}
+	if !hasID {
+		return nil, errors.New("the file needs an ID column")
+	}
+	return keys, nil
+}
+
+// unneutralize drops the quote the CSV export puts before formula-like
+// cells.
+func unneutralize(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func blank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package metaimport
+
+import (
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestParse(t *testing.T) {
+	input := "\ufeffID,Caption,tags,Created,Original URL\n" +
+		"1,New caption,,2024-01-01T00:00:00Z,https://cdn/1.jpg\n" +
+		"\n" +
+		"2,,\"cup, final\",,\n" +
+		",orphan,,,\n" +
+		"1,again,,,\n" +
+		"3,'=SUM(A1)\n"
+	rows, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %+v", rows)
+	}
+	if rows[0].Line != 2 || rows[0].ID != "1" || rows[0].Fields["caption"] != "New caption" || len(rows[0].Fields) != 1 {
+		t.Fatalf("unexpected first row %+v", rows[0])
+	}
+	if rows[1].Line != 4 || rows[1].Fields["tags"] != "cup, final" {
+		t.Fatalf("unexpected second row %+v", rows[1])
+	}
+	if rows[2].Err != "missing ID" || !strings.Contains(rows[3].Err, "line 2") || !strings.Contains(rows[4].Err, "expected 5 cells") {
+		t.Fatalf("unexpected row errors %+v", rows[2:])
+	}
+
+	update := rows[1].Update(ganache.Asset{Title: "Kept", Tags: []string{"old"}})
+	if update.Title != "Kept" || strings.Join(update.Tags, "|") != "cup|final" {
+		t.Fatalf("unexpected update %+v", update)
+	}
+}
+
+func TestParseRoundTripsExportQuotes(t *testing.T) {
+	rows, err := Parse(strings.NewReader("id,title\n7,'=HYPERLINK(x)\n"))
+	if err != nil || rows[0].Fields["title"] != "=HYPERLINK(x)" {
+		t.Fatalf("unexpected rows %+v %v", rows, err)
+	}
+}
+
+func TestParseRejectsBadHeaders(t *testing.T) {
+	for _, input := range []string{"", "title,caption\n", "id,colour\n"} {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
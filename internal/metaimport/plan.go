@@ -0,0 +1,158 @@
+package metaimport
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/revisions"
+)
+
+// fetchConcurrent bounds the Ganache requests made by Plan and Apply.
+const fetchConcurrent = 4
+
+type Status string
+
+const (
+	Changed   Status = "changed"
+	Unchanged Status = "unchanged"
+	Applied   Status = "applied"
+	Failed    Status = "failed"
+)
+
+// Result is the outcome of one row. Before is the asset as fetched for the
+// dry run and After the metadata the row asks for.
+type Result struct {
+	Row    Row                 `json:"row"`
+	Status Status              `json:"status"`
+	Before ganache.Asset       `json:"before"`
+	After  ganache.AssetUpdate `json:"after"`
+	Err    string              `json:"error,omitempty"`
+}
+
+// Changes lists the fields the row changes.
+func (r Result) Changes() []revisions.Change {
+	if r.Status != Changed && r.Status != Applied {
+		return nil
+	}
+	return revisions.Diff(r.Before.AsUpdate(), r.After)
+}
+
+// Getter fetches the current metadata of an asset.
+type Getter func(ctx context.Context, id string) (ganache.Asset, error)
+
+// Checker normalises and validates tags, such as a tag policy's Check.
+type Checker func(tags []string) ([]string, error)
+
+// Plan is the dry run: it fetches every asset named in rows and works out
+// what the row would change. Nothing is written.
+func Plan(ctx context.Context, get Getter, check Checker, rows []Row) []Result {
+	results := make([]Result, len(rows))
+	each(len(rows), func(i int) {
+		row := rows[i]
+		res := Result{Row: row}
+		defer func() { results[i] = res }()
+		if row.Err != "" {
+			res.Status, res.Err = Failed, row.Err
+			return
+		}
+		before, err := get(ctx, row.ID)
+		if err != nil {
+			res.Status, res.Err = Failed, err.Error()
+			if ganache.IsNotFound(err) {
+				res.Err = "asset not found"
+			}
+			return
+		}
+		res.Before = before
+		res.After = row.Update(before)
+		if check != nil {
+			tags, err := check(res.After.Tags)
+			if err != nil {
+				res.Status, res.Err = Failed, err.Error()
+				return
+			}
+			res.After.Tags = tags
+		}
+		res.Status = Unchanged
+		if len(revisions.Diff(before.AsUpdate(), res.After)) > 0 {
+			res.Status = Changed
+		}
+	})
+	return results
+}
+
+// ErrChangedSincePlan is reported for rows whose asset was edited after the
+// dry run, so the confirmed diff no longer holds.
+var ErrChangedSincePlan = errors.New("the asset changed since the preview; run the import again")
+
+// Updater writes one row's change.
+type Updater func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error
+
+// Apply writes the changed rows of a plan. Each asset is fetched again
+// first and left alone if it no longer matches the dry run. Rows that were
+// unchanged or failed are returned as they are.
+func Apply(ctx context.Context, get Getter, update Updater, plan []Result) []Result {
+	results := make([]Result, len(plan))
+	copy(results, plan)
+	each(len(results), func(i int) {
+		res := &results[i]
+		if res.Status != Changed {
+			return
+		}
+		current, err := get(ctx, res.Row.ID)
+		switch {
+		case err != nil:
+			res.Err = err.Error()
+		case len(revisions.Diff(current.AsUpdate(), res.Before.AsUpdate())) > 0:
+			res.Err = ErrChangedSincePlan.Error()
+		default:
+			err = update(ctx, current, res.After)
+			if err != nil {
+				res.Err = err.Error()
+			}
+		}
+		res.Status = Applied
+		if res.Err != "" {
+			res.Status = Failed
+		}
+	})
+	return results
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Changed, Unchanged, Applied, Failed int
+}
+
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, r := range results {
+		switch r.Status {
+		case Changed:
+			s.Changed++
+		case Unchanged:
+			s.Unchanged++
+		case Applied:
+			s.Applied++
+		case Failed:
+			s.Failed++
+		}
+	}
+	return s
+}
+
+func each(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fetchConcurrent)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,83 @@
+package metaimport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+type fakeAssets struct {
+	mu     sync.Mutex
+	assets map[string]ganache.Asset
+}
+
+func (f *fakeAssets) get(ctx context.Context, id string) (ganache.Asset, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a, ok := f.assets[id]
+	if !ok {
+		return a, &ganache.APIError{StatusCode: 404, Message: "not found"}
+	}
+	return a, nil
+}
+
+func (f *fakeAssets) update(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a := f.assets[string(before.ID)]
+	a.Title, a.Caption, a.Tags = after.Title, after.Caption, after.Tags
+	f.assets[string(before.ID)] = a
+	return nil
+}
+
+func TestPlanAndApply(t *testing.T) {
+	store := &fakeAssets{assets: map[string]ganache.Asset{
+		"1": {ID: "1", Title: "One", Caption: "old"},
+		"2": {ID: "2", Title: "Two", Caption: "same"},
+		"3": {ID: "3", Title: "Three"},
+		"4": {ID: "4", Title: "Four"},
+	}}
+	rows := []Row{
+		{Line: 2, ID: "1", Fields: map[string]string{"caption": "new"}},
+		{Line: 3, ID: "2", Fields: map[string]string{"caption": "same"}},
+		{Line: 4, ID: "9", Fields: map[string]string{"caption": "x"}},
+		{Line: 5, Err: "missing ID"},
+		{Line: 6, ID: "3", Fields: map[string]string{"tags": "banned"}},
+		{Line: 7, ID: "4", Fields: map[string]string{"title": "Vier"}},
+	}
+	check := func(tags []string) ([]string, error) {
+		for _, t := range tags {
+			if t == "banned" {
+				return nil, errors.New("tag banned is not allowed")
+			}
+		}
+		return tags, nil
+	}
+
+	plan := Plan(context.Background(), store.get, check, rows)
+	want := []Status{Changed, Unchanged, Failed, Failed, Failed, Changed}
+	for i, s := range want {
+		if plan[i].Status != s {
+			t.Fatalf("row %d: got %s (%s), want %s", i, plan[i].Status, plan[i].Err, s)
+		}
+	}
+	if plan[2].Err != "asset not found" || len(plan[0].Changes()) != 1 || plan[0].Changes()[0].After != "new" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	// Asset 4 is edited elsewhere between the preview and the confirmation.
+	store.assets["4"] = ganache.Asset{ID: "4", Title: "Quatre"}
+	report := Apply(context.Background(), store.get, store.update, plan)
+	if report[0].Status != Applied || store.assets["1"].Caption != "new" {
+		t.Fatalf("row 1 not applied: %+v", report[0])
+	}
+	if report[5].Status != Failed || report[5].Err != ErrChangedSincePlan.Error() || store.assets["4"].Title != "Quatre" {
+		t.Fatalf("drifted row should fail: %+v", report[5])
+	}
+	if sum := Summarize(report); sum != (Summary{Unchanged: 1, Applied: 1, Failed: 4}) {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+}
@@ -0,0 +1,82 @@
+package metaimport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+// PreviewTTL is how long a dry run can be confirmed.
+const PreviewTTL = 24 * time.Hour
+
+var ErrNotFound = errors.New("import not found or expired")
+
+// Import is a dry run waiting to be confirmed, or its final report.
+type Import struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Filename  string    `json:"filename"`
+	CreatedAt time.Time `json:"createdAt"`
+	AppliedAt time.Time `json:"appliedAt,omitempty"`
+	Results   []Result  `json:"results"`
+}
+
+func (imp Import) Summary() Summary { return Summarize(imp.Results) }
+
+// Store keeps each import in its own JSON file. Imports older than
+// PreviewTTL are removed whenever a new one is saved.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save stores imp, assigning an ID to a new import.
+func (st *Store) Save(imp Import) (Import, error) {
+	if imp.ID == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return Import{}, err
+		}
+		imp.ID = hex.EncodeToString(buf)
+		imp.CreatedAt = time.Now().UTC()
+		st.prune(imp.CreatedAt.Add(-PreviewTTL))
+	}
+	return imp, filestore.WriteJSON(st.path(imp.ID), imp)
+}
+
+func (st *Store) Get(id string) (Import, error) {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return Import{}, ErrNotFound
+	}
+	var imp Import
+	if err := filestore.ReadJSON(st.path(id), &imp); err != nil {
+		return Import{}, err
+	}
+	if imp.ID == "" || time.Since(imp.CreatedAt) > PreviewTTL {
+		return Import{}, ErrNotFound
+	}
+	return imp, nil
+}
+
+func (st *Store) prune(before time.Time) {
+	entries, _ := os.ReadDir(st.dir)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err == nil && strings.HasSuffix(e.Name(), ".json") && info.ModTime().Before(before) {
+			os.Remove(filepath.Join(st.dir, e.Name()))
+		}
+	}
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
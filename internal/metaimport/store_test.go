@@ -0,0 +1,23 @@
+package metaimport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStoreSaveGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+	imp, err := store.Save(Import{Owner: "alice", Filename: "captions.csv", Results: []Result{{Status: Changed}}})
+	if err != nil || imp.ID == "" || imp.CreatedAt.IsZero() {
+		t.Fatalf("save: %+v %v", imp, err)
+	}
+	got, err := store.Get(imp.ID)
+	if err != nil || got.Owner != "alice" || got.Summary().Changed != 1 {
+		t.Fatalf("get: %+v %v", got, err)
+	}
+	for _, id := range []string{"abcd", "../x", ""} {
+		if _, err := store.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%q: expected not found, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package metrics collects the UI's Prometheus metrics: HTTP requests by
+// route, calls to each Ganache backend, uploads, sessions and logins. They
+// are kept in a registry of their own rather than the global one, so a
+// process can hold several servers, as the tests do.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ganache_admin"
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	ganacheDuration *prometheus.HistogramVec
+	ganacheErrors   *prometheus.CounterVec
+	uploads         *prometheus.CounterVec
+	uploadBytes     *prometheus.CounterVec
+	logins          *prometheus.CounterVec
+}
+
+// New registers the metrics. activeSessions is read on every scrape.
+func New(activeSessions func() int) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by chi route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve HTTP requests by chi route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		ganacheDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ganache_request_duration_seconds",
+			Help:      "Time taken by calls to Ganache by backend and client method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "method"}),
+		ganacheErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ganache_errors_total",
+			Help:      "Calls to Ganache that failed, by backend and client method.",
+		}, []string{"backend", "method"}),
+		uploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploads_total",
+			Help:      "Files uploaded to Ganache by backend and result.",
+		}, []string{"backend", "result"}),
+		uploadBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upload_bytes_total",
+			Help:      "Bytes of the files uploaded to Ganache by backend.",
+		}, []string{"backend"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result.",
+		}, []string{"result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration,
+		m.ganacheDuration, m.ganacheErrors,
+		m.uploads, m.uploadBytes, m.logins,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_sessions",
+			Help:      "Sessions that have not expired.",
+		}, func() float64 { return float64(activeSessions()) }),
+	)
+	// Show both results from the start, so a rate of failures is defined
+	// before the first one.
+	m.logins.WithLabelValues("success")
+	m.logins.WithLabelValues("failure")
+	return m
+}
+
+// Middleware counts and times requests. It must be used on a chi router,
+// since it labels them with the pattern of the route that served them.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{route, methodLabel(r.Method), strconv.Itoa(status)}
+		m.requests.WithLabelValues(labels...).Inc()
+		m.requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel keeps the method label to the standard methods. net/http
+// accepts any token as a method, so a client could otherwise create a new
+// series with every request.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// Ganache returns an observer for the client of the named backend.
+func (m *Metrics) Ganache(backend string) ganache.Observer {
+	return func(method string, elapsed time.Duration, err error) {
+		m.ganacheDuration.WithLabelValues(backend, method).Observe(elapsed.Seconds())
+		if err != nil {
+			m.ganacheErrors.WithLabelValues(backend, method).Inc()
+		}
+	}
+}
+
+// Upload records a file sent to Ganache. Only the bytes of successful
+// uploads are counted.
+func (m *Metrics) Upload(backend string, size int64, err error) {
+	if err != nil {
+		m.uploads.WithLabelValues(backend, "failure").Inc()
+		return
+	}
+	m.uploads.WithLabelValues(backend, "success").Inc()
+	m.uploadBytes.WithLabelValues(backend).Add(float64(size))
+}
+
+// Login records a login attempt.
+func (m *Metrics) Login(ok bool) {
+	if ok {
+		m.logins.WithLabelValues("success").Inc()
+		return
+	}
+	m.logins.WithLabelValues("failure").Inc()
+}
+
+// Handler serves the metrics in the Prometheus text format. With a token,
+// scrapes must send it as a bearer token.
+func (m *Metrics) Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func scrape(t *testing.T, h http.Handler, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	m := New(func() int { return 3 })
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+	for _, path := range []string{"/assets/1", "/assets/2", "/assets/missing", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"PROBE1", "PROBE2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/nowhere", nil))
+	}
+
+	body := scrape(t, m.Handler(""), "").Body.String()
+	for _, want := range []string{
+		`ganache_admin_http_requests_total{method="GET",route="/assets/{id}",status="200"} 2`,
+		`ganache_admin_http_requests_total{method="GET",route="/assets/{id}",status="404"} 1`,
+		`ganache_admin_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`ganache_admin_http_requests_total{method="OTHER",route="unmatched",status="405"} 2`,
+		`ganache_admin_http_request_duration_seconds_count{method="GET",route="/assets/{id}",status="200"} 2`,
+		`ganache_admin_active_sessions 3`,
+		`ganache_admin_logins_total{result="failure"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if strings.Contains(body, "PROBE") {
+		t.Error("arbitrary methods should not become label values")
+	}
+}
+
+func TestGanacheUploadsAndLogins(t *testing.T) {
+	m := New(func() int { return 0 })
+	observe := m.Ganache("staging")
+	observe("search", 20*time.Millisecond, nil)
+	observe("get", time.Millisecond, errors.New("not found"))
+	m.Upload("staging", 1024, nil)
+	m.Upload("staging", 512, errors.New("too large"))
+	m.Login(true)
+	m.Login(false)
+	m.Login(false)
+
+	body := scrape(t, m.Handler(""), "").Body.String()
+	for _, want := range []string{
+		`ganache_admin_ganache_request_duration_seconds_count{backend="staging",method="search"} 1`,
+		`ganache_admin_ganache_errors_total{backend="staging",method="get"} 1`,
+		`ganache_admin_uploads_total{backend="staging",result="success"} 1`,
+		`ganache_admin_uploads_total{backend="staging",result="failure"} 1`,
+		`ganache_admin_upload_bytes_total{backend="staging"} 1024`,
+		`ganache_admin_logins_total{result="success"} 1`,
+		`ganache_admin_logins_total{result="failure"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if strings.Contains(body, `ganache_admin_ganache_errors_total{backend="staging",method="search"}`) {
+		t.Error("a successful call should not count as an error")
+	}
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	m := New(func() int { return 0 })
+	h := m.Handler("s3cret")
+	if rec := scrape(t, h, ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", rec.Code)
+	}
+	if rec := scrape(t, h, "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with the wrong token, got %d", rec.Code)
+	}
+	if rec := scrape(t, h, "s3cret"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Fatalf("expected metrics with the token, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,205 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/revisions"
+)
+
+type Status string
+
+const (
+	// Copied: the asset was uploaded to the target, or would be in a dry
+	// run.
+	Copied Status = "copied"
+	// AlreadyCopied: the asset was copied before and was left alone.
+	AlreadyCopied Status = "already copied"
+	// Synced: the copy's metadata was brought up to date, or would be.
+	Synced Status = "synced"
+	// Unchanged: the copy's metadata already matches the source.
+	Unchanged Status = "unchanged"
+	Failed    Status = "failed"
+)
+
+// Result is the outcome of copying one asset. Changes lists what a sync
+// changes on the target. For a failure, Cause is the error behind Err, and
+// Uploaded is set when the original was sent to the target, or may have
+// been, so copying the asset again could leave two copies.
+type Result struct {
+	SourceID string             `json:"sourceId"`
+	TargetID string             `json:"targetId,omitempty"`
+	Status   Status             `json:"status"`
+	Changes  []revisions.Change `json:"changes,omitempty"`
+	Err      string             `json:"error,omitempty"`
+	Uploaded bool               `json:"uploaded,omitempty"`
+	Cause    error              `json:"-"`
+}
+
+// Options tune a copy. Sync updates the metadata of assets that were
+// already copied instead of skipping them; DryRun works out what would
+// happen without writing to the target or the mapping.
+type Options struct {
+	Sync   bool
+	DryRun bool
+}
+
+// Copier copies assets between two backends. The target is written
+// through Create and Update so callers can apply their tag policy and keep
+// their own records, such as the revision history, of the change.
+type Copier struct {
+	// Get and Download read from the source.
+	Get      func(ctx context.Context, id string) (ganache.Asset, error)
+	Download func(ctx context.Context, rawURL string) (io.ReadCloser, error)
+
+	// GetTarget, Create and Update read and write the target. An error from
+	// Create is taken to mean the target may have the asset, unless
+	// ganache.IsUnreachable says nothing was sent.
+	GetTarget func(ctx context.Context, id string) (ganache.Asset, error)
+	Create    func(ctx context.Context, file io.Reader, filename string, meta ganache.AssetUpdate) (ganache.Asset, error)
+	Update    func(ctx context.Context, before ganache.Asset, after ganache.AssetUpdate) error
+
+	// Check, when set, normalises tags the way the target's Update does,
+	// so a sync does not report changes the target would undo.
+	Check func(tags []string) ([]string, error)
+
+	Map  *Store
+	User string
+}
+
+// Copy copies one asset unless it was copied before. Without opts.Sync the
+// mapping is trusted and the target is not read. With it, the copy's
+// metadata is brought up to date, and a copy that has been deleted from
+// the target is copied again.
+func (c *Copier) Copy(ctx context.Context, id string, opts Options) Result {
+	res := Result{SourceID: id}
+	fail := func(err error) Result {
+		res.Status, res.Err, res.Cause = Failed, err.Error(), err
+		return res
+	}
+	src, err := c.Get(ctx, id)
+	if err != nil {
+		return fail(err)
+	}
+
+	if m, ok := c.Map.Get(id); ok {
+		res.TargetID = m.TargetID
+		if !opts.Sync {
+			res.Status = AlreadyCopied
+			return res
+		}
+		dst, err := c.GetTarget(ctx, m.TargetID)
+		switch {
+		case ganache.IsNotFound(err):
+			res.TargetID = ""
+		case err != nil:
+			return fail(err)
+		default:
+			return c.sync(ctx, res, m, src, dst, opts)
+		}
+	}
+
+	if src.Variants.Original == "" {
+		return fail(errors.New("the asset has no original to copy"))
+	}
+	res.Status = Copied
+	if opts.DryRun {
+		return res
+	}
+	body, err := c.Download(ctx, src.Variants.Original)
+	if err != nil {
+		return fail(fmt.Errorf("download original: %w", err))
+	}
+	defer body.Close()
+	created, err := c.Create(ctx, body, filename(src), src.AsUpdate())
+	res.Uploaded = err == nil || !ganache.IsUnreachable(err)
+	if err != nil {
+		return fail(err)
+	}
+	if created.ID == "" {
+		return fail(errors.New("the target did not return an asset ID"))
+	}
+	res.TargetID = string(created.ID)
+	err = c.Map.Put(Mapping{
+		SourceID: id,
+		TargetID: res.TargetID,
+		CopiedBy: c.User,
+		CopiedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fail(fmt.Errorf("copied as %s, but the mapping was not saved: %w", res.TargetID, err))
+	}
+	return res
+}
+
+func (c *Copier) sync(ctx context.Context, res Result, m Mapping, src, dst ganache.Asset, opts Options) Result {
+	after := src.AsUpdate()
+	if c.Check != nil {
+		tags, err := c.Check(after.Tags)
+		if err != nil {
+			res.Status, res.Err, res.Cause = Failed, err.Error(), err
+			return res
+		}
+		after.Tags = tags
+	}
+	res.Changes = revisions.Diff(dst.AsUpdate(), after)
+	if len(res.Changes) == 0 {
+		res.Status = Unchanged
+		return res
+	}
+	res.Status = Synced
+	if opts.DryRun {
+		return res
+	}
+	if err := c.Update(ctx, dst, after); err != nil {
+		res.Status, res.Err, res.Cause = Failed, err.Error(), err
+		return res
+	}
+	m.SyncedAt = time.Now().UTC()
+	if err := c.Map.Put(m); err != nil {
+		res.Cause = fmt.Errorf("synced, but the mapping was not saved: %w", err)
+		res.Status, res.Err = Failed, res.Cause.Error()
+	}
+	return res
+}
+
+// filename is the name the original is uploaded under: the last element
+// of its URL, or the source ID when the URL has none.
+func filename(a ganache.Asset) string {
+	if u, err := url.Parse(a.Variants.Original); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return string(a.ID)
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Copied, AlreadyCopied, Synced, Unchanged, Failed int
+}
+
+func Summarize(results []Result) Summary {
+	var s Summary
+	for _, r := range results {
+		switch r.Status {
+		case Copied:
+			s.Copied++
+		case AlreadyCopied:
+			s.AlreadyCopied++
+		case Synced:
+			s.Synced++
+		case Unchanged:
+			s.Unchanged++
+		case Failed:
+			s.Failed++
+		}
+	}
+	return s
+}
@@ -0,0 +1,202 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// fakeBackend keeps assets and their files in memory.
+type fakeBackend struct {
+	assets  map[string]ganache.Asset
+	files   map[string]string
+	uploads int
+	updates int
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{assets: map[string]ganache.Asset{}, files: map[string]string{}}
+}
+
+func (b *fakeBackend) put(a ganache.Asset, content string) {
+	a.Variants.Original = "http://ganache/files/" + string(a.ID) + ".jpg"
+	b.assets[string(a.ID)] = a
+	b.files[a.Variants.Original] = content
+}
+
+func (b *fakeBackend) get(_ context.Context, id string) (ganache.Asset, error) {
+	a, ok := b.assets[id]
+	if !ok {
+		return a, &ganache.APIError{StatusCode: 404, Message: "not found"}
+	}
+	return a, nil
+}
+
+func (b *fakeBackend) download(_ context.Context, rawURL string) (io.ReadCloser, error) {
+	content, ok := b.files[rawURL]
+	if !ok {
+		return nil, &ganache.APIError{StatusCode: 404, Message: "not found"}
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (b *fakeBackend) create(_ context.Context, file io.Reader, filename string, meta ganache.AssetUpdate) (ganache.Asset, error) {
+	var buf bytes.Buffer
+	io.Copy(&buf, file)
+	b.uploads++
+	a := ganache.Asset{
+		ID: ganache.StringID(fmt.Sprintf("t%d", b.uploads)), Title: meta.Title, Caption: meta.Caption,
+		Credit: meta.Credit, Source: meta.Source, UsageNotes: meta.UsageNotes, Tags: meta.Tags,
+	}
+	b.put(a, filename+":"+buf.String())
+	return b.assets[string(a.ID)], nil
+}
+
+func (b *fakeBackend) update(_ context.Context, before ganache.Asset, after ganache.AssetUpdate) error {
+	b.updates++
+	a := b.assets[string(before.ID)]
+	a.Title, a.Caption, a.Credit, a.Source, a.UsageNotes, a.Tags = after.Title, after.Caption, after.Credit, after.Source, after.UsageNotes, after.Tags
+	b.assets[string(a.ID)] = a
+	return nil
+}
+
+func newCopier(t *testing.T, from, to *fakeBackend) *Copier {
+	t.Helper()
+	st, err := NewStore(filepath.Join(t.TempDir(), "map.json"))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	return &Copier{
+		Get: from.get, Download: from.download,
+		GetTarget: to.get, Create: to.create, Update: to.update,
+		Map: st, User: "tester",
+	}
+}
+
+func TestCopyIsIdempotent(t *testing.T) {
+	from, to := newFakeBackend(), newFakeBackend()
+	from.put(ganache.Asset{ID: "1", Title: "Kickoff", Credit: "AP", Tags: []string{"cup"}}, "pixels")
+	c := newCopier(t, from, to)
+	ctx := context.Background()
+
+	if res := c.Copy(ctx, "1", Options{DryRun: true}); res.Status != Copied || to.uploads != 0 {
+		t.Fatalf("a dry run should report the copy without uploading, got %+v", res)
+	}
+	res := c.Copy(ctx, "1", Options{})
+	if res.Status != Copied || res.TargetID != "t1" {
+		t.Fatalf("expected a copy, got %+v", res)
+	}
+	copied := to.assets["t1"]
+	if copied.Title != "Kickoff" || copied.Credit != "AP" || to.files[copied.Variants.Original] != "1.jpg:pixels" {
+		t.Fatalf("expected the original and metadata on the target, got %+v", copied)
+	}
+	if m, ok := c.Map.Get("1"); !ok || m.TargetID != "t1" || m.CopiedBy != "tester" {
+		t.Fatalf("expected the mapping to be recorded, got %+v", m)
+	}
+
+	if res := c.Copy(ctx, "1", Options{}); res.Status != AlreadyCopied || res.TargetID != "t1" || to.uploads != 1 {
+		t.Fatalf("copying again should skip the asset, got %+v after %d uploads", res, to.uploads)
+	}
+
+	delete(to.assets, "t1")
+	if res := c.Copy(ctx, "1", Options{Sync: true}); res.Status != Copied || res.TargetID != "t2" {
+		t.Fatalf("a copy deleted from the target should be copied again, got %+v", res)
+	}
+}
+
+func TestCopySyncsMetadata(t *testing.T) {
+	from, to := newFakeBackend(), newFakeBackend()
+	from.put(ganache.Asset{ID: "1", Title: "Kickoff", Tags: []string{"cup"}}, "pixels")
+	c := newCopier(t, from, to)
+	c.Check = func(tags []string) ([]string, error) {
+		out := make([]string, len(tags))
+		for i, t := range tags {
+			out[i] = strings.ToLower(t)
+		}
+		return out, nil
+	}
+	ctx := context.Background()
+	c.Copy(ctx, "1", Options{})
+
+	if res := c.Copy(ctx, "1", Options{Sync: true}); res.Status != Unchanged {
+		t.Fatalf("expected nothing to sync, got %+v", res)
+	}
+	src := from.assets["1"]
+	src.Caption, src.Tags = "At noon", []string{"CUP", "final"}
+	from.assets["1"] = src
+
+	res := c.Copy(ctx, "1", Options{Sync: true, DryRun: true})
+	if res.Status != Synced || len(res.Changes) != 2 || to.updates != 0 {
+		t.Fatalf("expected a dry-run sync of the caption and tags, got %+v", res)
+	}
+	res = c.Copy(ctx, "1", Options{Sync: true})
+	if res.Status != Synced || to.assets["t1"].Caption != "At noon" || strings.Join(to.assets["t1"].Tags, ",") != "cup,final" {
+		t.Fatalf("expected the metadata synced through the checker, got %+v %+v", res, to.assets["t1"])
+	}
+	if m, _ := c.Map.Get("1"); m.SyncedAt.IsZero() {
+		t.Fatal("expected the sync time to be recorded")
+	}
+}
+
+func TestCopyReportsFailures(t *testing.T) {
+	from, to := newFakeBackend(), newFakeBackend()
+	from.put(ganache.Asset{ID: "1", Title: "Lost"}, "pixels")
+	delete(from.files, from.assets["1"].Variants.Original)
+	c := newCopier(t, from, to)
+	ctx := context.Background()
+
+	results := []Result{c.Copy(ctx, "1", Options{}), c.Copy(ctx, "404", Options{})}
+	for _, res := range results {
+		if res.Status != Failed || res.Err == "" {
+			t.Fatalf("expected a failure, got %+v", res)
+		}
+	}
+	if _, ok := c.Map.Get("1"); ok || to.uploads != 0 {
+		t.Fatal("a failed copy must not be mapped")
+	}
+	if s := Summarize(results); s.Failed != 2 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestCopySaysWhetherTheTargetMayHaveTheAsset(t *testing.T) {
+	from, to := newFakeBackend(), newFakeBackend()
+	from.put(ganache.Asset{ID: "1", Title: "Kickoff"}, "pixels")
+	c := newCopier(t, from, to)
+	ctx := context.Background()
+
+	c.Create = func(context.Context, io.Reader, string, ganache.AssetUpdate) (ganache.Asset, error) {
+		return ganache.Asset{}, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	}
+	if res := c.Copy(ctx, "1", Options{}); res.Status != Failed || res.Uploaded || !ganache.IsUnreachable(res.Cause) {
+		t.Fatalf("nothing reached the target: %+v", res)
+	}
+	c.Create = func(context.Context, io.Reader, string, ganache.AssetUpdate) (ganache.Asset, error) {
+		return ganache.Asset{}, &ganache.APIError{StatusCode: 504, Message: "gateway timeout"}
+	}
+	if res := c.Copy(ctx, "1", Options{}); res.Status != Failed || !res.Uploaded {
+		t.Fatalf("a timed out upload may have reached the target: %+v", res)
+	}
+
+	// A sync that cannot read the existing copy has uploaded nothing.
+	c.Create = to.create
+	if res := c.Copy(ctx, "1", Options{}); res.Status != Copied {
+		t.Fatalf("copy: %+v", res)
+	}
+	c.GetTarget = func(context.Context, string) (ganache.Asset, error) {
+		return ganache.Asset{}, &ganache.APIError{StatusCode: 502, Message: "bad gateway"}
+	}
+	res := c.Copy(ctx, "1", Options{Sync: true})
+	var apiErr *ganache.APIError
+	if res.Status != Failed || res.Uploaded || !errors.As(res.Cause, &apiErr) {
+		t.Fatalf("expected a transient failure, got %+v", res)
+	}
+}
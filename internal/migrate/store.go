@@ -0,0 +1,116 @@
+// Package migrate copies assets from one Ganache backend to another: the
+// original is downloaded from the source and uploaded to the target with
+// the source's metadata. Every copy is recorded in a mapping from source to
+// target ID, so copying again skips assets that were already copied, and a
+// sync brings the metadata of copied assets up to date.
+package migrate
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+// Mapping records that an asset was copied.
+type Mapping struct {
+	SourceID string    `json:"sourceId"`
+	TargetID string    `json:"targetId"`
+	CopiedBy string    `json:"copiedBy,omitempty"`
+	CopiedAt time.Time `json:"copiedAt"`
+	SyncedAt time.Time `json:"syncedAt,omitempty"`
+}
+
+// Path returns where the mappings from one backend to another are kept
+// under the UI data directory. The UI and the CLI share it.
+func Path(dataDir, from, to string) string {
+	return filepath.Join(dataDir, "migrations", from+"_to_"+to+".json")
+}
+
+// Store holds the mappings from one backend to another.
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	mappings map[string]Mapping
+}
+
+func NewStore(path string) (*Store, error) {
+	var list []Mapping
+	if err := filestore.ReadJSON(path, &list); err != nil {
+		return nil, err
+	}
+	st := &Store{path: path, mappings: make(map[string]Mapping, len(list))}
+	for _, m := range list {
+		st.mappings[m.SourceID] = m
+	}
+	return st, nil
+}
+
+// Get returns the mapping of a source asset.
+func (st *Store) Get(sourceID string) (Mapping, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	m, ok := st.mappings[sourceID]
+	return m, ok
+}
+
+// Put records or replaces the mapping of m.SourceID.
+func (st *Store) Put(m Mapping) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	old, existed := st.mappings[m.SourceID]
+	st.mappings[m.SourceID] = m
+	if err := st.saveLocked(); err != nil {
+		if existed {
+			st.mappings[m.SourceID] = old
+		} else {
+			delete(st.mappings, m.SourceID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove forgets the mapping of a source asset.
+func (st *Store) Remove(sourceID string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	m, ok := st.mappings[sourceID]
+	if !ok {
+		return nil
+	}
+	delete(st.mappings, sourceID)
+	if err := st.saveLocked(); err != nil {
+		st.mappings[sourceID] = m
+		return err
+	}
+	return nil
+}
+
+// List returns every mapping, oldest copy first.
+func (st *Store) List() []Mapping {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.listLocked()
+}
+
+func (st *Store) listLocked() []Mapping {
+	list := make([]Mapping, 0, len(st.mappings))
+	for _, m := range st.mappings {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if !list[a].CopiedAt.Equal(list[b].CopiedAt) {
+			return list[a].CopiedAt.Before(list[b].CopiedAt)
+		}
+		return list[a].SourceID < list[b].SourceID
+	})
+	return list
+}
+
+func (st *Store) saveLocked() error {
+	return filestore.WriteJSON(st.path, st.listLocked())
+}
@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePersistsMappings(t *testing.T) {
+	path := Path(t.TempDir(), "staging", "production")
+	if filepath.Base(path) != "staging_to_production.json" {
+		t.Fatalf("unexpected path %s", path)
+	}
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	now := time.Now().UTC()
+	st.Put(Mapping{SourceID: "2", TargetID: "20", CopiedAt: now.Add(time.Minute)})
+	st.Put(Mapping{SourceID: "1", TargetID: "10", CopiedAt: now})
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	list := reopened.List()
+	if len(list) != 2 || list[0].SourceID != "1" || list[1].TargetID != "20" {
+		t.Fatalf("expected both mappings, oldest first, got %+v", list)
+	}
+	if err := reopened.Remove("1"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, ok := reopened.Get("1"); ok {
+		t.Fatal("expected the mapping to be removed")
+	}
+}
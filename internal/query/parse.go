@@ -0,0 +1,270 @@
+// Package query parses the library search box syntax. Terms Ganache can
+// answer itself (free text, tags, sort) become search parameters; the rest
+// (exclusions, field-scoped text, credit/source matches, date ranges) are
+// checked against each result by Match.
+//
+//	football final tag:cup -tag:archive credit:"AP" title:kick after:2024-01-01 sort:oldest
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+const dateLayout = "2006-01-02"
+
+// SyntaxError reports the first malformed term. Start and End are byte
+// offsets into the input so the UI can highlight the term.
+type SyntaxError struct {
+	Input      string
+	Start, End int
+	Msg        string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at %q", e.Msg, e.Input[e.Start:e.End])
+}
+
+// Before, Token and After split the input around the offending term.
+func (e *SyntaxError) Before() string { return e.Input[:e.Start] }
+func (e *SyntaxError) Token() string  { return e.Input[e.Start:e.End] }
+func (e *SyntaxError) After() string  { return e.Input[e.End:] }
+
+type Query struct {
+	// Text is passed to Ganache as q. Terms holds the same words and
+	// phrases unquoted, for the local index.
+	Text  string
+	Terms []string
+	// Tags are passed to Ganache as repeated tag parameters.
+	Tags []string
+	Sort string
+
+	ExcludeText   []string
+	ExcludeTags   []string
+	Credit        []string
+	ExcludeCredit []string
+	Source        []string
+	ExcludeSource []string
+	Title         []string
+	Caption       []string
+	// After and Before bound CreatedAt: After is inclusive, Before exclusive.
+	After  time.Time
+	Before time.Time
+
+	// ExcludeIDs hides individual assets, such as those in the trash. It
+	// does not make the query Filtered: unfiltered Ganache pages are
+	// returned without them and may come back short.
+	ExcludeIDs map[string]bool
+}
+
+type term struct {
+	neg        bool
+	key, value string
+	start, end int
+}
+
+func Parse(input string) (Query, error) {
+	terms, err := tokenize(input)
+	if err != nil {
+		return Query{}, err
+	}
+	var q Query
+	var text []string
+	for _, t := range terms {
+		fail := func(msg string) error {
+			return &SyntaxError{Input: input, Start: t.start, End: t.end, Msg: msg}
+		}
+		if t.key != "" && t.value == "" {
+			return Query{}, fail("missing value for " + t.key)
+		}
+		switch t.key {
+		case "":
+			if t.neg {
+				q.ExcludeText = append(q.ExcludeText, t.value)
+			} else {
+				q.Terms = append(q.Terms, t.value)
+				text = append(text, quoteIfSpaced(t.value))
+			}
+		case "tag":
+			if t.neg {
+				q.ExcludeTags = append(q.ExcludeTags, t.value)
+			} else {
+				q.Tags = append(q.Tags, t.value)
+			}
+		case "credit":
+			if t.neg {
+				q.ExcludeCredit = append(q.ExcludeCredit, t.value)
+			} else {
+				q.Credit = append(q.Credit, t.value)
+			}
+		case "source":
+			if t.neg {
+				q.ExcludeSource = append(q.ExcludeSource, t.value)
+			} else {
+				q.Source = append(q.Source, t.value)
+			}
+		case "title", "caption":
+			if t.neg {
+				return Query{}, fail(t.key + " cannot be negated")
+			}
+			if t.key == "title" {
+				q.Title = append(q.Title, t.value)
+			} else {
+				q.Caption = append(q.Caption, t.value)
+			}
+		case "after", "before":
+			if t.neg {
+				return Query{}, fail(t.key + " cannot be negated")
+			}
+			day, err := time.Parse(dateLayout, t.value)
+			if err != nil {
+				return Query{}, fail("dates must look like 2024-01-31")
+			}
+			if t.key == "after" {
+				q.After = day
+			} else {
+				q.Before = day
+			}
+		case "sort":
+			if t.neg || (t.value != "newest" && t.value != "oldest") {
+				return Query{}, fail("sort must be newest or oldest")
+			}
+			q.Sort = t.value
+		default:
+			return Query{}, fail("unknown field " + t.key)
+		}
+	}
+	if !q.After.IsZero() && !q.Before.IsZero() && !q.Before.After(q.After) {
+		return Query{}, &SyntaxError{Input: input, Start: 0, End: len(input), Msg: "before must be later than after"}
+	}
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// tokenize splits input on whitespace, keeping double-quoted values
+// together: credit:"Associated Press" and "free kick" are single terms.
+func tokenize(input string) ([]term, error) {
+	var terms []term
+	i := 0
+	for i < len(input) {
+		if input[i] == ' ' || input[i] == '\t' {
+			i++
+			continue
+		}
+		t := term{start: i}
+		if input[i] == '-' && i+1 < len(input) && input[i+1] != ' ' {
+			t.neg = true
+			i++
+		}
+		j := i
+		for j < len(input) && input[j] != ' ' && input[j] != '\t' && input[j] != ':' && input[j] != '"' {
+			j++
+		}
+		if j < len(input) && input[j] == ':' {
+			t.key = strings.ToLower(input[i:j])
+			i = j + 1
+		}
+		if i < len(input) && input[i] == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Input: input, Start: t.start, End: len(input), Msg: "unterminated quote"}
+			}
+			t.value = input[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			j = i
+			for j < len(input) && input[j] != ' ' && input[j] != '\t' {
+				j++
+			}
+			t.value = input[i:j]
+			i = j
+		}
+		t.value = strings.TrimSpace(t.value)
+		t.end = i
+		if t.key == "" && t.value == "" {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+func quoteIfSpaced(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// Filtered reports whether results need post-filtering with Match.
+func (q Query) Filtered() bool {
+	return len(q.ExcludeText) > 0 || len(q.ExcludeTags) > 0 ||
+		len(q.Credit) > 0 || len(q.ExcludeCredit) > 0 ||
+		len(q.Source) > 0 || len(q.ExcludeSource) > 0 ||
+		len(q.Title) > 0 || len(q.Caption) > 0 ||
+		!q.After.IsZero() || !q.Before.IsZero()
+}
+
+// Match applies the constraints Ganache cannot express. Text matches are
+// case-insensitive substrings.
+func (q Query) Match(a ganache.Asset) bool {
+	if q.ExcludeIDs[string(a.ID)] {
+		return false
+	}
+	for _, t := range q.ExcludeTags {
+		for _, have := range a.Tags {
+			if strings.EqualFold(have, t) {
+				return false
+			}
+		}
+	}
+	for _, c := range q.Credit {
+		if !containsFold(a.Credit, c) {
+			return false
+		}
+	}
+	for _, c := range q.ExcludeCredit {
+		if containsFold(a.Credit, c) {
+			return false
+		}
+	}
+	for _, s := range q.Source {
+		if !containsFold(a.Source, s) {
+			return false
+		}
+	}
+	for _, s := range q.ExcludeSource {
+		if containsFold(a.Source, s) {
+			return false
+		}
+	}
+	for _, w := range q.Title {
+		if !containsFold(a.Title, w) {
+			return false
+		}
+	}
+	for _, w := range q.Caption {
+		if !containsFold(a.Caption, w) {
+			return false
+		}
+	}
+	for _, w := range q.ExcludeText {
+		if containsFold(a.Title, w) || containsFold(a.Caption, w) {
+			return false
+		}
+	}
+	if !q.After.IsZero() && a.CreatedAt.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !a.CreatedAt.Before(q.Before) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestParseSplitsGanacheAndLocalTerms(t *testing.T) {
+	q, err := Parse(`final "free kick" tag:football -tag:archive credit:"Associated Press" -source:wire after:2024-01-01 before:2024-02-01 sort:oldest -blurry`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.Text != `final "free kick"` || strings.Join(q.Tags, ",") != "football" || q.Sort != "oldest" {
+		t.Fatalf("unexpected ganache params: %+v", q)
+	}
+	if strings.Join(q.ExcludeTags, ",") != "archive" || q.Credit[0] != "Associated Press" || q.ExcludeSource[0] != "wire" || q.ExcludeText[0] != "blurry" {
+		t.Fatalf("unexpected filters: %+v", q)
+	}
+	if !q.After.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.Filtered() {
+		t.Fatalf("unexpected dates: %+v", q)
+	}
+
+	plain, _ := Parse("goal tag:cup")
+	if plain.Filtered() {
+		t.Fatalf("tags and text alone should not need post-filtering")
+	}
+}
+
+func TestParseReportsErrorPosition(t *testing.T) {
+	cases := []struct {
+		input, token string
+	}{
+		{`tag:cup after:yesterday`, "after:yesterday"},
+		{`colour:red goal`, "colour:red"},
+		{`credit:"AP`, `credit:"AP`},
+		{`tag: goal`, "tag:"},
+		{`-sort:newest`, "-sort:newest"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected syntax error, got %v", c.input, err)
+		}
+		if syntaxErr.Token() != c.token || syntaxErr.Before()+syntaxErr.Token()+syntaxErr.After() != c.input {
+			t.Fatalf("%q: highlighted %q, want %q", c.input, syntaxErr.Token(), c.token)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	q, _ := Parse(`-tag:Archive credit:ap after:2024-01-01 -blurry`)
+	asset := ganache.Asset{Title: "Cup final", Credit: "AP Photo", Tags: []string{"cup"}, CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if !q.Match(asset) {
+		t.Fatalf("expected match")
+	}
+	for name, change := range map[string]func(*ganache.Asset){
+		"excluded tag": func(a *ganache.Asset) { a.Tags = append(a.Tags, "archive") },
+		"credit":       func(a *ganache.Asset) { a.Credit = "Reuters" },
+		"too old":      func(a *ganache.Asset) { a.CreatedAt = time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC) },
+		"excluded":     func(a *ganache.Asset) { a.Caption = "a bit blurry" },
+	} {
+		a := asset
+		change(&a)
+		if q.Match(a) {
+			t.Fatalf("%s: expected no match", name)
+		}
+	}
+}
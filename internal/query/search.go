@@ -0,0 +1,117 @@
+package query
+
+import (
+	"context"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// scanPageSize is the Ganache page size used while post-filtering.
+const scanPageSize = 100
+
+type Searcher interface {
+	SearchAssets(ctx context.Context, q string, tags []string, page, pageSize int, sort string) (ganache.SearchResponse, error)
+}
+
+type Result struct {
+	Assets   []ganache.Asset
+	Page     int
+	PageSize int
+	HasNext  bool
+	// Truncated is set when a filtered search stopped after scanLimit
+	// Ganache results without filling the page; later matches may exist.
+	Truncated bool
+}
+
+// Search returns one page of results for q. Unfiltered queries map directly
+// onto a Ganache page. Filtered queries walk Ganache pages in order,
+// counting matches so that page N holds the same assets it would if Ganache
+// had applied the filter itself, and give up after scanLimit results.
+func Search(ctx context.Context, s Searcher, q Query, page, pageSize, scanLimit int) (Result, error) {
+	if page < 1 {
+		page = 1
+	}
+	if !q.Filtered() {
+		resp, err := s.SearchAssets(ctx, q.Text, q.Tags, page, pageSize, q.Sort)
+		if err != nil {
+			return Result{}, err
+		}
+		assets := resp.Assets
+		if len(q.ExcludeIDs) > 0 {
+			assets = nil
+			for _, a := range resp.Assets {
+				if !q.ExcludeIDs[string(a.ID)] {
+					assets = append(assets, a)
+				}
+			}
+		}
+		return Result{
+			Assets:   assets,
+			Page:     resp.Page,
+			PageSize: resp.PageSize,
+			HasNext:  resp.Page*resp.PageSize < resp.Total,
+		}, nil
+	}
+
+	res := Result{Page: page, PageSize: pageSize}
+	skip := (page - 1) * pageSize
+	matched, scanned := 0, 0
+	for upstream := 1; ; upstream++ {
+		resp, err := s.SearchAssets(ctx, q.Text, q.Tags, upstream, scanPageSize, q.Sort)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, a := range resp.Assets {
+			if !q.Match(a) {
+				continue
+			}
+			if matched >= skip+pageSize {
+				res.HasNext = true
+				return res, nil
+			}
+			if matched >= skip {
+				res.Assets = append(res.Assets, a)
+			}
+			matched++
+		}
+		scanned += len(resp.Assets)
+		size := resp.PageSize
+		if size <= 0 {
+			size = scanPageSize
+		}
+		if len(resp.Assets) < size || (resp.Total > 0 && upstream*size >= resp.Total) {
+			return res, nil
+		}
+		if scanLimit > 0 && scanned >= scanLimit {
+			res.Truncated = true
+			return res, nil
+		}
+	}
+}
+
+// Each calls fn for every asset matching q, walking Ganache pages in
+// order. Only one page is held at a time, so it suits exports of the whole
+// result set. It stops at the first error from Ganache or fn.
+func Each(ctx context.Context, s Searcher, q Query, fn func(ganache.Asset) error) error {
+	for upstream := 1; ; upstream++ {
+		resp, err := s.SearchAssets(ctx, q.Text, q.Tags, upstream, scanPageSize, q.Sort)
+		if err != nil {
+			return err
+		}
+		for _, a := range resp.Assets {
+			if !q.Match(a) {
+				continue
+			}
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+		size := resp.PageSize
+		if size <= 0 {
+			size = scanPageSize
+		}
+		if len(resp.Assets) < size || (resp.Total > 0 && upstream*size >= resp.Total) {
+			return nil
+		}
+	}
+}
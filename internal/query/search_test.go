@@ -0,0 +1,94 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// fakeSearcher serves n assets; even IDs carry the "archive" tag.
+type fakeSearcher struct {
+	n     int
+	calls int
+}
+
+func (f *fakeSearcher) SearchAssets(ctx context.Context, q string, tags []string, page, pageSize int, sort string) (ganache.SearchResponse, error) {
+	f.calls++
+	resp := ganache.SearchResponse{Page: page, PageSize: pageSize, Total: f.n}
+	for i := (page - 1) * pageSize; i < page*pageSize && i < f.n; i++ {
+		a := ganache.Asset{ID: ganache.StringID(fmt.Sprint(i))}
+		if i%2 == 0 {
+			a.Tags = []string{"archive"}
+		}
+		resp.Assets = append(resp.Assets, a)
+	}
+	return resp, nil
+}
+
+func TestSearchPagesFilteredResults(t *testing.T) {
+	src := &fakeSearcher{n: 250}
+	q, _ := Parse("-tag:archive")
+
+	first, err := Search(context.Background(), src, q, 1, 20, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(first.Assets) != 20 || first.Assets[0].ID != "1" || first.Assets[19].ID != "39" || !first.HasNext {
+		t.Fatalf("unexpected first page: %d %+v", len(first.Assets), first)
+	}
+
+	last, _ := Search(context.Background(), src, q, 7, 20, 0)
+	if len(last.Assets) != 5 || last.Assets[0].ID != "241" || last.HasNext {
+		t.Fatalf("unexpected last page: %+v", last)
+	}
+}
+
+func TestSearchStopsAtScanLimit(t *testing.T) {
+	src := &fakeSearcher{n: 10000}
+	q, _ := Parse("-tag:archive credit:nobody")
+	res, err := Search(context.Background(), src, q, 1, 20, 300)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if !res.Truncated || len(res.Assets) != 0 || src.calls != 3 {
+		t.Fatalf("expected truncated scan after 3 pages, got %+v after %d calls", res, src.calls)
+	}
+}
+
+func TestSearchExcludesIDs(t *testing.T) {
+	src := &fakeSearcher{n: 50}
+	q := Query{ExcludeIDs: map[string]bool{"0": true, "3": true}}
+	res, err := Search(context.Background(), src, q, 1, 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if src.calls != 1 || len(res.Assets) != 8 || res.Assets[0].ID != "1" || !res.HasNext {
+		t.Fatalf("unfiltered search should drop excluded assets from the page: %+v", res)
+	}
+
+	q, _ = Parse("-tag:archive")
+	q.ExcludeIDs = map[string]bool{"1": true}
+	res, _ = Search(context.Background(), src, q, 1, 3, 0)
+	if len(res.Assets) != 3 || res.Assets[0].ID != "3" {
+		t.Fatalf("filtered search should skip excluded assets: %+v", res)
+	}
+}
+
+func TestEachWalksEveryPage(t *testing.T) {
+	src := &fakeSearcher{n: 250}
+	q, _ := Parse("-tag:archive")
+	q.ExcludeIDs = map[string]bool{"3": true}
+	var ids []ganache.StringID
+	err := Each(context.Background(), src, q, func(a ganache.Asset) error {
+		ids = append(ids, a.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("each: %v", err)
+	}
+	if len(ids) != 124 || ids[0] != "1" || ids[1] != "5" || ids[123] != "249" || src.calls != 3 {
+		t.Fatalf("unexpected walk: %d assets after %d calls", len(ids), src.calls)
+	}
+}
@@ -0,0 +1,61 @@
+package revisions
+
+import (
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+// Change is one changed field. Tags are compared as a set and reported as
+// Added and Removed; other fields carry their old and new values.
+type Change struct {
+	Field   string
+	Label   string
+	Before  string
+	After   string
+	Added   []string
+	Removed []string
+}
+
+// Diff compares two metadata snapshots field by field.
+func Diff(before, after ganache.AssetUpdate) []Change {
+	var out []Change
+	text := func(field, label, a, b string) {
+		if a != b {
+			out = append(out, Change{Field: field, Label: label, Before: a, After: b})
+		}
+	}
+	text("title", "Title", before.Title, after.Title)
+	text("caption", "Caption", before.Caption, after.Caption)
+	text("credit", "Credit", before.Credit, after.Credit)
+	text("source", "Source", before.Source, after.Source)
+	text("usageNotes", "Usage notes", before.UsageNotes, after.UsageNotes)
+
+	added, removed := missing(after.Tags, before.Tags), missing(before.Tags, after.Tags)
+	if len(added) > 0 || len(removed) > 0 {
+		out = append(out, Change{
+			Field:   "tags",
+			Label:   "Tags",
+			Before:  strings.Join(before.Tags, ", "),
+			After:   strings.Join(after.Tags, ", "),
+			Added:   added,
+			Removed: removed,
+		})
+	}
+	return out
+}
+
+// missing returns the tags in a that are not in b.
+func missing(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, t := range b {
+		in[t] = true
+	}
+	var out []string
+	for _, t := range a {
+		if !in[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+// Package revisions keeps the metadata history of assets edited through the
+// admin UI. Each asset has its own JSON file holding its most recent
+// revisions, newest first.
+package revisions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+	"ganache-admin-ui/internal/ganache"
+)
+
+// MaxPerAsset is how many revisions are kept for each asset; older ones
+// are dropped as new ones arrive.
+const MaxPerAsset = 50
+
+var ErrNotFound = errors.New("revision not found")
+
+// Revision records one metadata change: the asset's metadata before and
+// after it, who made it and when.
+type Revision struct {
+	ID      string              `json:"id"`
+	AssetID string              `json:"assetId"`
+	Time    time.Time           `json:"time"`
+	User    string              `json:"user"`
+	Note    string              `json:"note,omitempty"`
+	Before  ganache.AssetUpdate `json:"before"`
+	After   ganache.AssetUpdate `json:"after"`
+}
+
+// Changes lists the fields the revision changed.
+func (r Revision) Changes() []Change { return Diff(r.Before, r.After) }
+
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Record stores a revision unless it changes no field (reordered tags do
+// not count), and reports whether it was stored.
+func (st *Store) Record(rev Revision) (bool, error) {
+	if len(rev.Changes()) == 0 {
+		return false, nil
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return false, err
+	}
+	rev.ID = hex.EncodeToString(buf)
+	if rev.Time.IsZero() {
+		rev.Time = time.Now().UTC()
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list, err := st.readLocked(rev.AssetID)
+	if err != nil {
+		return false, err
+	}
+	list = append([]Revision{rev}, list...)
+	if len(list) > MaxPerAsset {
+		list = list[:MaxPerAsset]
+	}
+	return true, filestore.WriteJSON(st.path(rev.AssetID), list)
+}
+
+// List returns the asset's revisions, newest first.
+func (st *Store) List(assetID string) ([]Revision, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.readLocked(assetID)
+}
+
+func (st *Store) Get(assetID, id string) (Revision, error) {
+	list, err := st.List(assetID)
+	if err != nil {
+		return Revision{}, err
+	}
+	for _, rev := range list {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return Revision{}, ErrNotFound
+}
+
+func (st *Store) readLocked(assetID string) ([]Revision, error) {
+	var list []Revision
+	if err := filestore.ReadJSON(st.path(assetID), &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (st *Store) path(assetID string) string {
+	return filepath.Join(st.dir, url.PathEscape(assetID)+".json")
+}
@@ -0,0 +1,73 @@
+package revisions
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestRecordListAndGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+	before := ganache.AssetUpdate{Title: "Derby", Caption: "Old caption", Tags: []string{"football", "derby"}}
+	after := before
+	after.Caption = "New caption"
+	after.Tags = []string{"derby", "football"}
+
+	if stored, err := store.Record(Revision{AssetID: "a/1", User: "alice", Before: before, After: before}); err != nil || stored {
+		t.Fatalf("no-op edit recorded: %t %v", stored, err)
+	}
+	if stored, err := store.Record(Revision{AssetID: "a/1", User: "alice", Before: before, After: after}); err != nil || !stored {
+		t.Fatalf("record: %t %v", stored, err)
+	}
+	second := after
+	second.Title = "City derby"
+	store.Record(Revision{AssetID: "a/1", User: "bob", Before: after, After: second})
+
+	list, err := store.List("a/1")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list: %+v %v", list, err)
+	}
+	if list[0].User != "bob" || list[1].User != "alice" {
+		t.Fatalf("expected newest first, got %+v", list)
+	}
+	changes := list[1].Changes()
+	if len(changes) != 1 || changes[0].Field != "caption" || changes[0].Before != "Old caption" {
+		t.Fatalf("reordered tags should not count as a change: %+v", changes)
+	}
+	got, err := store.Get("a/1", list[1].ID)
+	if err != nil || got.Before.Caption != "Old caption" {
+		t.Fatalf("get: %+v %v", got, err)
+	}
+	if _, err := store.Get("a/1", "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if list, _ := store.List("other"); list != nil {
+		t.Fatalf("unexpected revisions for other asset")
+	}
+}
+
+func TestRecordKeepsMaxPerAsset(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for i := 0; i < MaxPerAsset+5; i++ {
+		store.Record(Revision{
+			AssetID: "1",
+			Before:  ganache.AssetUpdate{Title: "t"},
+			After:   ganache.AssetUpdate{Title: "t" + string(rune('a'+i%26))},
+		})
+	}
+	if list, _ := store.List("1"); len(list) != MaxPerAsset {
+		t.Fatalf("kept %d revisions", len(list))
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	changes := Diff(
+		ganache.AssetUpdate{Tags: []string{"a", "b"}, Credit: "x"},
+		ganache.AssetUpdate{Tags: []string{"b", "c"}, Credit: "x"},
+	)
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0].Added, []string{"c"}) || !reflect.DeepEqual(changes[0].Removed, []string{"a"}) {
+		t.Fatalf("unexpected diff %+v", changes)
+	}
+}
@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultChunkSize = 64 * 1024
+
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner inspects upload content before it is forwarded to Ganache. An
+// error means the content could not be scanned; it says nothing about
+// whether the content is clean.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// ClamdScanner streams content to clamd using the INSTREAM command.
+type ClamdScanner struct {
+	network   string
+	address   string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// NewClamdScanner accepts "tcp://host:port", "unix:///path/to/clamd.sock",
+// a bare "host:port" or a bare absolute socket path.
+func NewClamdScanner(addr string, timeout time.Duration) (*ClamdScanner, error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &ClamdScanner{network: network, address: address, timeout: timeout, chunkSize: defaultChunkSize}, nil
+}
+
+func parseAddr(addr string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://"), nil
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	case strings.HasPrefix(addr, "/"):
+		return "unix", addr, nil
+	case strings.Contains(addr, ":"):
+		return "tcp", addr, nil
+	}
+	return "", "", fmt.Errorf("invalid clamd address %q", addr)
+}
+
+func (c *ClamdScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	var d net.Dialer
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamd dial: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	writeErr := c.stream(conn, r)
+	// clamd may close the stream early (e.g. StreamMaxLength exceeded) and
+	// still send an explanatory reply, so read it even if the write failed.
+	reply, readErr := bufio.NewReader(conn).ReadString(0)
+	if readErr != nil && reply == "" {
+		if writeErr != nil {
+			return Result{}, fmt.Errorf("clamd stream: %w", writeErr)
+		}
+		return Result{}, fmt.Errorf("clamd reply: %w", readErr)
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+func (c *ClamdScanner) stream(w io.Writer, r io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, 4+c.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(reply, " ERROR"))
+	}
+	return Result{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+}
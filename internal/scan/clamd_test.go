@@ -0,0 +1,141 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd implements enough of the INSTREAM protocol to exercise the
+// client. It flags any stream containing the EICAR test string.
+func fakeClamd(t *testing.T, network, address string, maxLen int) string {
+	t.Helper()
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, maxLen)
+		}
+	}()
+	if network == "unix" {
+		return "unix://" + ln.Addr().String()
+	}
+	return "tcp://" + ln.Addr().String()
+}
+
+func serveClamd(conn net.Conn, maxLen int) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil || cmd != "zINSTREAM\x00" {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return
+		}
+		if maxLen > 0 && data.Len() > maxLen {
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+	}
+	if strings.Contains(data.String(), eicar) {
+		io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+		return
+	}
+	io.WriteString(conn, "stream: OK\x00")
+}
+
+func TestClamdScannerCleanAndInfected(t *testing.T) {
+	addr := fakeClamd(t, "tcp", "127.0.0.1:0", 0)
+	scanner, err := NewClamdScanner(addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	scanner.chunkSize = 16
+
+	res, err := scanner.Scan(context.Background(), strings.NewReader(strings.Repeat("clean data ", 20)))
+	if err != nil || res.Infected {
+		t.Fatalf("expected clean, got %+v %v", res, err)
+	}
+
+	res, err = scanner.Scan(context.Background(), strings.NewReader("prefix "+eicar))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if !res.Infected || res.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("expected infected, got %+v", res)
+	}
+}
+
+func TestClamdScannerUnixSocket(t *testing.T) {
+	addr := fakeClamd(t, "unix", filepath.Join(t.TempDir(), "clamd.sock"), 0)
+	scanner, err := NewClamdScanner(addr, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	res, err := scanner.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil || !res.Infected {
+		t.Fatalf("expected infected over unix socket, got %+v %v", res, err)
+	}
+}
+
+func TestClamdScannerReportsErrors(t *testing.T) {
+	addr := fakeClamd(t, "tcp", "127.0.0.1:0", 8)
+	scanner, _ := NewClamdScanner(addr, time.Second)
+	scanner.chunkSize = 4
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("0123456789abcdef")); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Fatalf("expected size limit error, got %v", err)
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := ln.Addr().String()
+	ln.Close()
+	scanner, _ = NewClamdScanner(down, time.Second)
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Fatalf("expected dial error")
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	cases := map[string][2]string{
+		"tcp://clamav:3310":      {"tcp", "clamav:3310"},
+		"clamav:3310":            {"tcp", "clamav:3310"},
+		"unix:///run/clamd.sock": {"unix", "/run/clamd.sock"},
+		"/run/clamd.sock":        {"unix", "/run/clamd.sock"},
+	}
+	for in, want := range cases {
+		network, address, err := parseAddr(in)
+		if err != nil || network != want[0] || address != want[1] {
+			t.Fatalf("%s: got %s %s %v", in, network, address, err)
+		}
+	}
+	if _, _, err := parseAddr("clamav"); err == nil {
+		t.Fatalf("expected error for address without port")
+	}
+}
@@ -0,0 +1,183 @@
+// Package searches stores named asset searches per user. Searches are
+// readable by every user so they can be shared by link, but only the owner
+// can change them.
+package searches
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+var (
+	ErrNotFound  = errors.New("saved search not found")
+	ErrForbidden = errors.New("saved search belongs to another user")
+)
+
+type Search struct {
+	ID            string    `json:"id"`
+	Owner         string    `json:"owner"`
+	Name          string    `json:"name"`
+	Query         string    `json:"query"`
+	Tags          []string  `json:"tags,omitempty"`
+	Sort          string    `json:"sort,omitempty"`
+	Pinned        bool      `json:"pinned"`
+	NotifyNew     bool      `json:"notifyNew"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastVisitedAt time.Time `json:"lastVisitedAt"`
+}
+
+// Values returns the assets index query parameters for the search.
+func (s Search) Values() url.Values {
+	v := url.Values{}
+	if s.Query != "" {
+		v.Set("q", s.Query)
+	}
+	for _, t := range s.Tags {
+		v.Add("tag", t)
+	}
+	if s.Sort != "" {
+		v.Set("sort", s.Sort)
+	}
+	return v
+}
+
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	searches map[string]*Search
+}
+
+func NewStore(path string) (*Store, error) {
+	var list []*Search
+	if err := filestore.ReadJSON(path, &list); err != nil {
+		return nil, err
+	}
+	st := &Store{path: path, searches: make(map[string]*Search, len(list))}
+	for _, s := range list {
+		st.searches[s.ID] = s
+	}
+	return st, nil
+}
+
+func (st *Store) Create(s Search) (Search, error) {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return Search{}, errors.New("name is required")
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return Search{}, err
+	}
+	s.ID = hex.EncodeToString(buf)
+	s.CreatedAt = time.Now().UTC()
+	s.LastVisitedAt = s.CreatedAt
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.searches[s.ID] = &s
+	if err := st.saveLocked(); err != nil {
+		delete(st.searches, s.ID)
+		return Search{}, err
+	}
+	return s, nil
+}
+
+func (st *Store) Get(id string) (Search, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.searches[id]
+	if !ok {
+		return Search{}, ErrNotFound
+	}
+	return *s, nil
+}
+
+// List returns the owner's searches, pinned first then by name.
+func (st *Store) List(owner string) []Search {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var list []Search
+	for _, s := range st.searches {
+		if s.Owner == owner {
+			list = append(list, *s)
+		}
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Pinned != list[b].Pinned {
+			return list[a].Pinned
+		}
+		return strings.ToLower(list[a].Name) < strings.ToLower(list[b].Name)
+	})
+	return list
+}
+
+func (st *Store) Pinned(owner string) []Search {
+	var pinned []Search
+	for _, s := range st.List(owner) {
+		if s.Pinned {
+			pinned = append(pinned, s)
+		}
+	}
+	return pinned
+}
+
+// Update applies fn to the owner's search and persists the result.
+func (st *Store) Update(owner, id string, fn func(*Search)) (Search, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.searches[id]
+	if !ok {
+		return Search{}, ErrNotFound
+	}
+	if s.Owner != owner {
+		return Search{}, ErrForbidden
+	}
+	prev := *s
+	fn(s)
+	s.ID, s.Owner, s.CreatedAt = prev.ID, prev.Owner, prev.CreatedAt
+	if s.Name = strings.TrimSpace(s.Name); s.Name == "" {
+		s.Name = prev.Name
+	}
+	if err := st.saveLocked(); err != nil {
+		*s = prev
+		return Search{}, err
+	}
+	return *s, nil
+}
+
+func (st *Store) MarkVisited(owner, id string, at time.Time) error {
+	_, err := st.Update(owner, id, func(s *Search) { s.LastVisitedAt = at.UTC() })
+	return err
+}
+
+func (st *Store) Delete(owner, id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.searches[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if s.Owner != owner {
+		return ErrForbidden
+	}
+	delete(st.searches, id)
+	return st.saveLocked()
+}
+
+func (st *Store) saveLocked() error {
+	list := make([]*Search, 0, len(st.searches))
+	for _, s := range st.searches {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return filestore.WriteJSON(st.path, list)
+}
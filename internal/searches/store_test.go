@@ -0,0 +1,62 @@
+package searches
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePersistsPerOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "searches.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := store.Create(Search{Owner: "alice", Name: "  "}); err == nil {
+		t.Fatalf("expected name to be required")
+	}
+	b, _ := store.Create(Search{Owner: "alice", Name: "b archive", Query: "old"})
+	a, _ := store.Create(Search{Owner: "alice", Name: "a football", Tags: []string{"football"}, Sort: "newest", Pinned: true})
+	store.Create(Search{Owner: "bob", Name: "bob's"})
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	list := reloaded.List("alice")
+	if len(list) != 2 || list[0].ID != a.ID || list[1].ID != b.ID {
+		t.Fatalf("expected pinned first, got %+v", list)
+	}
+	if pinned := reloaded.Pinned("alice"); len(pinned) != 1 || pinned[0].ID != a.ID {
+		t.Fatalf("unexpected pinned list %+v", pinned)
+	}
+	if got := a.Values().Encode(); got != "sort=newest&tag=football" {
+		t.Fatalf("unexpected values %q", got)
+	}
+}
+
+func TestStoreOnlyOwnerCanChange(t *testing.T) {
+	store, _ := NewStore(filepath.Join(t.TempDir(), "searches.json"))
+	s, _ := store.Create(Search{Owner: "alice", Name: "mine"})
+
+	if _, err := store.Update("bob", s.ID, func(s *Search) { s.Name = "stolen" }); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected forbidden update, got %v", err)
+	}
+	if err := store.Delete("bob", s.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected forbidden delete, got %v", err)
+	}
+	visited := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.MarkVisited("alice", s.ID, visited); err != nil {
+		t.Fatalf("mark visited: %v", err)
+	}
+	if got, _ := store.Get(s.ID); !got.LastVisitedAt.Equal(visited) || got.Name != "mine" {
+		t.Fatalf("unexpected search %+v", got)
+	}
+	if err := store.Delete("alice", s.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
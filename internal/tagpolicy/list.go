@@ -0,0 +1,42 @@
+package tagpolicy
+
+import "strings"
+
+// Split reads tags typed as comma-separated lists, from one or several
+// inputs, and returns them trimmed, in order and without duplicates. It is
+// how every form, file and flag that takes tags reads them, so the same
+// text gives the same tags everywhere.
+func Split(inputs ...string) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, in := range inputs {
+		for _, part := range strings.Split(in, ",") {
+			t := strings.TrimSpace(part)
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// Edit removes then adds tags, keeping the existing order and ignoring
+// duplicates. A tag both removed and added is removed.
+func Edit(tags, add, remove []string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, t := range remove {
+		drop[t] = true
+	}
+	seen := make(map[string]bool, len(tags)+len(add))
+	var out []string
+	for _, t := range append(append([]string(nil), tags...), add...) {
+		if drop[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
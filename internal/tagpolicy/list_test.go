@@ -0,0 +1,23 @@
+package tagpolicy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	got := Split(" cup, final,,cup", "night , final")
+	if want := []string{"cup", "final", "night"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := Split(" , ", ""); got != nil {
+		t.Fatalf("expected no tags, got %q", got)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	got := Edit([]string{"a", "b", "c"}, []string{"d", "a", "e"}, []string{"b", "e"})
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
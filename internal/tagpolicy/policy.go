@@ -0,0 +1,276 @@
+// Package tagpolicy normalises asset tags before they are saved: it tidies
+// whitespace and case, maps aliases to canonical tags, adds parent tags from
+// a hierarchical vocabulary and reports tags the vocabulary does not know.
+package tagpolicy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CaseLower    = "lower"
+	CasePreserve = "preserve"
+)
+
+type Term struct {
+	Name    string   `yaml:"name"`
+	Parent  string   `yaml:"parent"`
+	Aliases []string `yaml:"aliases"`
+}
+
+type File struct {
+	// Case is applied to tags outside the vocabulary; vocabulary terms keep
+	// the spelling they are defined with.
+	Case string `yaml:"case"`
+	// Strict rejects tags outside the vocabulary instead of warning.
+	Strict bool   `yaml:"strict"`
+	Tags   []Term `yaml:"tags"`
+}
+
+type Policy struct {
+	caseMode string
+	strict   bool
+	// canonical maps the folded form of every term and alias to its term.
+	canonical map[string]string
+	parent    map[string]string
+	terms     []string
+}
+
+// Unknown is a tag outside the vocabulary, with the closest term when one
+// is within a couple of typos.
+type Unknown struct {
+	Tag        string
+	Suggestion string
+}
+
+type Result struct {
+	Tags    []string
+	Unknown []Unknown
+}
+
+// Error is returned by Check when a strict policy rejects tags.
+type Error struct {
+	Unknown []Unknown
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Unknown))
+	for i, u := range e.Unknown {
+		parts[i] = u.String()
+	}
+	return "not in the tag vocabulary: " + strings.Join(parts, ", ")
+}
+
+func (u Unknown) String() string {
+	if u.Suggestion != "" {
+		return fmt.Sprintf("%q (did you mean %q?)", u.Tag, u.Suggestion)
+	}
+	return fmt.Sprintf("%q", u.Tag)
+}
+
+// Load reads a policy file. An empty path gives the default policy, which
+// only tidies whitespace and drops case-insensitive duplicates.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return New(File{})
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("tag policy %s: %w", path, err)
+	}
+	return New(f)
+}
+
+func New(f File) (*Policy, error) {
+	p := &Policy{
+		caseMode:  f.Case,
+		strict:    f.Strict,
+		canonical: make(map[string]string),
+		parent:    make(map[string]string),
+	}
+	switch p.caseMode {
+	case "":
+		p.caseMode = CasePreserve
+	case CaseLower, CasePreserve:
+	default:
+		return nil, fmt.Errorf("tag policy: unknown case %q", f.Case)
+	}
+	if p.strict && len(f.Tags) == 0 {
+		return nil, errors.New("tag policy: strict needs a vocabulary")
+	}
+	for _, t := range f.Tags {
+		name := tidy(t.Name)
+		if name == "" {
+			return nil, errors.New("tag policy: term without a name")
+		}
+		if _, dup := p.canonical[fold(name)]; dup {
+			return nil, fmt.Errorf("tag policy: %q defined twice", name)
+		}
+		p.canonical[fold(name)] = name
+		p.terms = append(p.terms, name)
+	}
+	for _, t := range f.Tags {
+		name := tidy(t.Name)
+		for _, a := range t.Aliases {
+			key := fold(tidy(a))
+			if existing, ok := p.canonical[key]; ok && existing != name {
+				return nil, fmt.Errorf("tag policy: alias %q already maps to %q", a, existing)
+			}
+			p.canonical[key] = name
+		}
+		if t.Parent != "" {
+			parent, ok := p.canonical[fold(tidy(t.Parent))]
+			if !ok {
+				return nil, fmt.Errorf("tag policy: %q has unknown parent %q", name, t.Parent)
+			}
+			p.parent[name] = parent
+		}
+	}
+	for _, name := range p.terms {
+		seen := map[string]bool{name: true}
+		for cur := p.parent[name]; cur != ""; cur = p.parent[cur] {
+			if seen[cur] {
+				return nil, fmt.Errorf("tag policy: parent cycle through %q", name)
+			}
+			seen[cur] = true
+		}
+	}
+	sort.Strings(p.terms)
+	return p, nil
+}
+
+// HasVocabulary reports whether the policy defines any terms.
+func (p *Policy) HasVocabulary() bool { return len(p.terms) > 0 }
+
+func (p *Policy) Strict() bool { return p.strict }
+
+// Apply normalises tags: aliases become their term, each term brings its
+// parents, other tags get the configured case, and duplicates are dropped
+// case-insensitively keeping the first spelling.
+func (p *Policy) Apply(tags []string) Result {
+	var res Result
+	seen := make(map[string]struct{})
+	add := func(t string) {
+		if _, ok := seen[fold(t)]; ok {
+			return
+		}
+		seen[fold(t)] = struct{}{}
+		res.Tags = append(res.Tags, t)
+	}
+	for _, raw := range tags {
+		t := tidy(raw)
+		if t == "" {
+			continue
+		}
+		if name, ok := p.canonical[fold(t)]; ok {
+			add(name)
+			for parent := p.parent[name]; parent != ""; parent = p.parent[parent] {
+				add(parent)
+			}
+			continue
+		}
+		if p.caseMode == CaseLower {
+			t = strings.ToLower(t)
+		}
+		if p.HasVocabulary() {
+			if _, dup := seen[fold(t)]; !dup {
+				res.Unknown = append(res.Unknown, Unknown{Tag: t, Suggestion: p.closest(t)})
+			}
+		}
+		add(t)
+	}
+	return res
+}
+
+// Check applies the policy and, when it is strict, rejects unknown tags.
+func (p *Policy) Check(tags []string) ([]string, error) {
+	res := p.Apply(tags)
+	if p.strict && len(res.Unknown) > 0 {
+		return nil, &Error{Unknown: res.Unknown}
+	}
+	return res.Tags, nil
+}
+
+// Canonical returns the vocabulary term for tag or one of its aliases.
+func (p *Policy) Canonical(tag string) (string, bool) {
+	name, ok := p.canonical[fold(tidy(tag))]
+	return name, ok
+}
+
+// Suggest returns vocabulary terms whose name or alias starts with prefix,
+// in alphabetical order.
+func (p *Policy) Suggest(prefix string, limit int) []string {
+	prefix = fold(tidy(prefix))
+	matched := make(map[string]struct{})
+	for key, name := range p.canonical {
+		if strings.HasPrefix(key, prefix) {
+			matched[name] = struct{}{}
+		}
+	}
+	var out []string
+	for _, name := range p.terms {
+		if _, ok := matched[name]; ok {
+			out = append(out, name)
+			if limit > 0 && len(out) == limit {
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (p *Policy) closest(tag string) string {
+	key := fold(tag)
+	limit := 1
+	if len([]rune(key)) > 5 {
+		limit = 2
+	}
+	best, bestDist := "", limit+1
+	for k, name := range p.canonical {
+		d := distance(key, k)
+		if d < bestDist || (d == bestDist && best != "" && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func tidy(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,120 @@
+package tagpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testPolicy(t *testing.T, strict bool) *Policy {
+	t.Helper()
+	p, err := New(File{
+		Case:   CaseLower,
+		Strict: strict,
+		Tags: []Term{
+			{Name: "sport"},
+			{Name: "football", Parent: "sport", Aliases: []string{"soccer"}},
+			{Name: "Premier League", Parent: "football", Aliases: []string{"EPL"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	return p
+}
+
+func TestApplyAliasesParentsAndCase(t *testing.T) {
+	p := testPolicy(t, false)
+	res := p.Apply([]string{"  epl ", "Soccer", "Stadium  Night", "stadium night", ""})
+	want := []string{"Premier League", "football", "sport", "stadium night"}
+	if !reflect.DeepEqual(res.Tags, want) {
+		t.Fatalf("tags = %q, want %q", res.Tags, want)
+	}
+	if len(res.Unknown) != 1 || res.Unknown[0].Tag != "stadium night" {
+		t.Fatalf("unknown = %+v", res.Unknown)
+	}
+}
+
+func TestApplySuggestsClosestTerm(t *testing.T) {
+	p := testPolicy(t, false)
+	res := p.Apply([]string{"footbal", "weather"})
+	if len(res.Unknown) != 2 {
+		t.Fatalf("unknown = %+v", res.Unknown)
+	}
+	if res.Unknown[0].Suggestion != "football" {
+		t.Fatalf("suggestion = %q", res.Unknown[0].Suggestion)
+	}
+	if res.Unknown[1].Suggestion != "" {
+		t.Fatalf("unexpected suggestion %q", res.Unknown[1].Suggestion)
+	}
+}
+
+func TestDefaultPolicyOnlyTidies(t *testing.T) {
+	p, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	res := p.Apply([]string{" Night  Match", "night match", "EPL"})
+	if !reflect.DeepEqual(res.Tags, []string{"Night Match", "EPL"}) || res.Unknown != nil {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	p := testPolicy(t, true)
+	if _, err := p.Check([]string{"soccer"}); err != nil {
+		t.Fatalf("known tag rejected: %v", err)
+	}
+	_, err := p.Check([]string{"soccer", "footbal"})
+	var perr *Error
+	if !errors.As(err, &perr) || len(perr.Unknown) != 1 {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `did you mean "football"`) {
+		t.Fatalf("message = %q", err.Error())
+	}
+}
+
+func TestSuggestMatchesAliases(t *testing.T) {
+	p := testPolicy(t, false)
+	if got := p.Suggest("soc", 10); !reflect.DeepEqual(got, []string{"football"}) {
+		t.Fatalf("suggest soc = %q", got)
+	}
+	if got := p.Suggest("", 2); !reflect.DeepEqual(got, []string{"Premier League", "football"}) {
+		t.Fatalf("suggest all = %q", got)
+	}
+}
+
+func TestNewRejectsBadVocabulary(t *testing.T) {
+	cases := map[string]File{
+		"case":      {Case: "upper"},
+		"strict":    {Strict: true},
+		"duplicate": {Tags: []Term{{Name: "a"}, {Name: "A"}}},
+		"alias":     {Tags: []Term{{Name: "a", Aliases: []string{"x"}}, {Name: "b", Aliases: []string{"x"}}}},
+		"parent":    {Tags: []Term{{Name: "a", Parent: "missing"}}},
+		"cycle":     {Tags: []Term{{Name: "a", Parent: "b"}, {Name: "b", Parent: "a"}}},
+	}
+	for name, f := range cases {
+		if _, err := New(f); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.yaml")
+	data := "case: lower\ntags:\n  - name: football\n    aliases: [soccer]\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if name, ok := p.Canonical("SOCCER"); !ok || name != "football" {
+		t.Fatalf("canonical = %q, %t", name, ok)
+	}
+}
@@ -0,0 +1,168 @@
+// Package tracing sets up OpenTelemetry tracing: a span for each request
+// named after its chi route, one for each call to Ganache and one for each
+// page rendered. Trace context travels in W3C traceparent headers, both
+// from a proxy in front of the UI and on to Ganache.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "ganache-admin-ui"
+
+// Exporters accepted by Options.Exporter. With none, no spans are
+// recorded, but trace context from a proxy is still passed on to Ganache.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Options configures Setup. Endpoint is the base URL of an OTLP/HTTP
+// collector; spans are posted to its /v1/traces with Headers. SampleRatio
+// is the share of new traces recorded; requests that arrive with a sampled
+// traceparent are always recorded.
+type Options struct {
+	Exporter    string
+	Endpoint    string
+	Headers     map[string]string
+	SampleRatio float64
+	Version     string
+}
+
+// Setup installs the global tracer provider and propagator. The returned
+// function flushes the spans not yet exported and must be called before
+// the process exits.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		if opts.Endpoint == "" {
+			return nil, errors.New("tracing: an OTLP endpoint is required")
+		}
+		exp, err := otlptrace.New(ctx, &otlpClient{
+			url:     strings.TrimRight(opts.Endpoint, "/") + "/v1/traces",
+			headers: opts.Headers,
+			http:    &http.Client{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	case ExporterStdout:
+		exp, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", opts.Exporter)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", instrumentation),
+			attribute.String("service.version", opts.Version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for spans the UI starts itself.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Middleware starts a span for each request. It must be used on a chi
+// router: once the request is routed, the span is renamed after the route
+// pattern, such as "GET /assets/{id}", which keeps the number of span
+// names small. Health checks, static files and metrics are not traced.
+func Middleware(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if pattern := routePattern(r); pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(spanName(r))
+			span.SetAttributes(attribute.String("http.route", pattern))
+		}
+	})
+	// otelhttp names the span again once the request is served, when chi
+	// has set its pattern.
+	return otelhttp.NewHandler(named, instrumentation,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return spanName(r) }),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch {
+			case r.URL.Path == "/healthz", r.URL.Path == "/readyz", r.URL.Path == "/metrics":
+				return false
+			case strings.HasPrefix(r.URL.Path, "/static/"):
+				return false
+			}
+			return true
+		}),
+	)
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// spanName is the method, followed by the route pattern once routed.
+func spanName(r *http.Request) string {
+	if pattern := routePattern(r); pattern != "" {
+		return r.Method + " " + pattern
+	}
+	return r.Method
+}
+
+// Transport wraps the transport of a Ganache client, for
+// ganache.Client.WrapTransport. Spans are named after the client method,
+// such as "ganache search", and carry the backend's name.
+func Transport(backend string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				if method := ganache.MethodOf(r); method != "" {
+					return "ganache " + method
+				}
+				return "ganache " + r.Method
+			}),
+			otelhttp.WithSpanOptions(trace.WithAttributes(attribute.String("ganache.backend", backend))),
+		)
+	}
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+
+	"github.com/go-chi/chi/v5"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an in-process OTLP/HTTP collector that keeps the spans
+// posted to it.
+type collector struct {
+	mu     sync.Mutex
+	spans  []*tracepb.Span
+	header http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header = r.Header.Clone()
+	for len(body) > 0 {
+		num, typ, n := protowire.ConsumeTag(body)
+		body = body[n:]
+		if num != 1 || typ != protowire.BytesType {
+			http.Error(w, "unexpected field", http.StatusBadRequest)
+			return
+		}
+		data, n := protowire.ConsumeBytes(body)
+		body = body[n:]
+		var rs tracepb.ResourceSpans
+		if err := proto.Unmarshal(data, &rs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *collector) span(name string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestSpansReachTheCollectorAndGanache(t *testing.T) {
+	col := &collector{}
+	colServer := httptest.NewServer(col)
+	t.Cleanup(colServer.Close)
+
+	shutdown, err := Setup(context.Background(), Options{
+		Exporter:    ExporterOTLP,
+		Endpoint:    colServer.URL,
+		Headers:     map[string]string{"Authorization": "Bearer s3cret"},
+		SampleRatio: 1,
+		Version:     "test",
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	var traceparent string
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		io.WriteString(w, `{"id":"7"}`)
+	}))
+	t.Cleanup(fake.Close)
+	client := ganache.NewClient(fake.URL, "key", time.Second)
+	client.WrapTransport(Transport("staging"))
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := client.GetAsset(r.Context(), chi.URLParam(r, "id")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	})
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	const proxyTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/assets/7", nil)
+	req.Header.Set("Traceparent", "00-"+proxyTrace+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	route := col.span("GET /assets/{id}")
+	call := col.span("ganache get")
+	if route == nil || call == nil {
+		t.Fatalf("expected a route span and a Ganache span, got %v", col.spans)
+	}
+	if got := hex.EncodeToString(route.TraceId); got != proxyTrace {
+		t.Fatalf("expected the proxy's trace to continue, got %s", got)
+	}
+	if string(call.ParentSpanId) != string(route.SpanId) {
+		t.Fatal("expected the Ganache call to be a child of the route span")
+	}
+	if want := "00-" + proxyTrace + "-" + hex.EncodeToString(call.SpanId) + "-01"; traceparent != want {
+		t.Fatalf("expected Ganache to receive %s, got %q", want, traceparent)
+	}
+	if col.span("GET /healthz") != nil || col.span("GET") != nil {
+		t.Fatal("health checks should not be traced")
+	}
+	if col.header.Get("Authorization") != "Bearer s3cret" {
+		t.Fatal("expected the configured headers on export")
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := Setup(context.Background(), Options{Exporter: ExporterOTLP}); err == nil {
+		t.Fatal("expected the OTLP endpoint to be required")
+	}
+}
@@ -0,0 +1,136 @@
+// Package trash records assets deleted in the admin UI. A trashed asset is
+// left in Ganache but hidden from searches until it is restored or its
+// restore window ends and it is purged.
+package trash
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+	"ganache-admin-ui/internal/ganache"
+)
+
+var ErrNotFound = errors.New("asset is not in the trash")
+
+// Entry keeps a copy of the asset as it was when deleted, so the trash
+// page can show it without asking Ganache.
+type Entry struct {
+	Asset     ganache.Asset `json:"asset"`
+	DeletedBy string        `json:"deletedBy"`
+	DeletedAt time.Time     `json:"deletedAt"`
+	PurgeAt   time.Time     `json:"purgeAt"`
+}
+
+func (e Entry) ID() string { return string(e.Asset.ID) }
+
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewStore(path string) (*Store, error) {
+	var list []Entry
+	if err := filestore.ReadJSON(path, &list); err != nil {
+		return nil, err
+	}
+	st := &Store{path: path, entries: make(map[string]Entry, len(list))}
+	for _, e := range list {
+		st.entries[e.ID()] = e
+	}
+	return st, nil
+}
+
+// Add moves an asset to the trash. Trashing it again keeps the original
+// deletion time and window.
+func (st *Store) Add(e Entry) (Entry, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if existing, ok := st.entries[e.ID()]; ok {
+		return existing, nil
+	}
+	st.entries[e.ID()] = e
+	if err := st.saveLocked(); err != nil {
+		delete(st.entries, e.ID())
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+func (st *Store) Get(id string) (Entry, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e, ok := st.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// IDs returns the set of trashed asset IDs.
+func (st *Store) IDs() map[string]bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ids := make(map[string]bool, len(st.entries))
+	for id := range st.entries {
+		ids[id] = true
+	}
+	return ids
+}
+
+// List returns the trash, most recently deleted first.
+func (st *Store) List() []Entry {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]Entry, 0, len(st.entries))
+	for _, e := range st.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if !list[a].DeletedAt.Equal(list[b].DeletedAt) {
+			return list[a].DeletedAt.After(list[b].DeletedAt)
+		}
+		return list[a].ID() < list[b].ID()
+	})
+	return list
+}
+
+// Due returns the entries whose restore window ended before now.
+func (st *Store) Due(now time.Time) []Entry {
+	var due []Entry
+	for _, e := range st.List() {
+		if !e.PurgeAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	return due
+}
+
+// Remove takes an asset out of the trash, after it is restored or purged.
+func (st *Store) Remove(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	e, ok := st.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(st.entries, id)
+	if err := st.saveLocked(); err != nil {
+		st.entries[id] = e
+		return err
+	}
+	return nil
+}
+
+func (st *Store) saveLocked() error {
+	list := make([]Entry, 0, len(st.entries))
+	for _, e := range st.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].DeletedAt.Before(list[b].DeletedAt) })
+	return filestore.WriteJSON(st.path, list)
+}
@@ -0,0 +1,50 @@
+package trash
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestStoreAddDueRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trash.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	old, _ := store.Add(Entry{Asset: ganache.Asset{ID: "1", Title: "Old"}, DeletedBy: "alice", DeletedAt: now.Add(-48 * time.Hour), PurgeAt: now.Add(-time.Hour)})
+	store.Add(Entry{Asset: ganache.Asset{ID: "2"}, DeletedBy: "bob", DeletedAt: now, PurgeAt: now.Add(24 * time.Hour)})
+
+	again, err := store.Add(Entry{Asset: ganache.Asset{ID: "1"}, DeletedBy: "bob", DeletedAt: now, PurgeAt: now.Add(time.Hour)})
+	if err != nil || again.DeletedBy != "alice" || !again.PurgeAt.Equal(old.PurgeAt) {
+		t.Fatalf("re-trashing should keep the first entry: %+v %v", again, err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if list := reloaded.List(); len(list) != 2 || list[0].ID() != "2" {
+		t.Fatalf("expected newest first, got %+v", list)
+	}
+	if ids := reloaded.IDs(); !ids["1"] || !ids["2"] || len(ids) != 2 {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	due := reloaded.Due(now)
+	if len(due) != 1 || due[0].ID() != "1" || due[0].Asset.Title != "Old" {
+		t.Fatalf("unexpected due entries %+v", due)
+	}
+	if err := reloaded.Remove("1"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := reloaded.Get("1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := reloaded.Remove("1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package tus stages resumable uploads on disk for the tus 1.0 protocol
+// handlers in httpui. Each upload is a pair of files in the staging
+// directory: <id>.bin holds the bytes received so far and <id>.json holds
+// the Upload record.
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ganache-admin-ui/internal/filestore"
+)
+
+var (
+	ErrNotFound       = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	ErrTooLarge       = errors.New("upload exceeds declared length")
+	ErrLocked         = errors.New("upload is busy")
+)
+
+type Upload struct {
+	ID        string            `json:"id"`
+	Owner     string            `json:"owner"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+func (u Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+type Store struct {
+	dir string
+	ttl time.Duration
+
+	mu     sync.Mutex
+	active map[string]struct{}
+}
+
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create upload staging dir: %w", err)
+	}
+	return &Store{dir: dir, ttl: ttl, active: make(map[string]struct{})}, nil
+}
+
+func (s *Store) Create(owner string, length int64, meta map[string]string) (Upload, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return Upload{}, err
+	}
+	now := time.Now().UTC()
+	u := Upload{
+		ID:        hex.EncodeToString(buf),
+		Owner:     owner,
+		Length:    length,
+		Metadata:  meta,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	f, err := os.OpenFile(s.dataPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Upload{}, err
+	}
+	f.Close()
+	if err := s.writeInfo(u); err != nil {
+		os.Remove(s.dataPath(u.ID))
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+func (s *Store) Get(id string) (Upload, error) {
+	if !validID(id) {
+		return Upload{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Upload{}, ErrNotFound
+	}
+	if err != nil {
+		return Upload{}, err
+	}
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return Upload{}, err
+	}
+	if time.Now().After(u.ExpiresAt) {
+		return Upload{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// Append writes a chunk starting at offset. The chunk may be cut short by
+// the client; whatever arrived is kept and the new offset recorded so the
+// upload can resume from there.
+func (s *Store) Append(id string, offset int64, r io.Reader) (Upload, error) {
+	if !s.TryLock(id) {
+		return Upload{}, ErrLocked
+	}
+	defer s.Unlock(id)
+
+	u, err := s.Get(id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if offset != u.Offset {
+		return u, ErrOffsetMismatch
+	}
+	f, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0o600)
+	if err != nil {
+		return u, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return u, err
+	}
+	remaining := u.Length - u.Offset
+	n, copyErr := io.Copy(f, io.LimitReader(r, remaining))
+	u.Offset += n
+	u.ExpiresAt = time.Now().UTC().Add(s.ttl)
+	if err := s.writeInfo(u); err != nil {
+		return u, err
+	}
+	if copyErr != nil {
+		return u, copyErr
+	}
+	if n == remaining {
+		var probe [1]byte
+		if m, _ := r.Read(probe[:]); m > 0 {
+			return u, ErrTooLarge
+		}
+	}
+	return u, nil
+}
+
+// Open returns the staged bytes of an upload for reading.
+func (s *Store) Open(id string) (*os.File, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	return os.Open(s.dataPath(id))
+}
+
+func (s *Store) Remove(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.dataPath(id))
+	if ierr := os.Remove(s.infoPath(id)); err == nil {
+		err = ierr
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// CleanupExpired removes uploads that have not received data within the
+// expiry window, along with orphaned data files.
+func (s *Store) CleanupExpired() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	removed := 0
+	for _, e := range entries {
+		name := e.Name()
+		id := strings.TrimSuffix(strings.TrimSuffix(name, ".json"), ".bin")
+		if !validID(id) || !s.TryLock(id) {
+			continue
+		}
+		expired := false
+		data, err := os.ReadFile(s.infoPath(id))
+		var u Upload
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			info, ierr := e.Info()
+			expired = ierr == nil && now.Sub(info.ModTime()) > s.ttl
+		case err == nil && json.Unmarshal(data, &u) == nil:
+			expired = now.After(u.ExpiresAt)
+		}
+		if expired {
+			if err := s.Remove(id); err == nil {
+				removed++
+			}
+		}
+		s.Unlock(id)
+	}
+	return removed, nil
+}
+
+func (s *Store) writeInfo(u Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return filestore.WriteFile(s.infoPath(u.ID), data, 0o600)
+}
+
+// TryLock reserves an upload for exclusive use, such as appending a chunk
+// or forwarding the finished file. It reports false if it is already held.
+func (s *Store) TryLock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.active[id]; busy {
+		return false
+	}
+	s.active[id] = struct{}{}
+	return true
+}
+
+func (s *Store) Unlock(id string) {
+	s.mu.Lock()
+	delete(s.active, id)
+	s.mu.Unlock()
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *Store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// ParseMetadata decodes an Upload-Metadata header: comma separated pairs
+// of a key and an optional base64 encoded value.
+func ParseMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			meta[parts[0]] = ""
+		case 2:
+			val, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %q", parts[0])
+			}
+			meta[parts[0]] = string(val)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair %q", pair)
+		}
+	}
+	return meta, nil
+}
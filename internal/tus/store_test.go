@@ -0,0 +1,94 @@
+package tus
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreAppendAndResume(t *testing.T) {
+	store, err := NewStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	u, err := store.Create("alice", 10, map[string]string{"filename": "a.jpg"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// A chunk cut short by the client keeps what arrived.
+	cut := io.MultiReader(strings.NewReader("0123"), errReader{})
+	u, err = store.Append(u.ID, 0, cut)
+	if err == nil || u.Offset != 4 {
+		t.Fatalf("expected partial append at 4, got %d %v", u.Offset, err)
+	}
+	if _, err := store.Append(u.ID, 0, strings.NewReader("0123")); !errors.Is(err, ErrOffsetMismatch) {
+		t.Fatalf("expected offset mismatch, got %v", err)
+	}
+	u, err = store.Append(u.ID, 4, strings.NewReader("456789"))
+	if err != nil || !u.Complete() {
+		t.Fatalf("expected complete, got %+v %v", u, err)
+	}
+
+	got, err := store.Get(u.ID)
+	if err != nil || got.Offset != 10 || got.Metadata["filename"] != "a.jpg" || got.Owner != "alice" {
+		t.Fatalf("unexpected record: %+v %v", got, err)
+	}
+	f, _ := store.Open(u.ID)
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "0123456789" {
+		t.Fatalf("unexpected data %q", data)
+	}
+}
+
+func TestStoreRejectsOverflow(t *testing.T) {
+	store, _ := NewStore(t.TempDir(), time.Hour)
+	u, _ := store.Create("alice", 3, nil)
+	if _, err := store.Append(u.ID, 0, strings.NewReader("abcd")); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected too large, got %v", err)
+	}
+}
+
+func TestStoreCleanupExpired(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStore(dir, time.Hour)
+	keep, _ := store.Create("alice", 3, nil)
+	store.ttl = -time.Minute
+	gone, _ := store.Create("alice", 3, nil)
+
+	n, err := store.CleanupExpired()
+	if err != nil || n != 1 {
+		t.Fatalf("expected one removal, got %d %v", n, err)
+	}
+	if _, err := store.Get(keep.ID); err != nil {
+		t.Fatalf("live upload removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, gone.ID+".bin")); !os.IsNotExist(err) {
+		t.Fatalf("expired data file still present")
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	meta, err := ParseMetadata("filename cGhvdG8uanBn,tags Zm9vdGJhbGwsIG5ld3M=,is_confidential")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if meta["filename"] != "photo.jpg" || meta["tags"] != "football, news" {
+		t.Fatalf("unexpected metadata: %v", meta)
+	}
+	if _, ok := meta["is_confidential"]; !ok {
+		t.Fatalf("expected key without value")
+	}
+	if _, err := ParseMetadata("title not-base64!"); err == nil {
+		t.Fatalf("expected error for invalid base64")
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
@@ -0,0 +1,149 @@
+// Package xmp reads and writes asset metadata as XMP sidecar files, using
+// the Dublin Core and Photoshop properties that photo tools use for title,
+// caption, keywords, credit and source.
+package xmp
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+const (
+	nsDC        = "http://purl.org/dc/elements/1.1/"
+	nsPhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	nsRights    = "http://ns.adobe.com/xap/1.0/rights/"
+	nsRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// Write encodes m as an XMP sidecar. Empty fields are left out.
+func Write(w io.Writer, m ganache.AssetUpdate) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="` + nsRDF + `">` + "\n")
+	b.WriteString(`  <rdf:Description rdf:about=""` +
+		` xmlns:dc="` + nsDC + `"` +
+		` xmlns:photoshop="` + nsPhotoshop + `"` +
+		` xmlns:xmpRights="` + nsRights + `">` + "\n")
+	alt := func(name, v string) {
+		if v == "" {
+			return
+		}
+		b.WriteString(`   <` + name + `><rdf:Alt><rdf:li xml:lang="x-default">`)
+		xml.EscapeText(b, []byte(v))
+		b.WriteString(`</rdf:li></rdf:Alt></` + name + ">\n")
+	}
+	simple := func(name, v string) {
+		if v == "" {
+			return
+		}
+		b.WriteString(`   <` + name + `>`)
+		xml.EscapeText(b, []byte(v))
+		b.WriteString(`</` + name + ">\n")
+	}
+	alt("dc:title", m.Title)
+	alt("dc:description", m.Caption)
+	if len(m.Tags) > 0 {
+		b.WriteString("   <dc:subject><rdf:Bag>")
+		for _, t := range m.Tags {
+			b.WriteString("<rdf:li>")
+			xml.EscapeText(b, []byte(t))
+			b.WriteString("</rdf:li>")
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	simple("photoshop:Credit", m.Credit)
+	simple("photoshop:Source", m.Source)
+	alt("xmpRights:UsageTerms", m.UsageNotes)
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	return b.Flush()
+}
+
+// Read decodes the properties Write produces from a sidecar. Simple
+// properties may also be given as attributes of rdf:Description, as some
+// tools write them. Language alternatives yield the x-default entry, or
+// the first one.
+func Read(r io.Reader) (ganache.AssetUpdate, error) {
+	var m ganache.AssetUpdate
+	fields := map[xml.Name]*string{
+		{Space: nsDC, Local: "title"}:          &m.Title,
+		{Space: nsDC, Local: "description"}:    &m.Caption,
+		{Space: nsPhotoshop, Local: "Credit"}:  &m.Credit,
+		{Space: nsPhotoshop, Local: "Source"}:  &m.Source,
+		{Space: nsRights, Local: "UsageTerms"}: &m.UsageNotes,
+	}
+	subject := xml.Name{Space: nsDC, Local: "subject"}
+
+	dec := xml.NewDecoder(r)
+	var (
+		prop    xml.Name // property being read, if any
+		inItem  bool
+		isDflt  bool
+		text    strings.Builder
+		seenXMP bool
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ganache.AssetUpdate{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == nsRDF && t.Name.Local == "Description":
+				seenXMP = true
+				for _, attr := range t.Attr {
+					if dst, ok := fields[attr.Name]; ok && *dst == "" {
+						*dst = strings.TrimSpace(attr.Value)
+					}
+				}
+			case prop.Local == "" && (fields[t.Name] != nil || t.Name == subject):
+				prop = t.Name
+				text.Reset()
+			case prop.Local != "" && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem = true
+				isDflt = false
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "lang" && attr.Value == "x-default" {
+						isDflt = true
+					}
+				}
+				text.Reset()
+			}
+		case xml.CharData:
+			if prop.Local != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case inItem && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem = false
+				v := strings.TrimSpace(text.String())
+				if prop == subject {
+					if v != "" {
+						m.Tags = append(m.Tags, v)
+					}
+				} else if dst := fields[prop]; *dst == "" || isDflt {
+					*dst = v
+				}
+				text.Reset()
+			case t.Name == prop:
+				if dst := fields[prop]; dst != nil && *dst == "" {
+					*dst = strings.TrimSpace(text.String())
+				}
+				prop = xml.Name{}
+			}
+		}
+	}
+	if !seenXMP {
+		return ganache.AssetUpdate{}, errors.New("not an XMP document")
+	}
+	return m, nil
+}
@@ -0,0 +1,94 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, ganache.AssetUpdate{
+		Title:   "Final <whistle>",
+		Caption: "Fans & players",
+		Credit:  "AP",
+		Tags:    []string{"cup", "final"},
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "photoshop:Source") || strings.Contains(out, "UsageTerms") {
+		t.Fatalf("empty fields should be left out: %s", out)
+	}
+
+	var doc struct {
+		Description struct {
+			Title   string   `xml:"title>Alt>li"`
+			Caption string   `xml:"description>Alt>li"`
+			Tags    []string `xml:"subject>Bag>li"`
+			Credit  string   `xml:"Credit"`
+		} `xml:"RDF>Description"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("not valid xml: %v\n%s", err, out)
+	}
+	d := doc.Description
+	if d.Title != "Final <whistle>" || d.Caption != "Fans & players" || d.Credit != "AP" || len(d.Tags) != 2 {
+		t.Fatalf("unexpected sidecar %+v", d)
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	want := ganache.AssetUpdate{
+		Title:      "Final <whistle>",
+		Caption:    "Fans & players",
+		Credit:     "AP",
+		Source:     "Wire",
+		UsageNotes: "Editorial only",
+		Tags:       []string{"cup", "final"},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, want); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got.Title != want.Title || got.Caption != want.Caption || got.Credit != want.Credit ||
+		got.Source != want.Source || got.UsageNotes != want.UsageNotes || strings.Join(got.Tags, ",") != "cup,final" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestReadAttributesAndLanguages(t *testing.T) {
+	doc := `<?xpacket begin=""?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/"
+    photoshop:Credit="Reuters" photoshop:Source="Desk">
+   <dc:title><rdf:Alt><rdf:li xml:lang="de">Finale</rdf:li><rdf:li xml:lang="x-default">Final</rdf:li></rdf:Alt></dc:title>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+	got, err := Read(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got.Title != "Final" || got.Credit != "Reuters" || got.Source != "Desk" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestReadRejectsOtherXML(t *testing.T) {
+	if _, err := Read(strings.NewReader("<html><body/></html>")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := Read(strings.NewReader("<x:xmpmeta")); err == nil {
+		t.Fatal("expected an error for truncated xml")
+	}
+}
@@ -0,0 +1,228 @@
+package zipimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"ganache-admin-ui/internal/tagpolicy"
+)
+
+// Row is one manifest entry. File is relative to the manifest's folder;
+// Tags is nil when the entry leaves the tags to the sidecar.
+type Row struct {
+	Line   int
+	File   string
+	Fields map[string]string
+	Tags   []string
+}
+
+// manifestColumns maps accepted header names, lower-cased, to form keys.
+var manifestColumns = map[string]string{
+	"file": "file", "filename": "file", "name": "file",
+	"title":   "title",
+	"caption": "caption", "description": "caption",
+	"credit":     "credit",
+	"source":     "source",
+	"usagenotes": "usageNotes", "usage notes": "usageNotes", "usage_notes": "usageNotes",
+	"tags": "tags", "keywords": "tags",
+}
+
+func readManifest(f *zip.File) ([]Row, error) {
+	if f.UncompressedSize64 > maxManifestSize {
+		return nil, fmt.Errorf("manifest is larger than %d MB", maxManifestSize>>20)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	if strings.EqualFold(path.Ext(f.Name), ".json") {
+		rows, err = parseJSON(data)
+	} else {
+		rows, err = parseCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]int{}
+	for _, row := range rows {
+		if err := checkName(row.File); err != nil {
+			return nil, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		key := strings.ToLower(path.Clean(row.File))
+		if first, dup := seen[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is also on line %d", row.Line, row.File, first)
+		}
+		seen[key] = row.Line
+	}
+	return rows, nil
+}
+
+func parseCSV(data []byte) ([]Row, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the manifest is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(header))
+	hasFile := false
+	for i, h := range header {
+		key, ok := manifestColumns[strings.ToLower(strings.TrimSpace(h))]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		keys[i] = key
+		hasFile = hasFile || key == "file"
+	}
+	if !hasFile {
+		return nil, errors.New("the manifest needs a file column")
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line, Fields: map[string]string{}}
+		for i, key := range keys {
+			v := strings.TrimSpace(record[i])
+			switch {
+			case key == "file":
+				row.File = v
+			case key == "tags":
+				if v != "" {
+					row.Tags = manifestTags(v)
+				}
+			case v != "":
+				row.Fields[key] = v
+			}
+		}
+		if row.File == "" {
+			return nil, fmt.Errorf("line %d: missing file name", line)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseJSON accepts a list of objects with a "file" key, or an object keyed
+// by file name. Tags may be a list or a comma-separated string.
+func parseJSON(data []byte) ([]Row, error) {
+	var rows []Row
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var list []map[string]any
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, err
+		}
+		for i, obj := range list {
+			row, err := jsonRow(i+1, obj)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var byFile map[string]map[string]any
+		if err := json.Unmarshal(trimmed, &byFile); err != nil {
+			return nil, err
+		}
+		for _, file := range sortedFiles(byFile) {
+			obj := byFile[file]
+			obj["file"] = file
+			row, err := jsonRow(len(rows)+1, obj)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, errors.New("the manifest must be a JSON list or object")
+	}
+	return rows, nil
+}
+
+// jsonRow converts one entry; line is its position in the manifest.
+func jsonRow(line int, obj map[string]any) (Row, error) {
+	row := Row{Line: line, Fields: map[string]string{}}
+	for name, raw := range obj {
+		key, ok := manifestColumns[strings.ToLower(name)]
+		if !ok {
+			return Row{}, fmt.Errorf("entry %d: unknown field %q", line, name)
+		}
+		if key == "tags" {
+			switch v := raw.(type) {
+			case string:
+				row.Tags = manifestTags(v)
+			case []any:
+				var parts []string
+				for _, t := range v {
+					s, ok := t.(string)
+					if !ok {
+						return Row{}, fmt.Errorf("entry %d: tags must be strings", line)
+					}
+					parts = append(parts, s)
+				}
+				row.Tags = manifestTags(parts...)
+			case nil:
+			default:
+				return Row{}, fmt.Errorf("entry %d: tags must be a list or a string", line)
+			}
+			continue
+		}
+		s, ok := raw.(string)
+		if !ok && raw != nil {
+			return Row{}, fmt.Errorf("entry %d: %s must be a string", line, name)
+		}
+		s = strings.TrimSpace(s)
+		switch {
+		case key == "file":
+			row.File = s
+		case s != "":
+			row.Fields[key] = s
+		}
+	}
+	if row.File == "" {
+		return Row{}, fmt.Errorf("entry %d: missing file name", line)
+	}
+	return row, nil
+}
+
+func sortedFiles(m map[string]map[string]any) []string {
+	files := make([]string, 0, len(m))
+	for f := range m {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// manifestTags reads the tags of a manifest entry. Tags given but empty
+// stay non-nil: the entry then has no tags rather than the sidecar's.
+func manifestTags(inputs ...string) []string {
+	if tags := tagpolicy.Split(inputs...); tags != nil {
+		return tags
+	}
+	return []string{}
+}
@@ -0,0 +1,297 @@
+// Package zipimport reads a ZIP of images for bulk upload. Metadata comes
+// from an optional manifest.csv or manifest.json and from XMP sidecars next
+// to the images. Entries are never extracted by name, and sizes are checked
+// before anything is read, so a hostile archive can neither write outside
+// the staging directory nor expand without bound.
+package zipimport
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/xmp"
+)
+
+// Limits bound what an archive may expand to. MaxRatio applies to files
+// over ratioFloor, since small files compress well for honest reasons.
+type Limits struct {
+	MaxEntries   int
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxRatio     int
+}
+
+// DefaultLimits are used when a limit is zero.
+var DefaultLimits = Limits{
+	MaxEntries:   2000,
+	MaxFileSize:  200 << 20,
+	MaxTotalSize: 4 << 30,
+	MaxRatio:     100,
+}
+
+const (
+	ratioFloor      = 1 << 20
+	maxManifestSize = 5 << 20
+	maxSidecarSize  = 1 << 20
+)
+
+// Item is an image to upload, or an entry that cannot be uploaded, in which
+// case Err says why. Fields uses the keys of the upload form.
+type Item struct {
+	Name   string
+	Fields map[string]string
+	Tags   []string
+	Err    string
+	file   *zip.File
+	limit  int64
+}
+
+// Filename is the image's name without its folder.
+func (it Item) Filename() string {
+	return path.Base(it.Name)
+}
+
+// Open returns the image's content. Reading fails once the content goes
+// past the maximum file size, even if the archive declared a smaller size.
+func (it Item) Open() (io.ReadCloser, error) {
+	if it.file == nil {
+		return nil, errors.New(it.Err)
+	}
+	rc, err := it.file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReadCloser{r: io.LimitReader(rc, it.limit+1), c: rc, n: it.limit}, nil
+}
+
+type limitedReadCloser struct {
+	r    io.Reader
+	c    io.Closer
+	n    int64
+	read int64
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.n {
+		return n, fmt.Errorf("file is larger than %d MB", l.n>>20)
+	}
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.c.Close()
+}
+
+// Read lists the archive's images with their metadata, in archive order,
+// followed by manifest rows that name no image. An error means the archive
+// as a whole is unusable.
+func Read(r io.ReaderAt, size int64, limits Limits) ([]Item, error) {
+	limits = limits.withDefaults()
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("not a ZIP archive")
+	}
+	if len(zr.File) > limits.MaxEntries {
+		return nil, fmt.Errorf("the archive has more than %d entries", limits.MaxEntries)
+	}
+
+	var (
+		items     []Item
+		total     uint64
+		manifests []*zip.File
+		sidecars  = map[string]*zip.File{}
+	)
+	for _, f := range zr.File {
+		if ignored(f) {
+			continue
+		}
+		total += f.UncompressedSize64
+		if total > uint64(limits.MaxTotalSize) {
+			return nil, fmt.Errorf("the archive expands to more than %d MB", limits.MaxTotalSize>>20)
+		}
+		it := Item{Name: f.Name, limit: limits.MaxFileSize}
+		if err := checkName(f.Name); err != nil {
+			it.Err = err.Error()
+			items = append(items, it)
+			continue
+		}
+		switch base := strings.ToLower(path.Base(f.Name)); {
+		case base == "manifest.csv" || base == "manifest.json":
+			manifests = append(manifests, f)
+			continue
+		case path.Ext(base) == ".xmp":
+			sidecars[strings.ToLower(f.Name)] = f
+			continue
+		}
+		if err := checkSize(f, limits); err != nil {
+			it.Err = err.Error()
+		} else {
+			it.file = f
+		}
+		items = append(items, it)
+	}
+
+	if len(manifests) > 1 {
+		return nil, errors.New("the archive has more than one manifest")
+	}
+	var rows []Row
+	dir := ""
+	if len(manifests) == 1 {
+		f := manifests[0]
+		dir = path.Dir(f.Name)
+		if rows, err = readManifest(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+
+	byPath := map[string]int{}
+	byBase := map[string][]int{}
+	for i, it := range items {
+		byPath[strings.ToLower(it.Name)] = i
+		base := strings.ToLower(it.Filename())
+		byBase[base] = append(byBase[base], i)
+	}
+	for i := range items {
+		it := &items[i]
+		it.Fields = map[string]string{}
+		if it.Err != "" {
+			continue
+		}
+		if sc := sidecarFor(it.Name, sidecars); sc != nil {
+			meta, err := readSidecar(sc)
+			if err != nil {
+				it.Err = fmt.Sprintf("%s: %v", sc.Name, err)
+				it.file = nil
+				continue
+			}
+			applySidecar(it, meta)
+		}
+	}
+
+	var unmatched []Item
+	for _, row := range rows {
+		i, ok := byPath[strings.ToLower(path.Join(dir, row.File))]
+		if !ok && len(byBase[strings.ToLower(path.Base(row.File))]) == 1 {
+			i, ok = byBase[strings.ToLower(path.Base(row.File))][0], true
+		}
+		if !ok {
+			unmatched = append(unmatched, Item{
+				Name: row.File,
+				Err:  fmt.Sprintf("manifest line %d: no such file in the archive", row.Line),
+			})
+			continue
+		}
+		it := &items[i]
+		for k, v := range row.Fields {
+			it.Fields[k] = v
+		}
+		if row.Tags != nil {
+			it.Tags = row.Tags
+		}
+	}
+	for i := range items {
+		if it := &items[i]; it.Err == "" && it.Fields["title"] == "" {
+			it.Fields["title"] = strings.TrimSuffix(it.Filename(), path.Ext(it.Name))
+		}
+	}
+	return append(items, unmatched...), nil
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultLimits.MaxEntries
+	}
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultLimits.MaxFileSize
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultLimits.MaxTotalSize
+	}
+	if l.MaxRatio <= 0 {
+		l.MaxRatio = DefaultLimits.MaxRatio
+	}
+	return l
+}
+
+// ignored skips folders and the clutter archivers add, such as macOS
+// resource forks and dotfiles.
+func ignored(f *zip.File) bool {
+	if f.FileInfo().IsDir() || strings.HasSuffix(f.Name, "/") {
+		return true
+	}
+	if strings.HasPrefix(f.Name, "__MACOSX/") {
+		return true
+	}
+	base := path.Base(f.Name)
+	return strings.HasPrefix(base, ".") || strings.EqualFold(base, "Thumbs.db")
+}
+
+// checkName rejects names that would escape a directory if extracted, so
+// such archives are reported even though nothing is extracted by name.
+func checkName(name string) error {
+	switch {
+	case strings.ContainsAny(name, "\\\x00"),
+		strings.HasPrefix(name, "/"),
+		len(name) > 1 && name[1] == ':':
+		return errors.New("unsafe path in archive")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return errors.New("unsafe path in archive")
+		}
+	}
+	return nil
+}
+
+func checkSize(f *zip.File, limits Limits) error {
+	if f.UncompressedSize64 > uint64(limits.MaxFileSize) {
+		return fmt.Errorf("file is larger than %d MB", limits.MaxFileSize>>20)
+	}
+	if f.UncompressedSize64 > ratioFloor &&
+		(f.CompressedSize64 == 0 || f.UncompressedSize64/f.CompressedSize64 > uint64(limits.MaxRatio)) {
+		return errors.New("file is compressed suspiciously well")
+	}
+	return nil
+}
+
+// sidecarFor finds IMG.xmp or IMG.jpg.xmp next to the image.
+func sidecarFor(name string, sidecars map[string]*zip.File) *zip.File {
+	lower := strings.ToLower(name)
+	if f, ok := sidecars[lower+".xmp"]; ok {
+		return f
+	}
+	return sidecars[strings.TrimSuffix(lower, path.Ext(lower))+".xmp"]
+}
+
+func readSidecar(f *zip.File) (ganache.AssetUpdate, error) {
+	if f.UncompressedSize64 > maxSidecarSize {
+		return ganache.AssetUpdate{}, errors.New("sidecar is too large")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return ganache.AssetUpdate{}, err
+	}
+	defer rc.Close()
+	return xmp.Read(io.LimitReader(rc, maxSidecarSize))
+}
+
+func applySidecar(it *Item, m ganache.AssetUpdate) {
+	for k, v := range map[string]string{
+		"title": m.Title, "caption": m.Caption, "credit": m.Credit,
+		"source": m.Source, "usageNotes": m.UsageNotes,
+	} {
+		if v != "" {
+			it.Fields[k] = v
+		}
+	}
+	if len(m.Tags) > 0 {
+		it.Tags = m.Tags
+	}
+}
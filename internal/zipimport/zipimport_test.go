@@ -0,0 +1,167 @@
+package zipimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"ganache-admin-ui/internal/ganache"
+	"ganache-admin-ui/internal/xmp"
+)
+
+type entry struct {
+	name string
+	data []byte
+}
+
+func archive(t *testing.T, entries ...entry) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatalf("create %s: %v", e.name, err)
+		}
+		w.Write(e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func read(t *testing.T, r *bytes.Reader, limits Limits) []Item {
+	t.Helper()
+	items, err := Read(r, r.Size(), limits)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return items
+}
+
+func sidecar(t *testing.T, m ganache.AssetUpdate) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := xmp.Write(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadMergesManifestAndSidecars(t *testing.T) {
+	r := archive(t,
+		entry{"shoot/manifest.csv", []byte("File,Title,Tags\na.jpg,Opening,\"cup, final\"\nmissing.jpg,Gone,\n")},
+		entry{"shoot/a.jpg", []byte("jpeg-a")},
+		entry{"shoot/a.xmp", sidecar(t, ganache.AssetUpdate{Title: "From sidecar", Credit: "AP", Tags: []string{"old"}})},
+		entry{"shoot/b.png", []byte("png-b")},
+		entry{"shoot/b.png.xmp", sidecar(t, ganache.AssetUpdate{Caption: "Crowd"})},
+		entry{"__MACOSX/shoot/._a.jpg", []byte("fork")},
+		entry{"shoot/.DS_Store", []byte("junk")},
+	)
+	items := read(t, r, Limits{})
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %+v", items)
+	}
+	a, b, missing := items[0], items[1], items[2]
+	if a.Name != "shoot/a.jpg" || a.Err != "" || a.Fields["title"] != "Opening" || a.Fields["credit"] != "AP" ||
+		strings.Join(a.Tags, ",") != "cup,final" {
+		t.Fatalf("manifest should override the sidecar: %+v", a)
+	}
+	if b.Fields["title"] != "b" || b.Fields["caption"] != "Crowd" || b.Tags != nil {
+		t.Fatalf("unexpected b: %+v", b)
+	}
+	if missing.Name != "missing.jpg" || !strings.Contains(missing.Err, "line 3") {
+		t.Fatalf("unmatched manifest row should be reported: %+v", missing)
+	}
+
+	rc, err := a.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "jpeg-a" {
+		t.Fatalf("unexpected content %q", data)
+	}
+}
+
+func TestReadJSONManifest(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"list":   `[{"file": "a.jpg", "caption": "Goal", "tags": ["cup", "cup", "goal"]}]`,
+		"object": `{"a.jpg": {"description": "Goal", "keywords": "cup, goal"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			items := read(t, archive(t, entry{"manifest.json", []byte(manifest)}, entry{"a.jpg", []byte("x")}), Limits{})
+			if len(items) != 1 || items[0].Fields["caption"] != "Goal" || strings.Join(items[0].Tags, ",") != "cup,goal" {
+				t.Fatalf("unexpected items %+v", items)
+			}
+		})
+	}
+}
+
+func TestReadRejectsBadManifests(t *testing.T) {
+	for name, m := range map[string]entry{
+		"unknown column": {"manifest.csv", []byte("file,colour\na.jpg,red\n")},
+		"no file column": {"manifest.csv", []byte("title\nA\n")},
+		"duplicate":      {"manifest.csv", []byte("file\na.jpg\n./a.jpg\n")},
+		"escaping":       {"manifest.csv", []byte("file\n../etc/passwd\n")},
+		"json scalar":    {"manifest.json", []byte(`"a.jpg"`)},
+		"json number":    {"manifest.json", []byte(`[{"file": "a.jpg", "title": 3}]`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := archive(t, m, entry{"a.jpg", []byte("x")})
+			if _, err := Read(r, r.Size(), Limits{}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestReadReportsUnsafeNames(t *testing.T) {
+	items := read(t, archive(t,
+		entry{"../evil.jpg", []byte("x")},
+		entry{"/abs.jpg", []byte("x")},
+		entry{`dir\win.jpg`, []byte("x")},
+		entry{"ok.jpg", []byte("x")},
+	), Limits{})
+	if len(items) != 4 {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	for _, it := range items[:3] {
+		if it.Err == "" {
+			t.Fatalf("%s should be rejected", it.Name)
+		}
+		if _, err := it.Open(); err == nil {
+			t.Fatalf("%s should not open", it.Name)
+		}
+	}
+	if items[3].Err != "" {
+		t.Fatalf("ok.jpg rejected: %s", items[3].Err)
+	}
+}
+
+func TestReadGuardsAgainstBombs(t *testing.T) {
+	zeros := bytes.Repeat([]byte{0}, 4<<20)
+	items := read(t, archive(t, entry{"bomb.jpg", zeros}, entry{"small.jpg", []byte("x")}), Limits{})
+	if !strings.Contains(items[0].Err, "compressed") || items[1].Err != "" {
+		t.Fatalf("unexpected items %+v", items)
+	}
+
+	items = read(t, archive(t, entry{"big.jpg", []byte("0123456789")}), Limits{MaxFileSize: 5})
+	if items[0].Err == "" {
+		t.Fatal("file over the size limit should be rejected")
+	}
+
+	r := archive(t, entry{"a.jpg", []byte("0123456789")}, entry{"b.jpg", []byte("0123456789")})
+	if _, err := Read(r, r.Size(), Limits{MaxTotalSize: 15}); err == nil {
+		t.Fatal("expected the total size limit to apply")
+	}
+	if _, err := Read(r, r.Size(), Limits{MaxEntries: 1}); err == nil {
+		t.Fatal("expected the entry limit to apply")
+	}
+	if _, err := Read(strings.NewReader("not a zip"), 9, Limits{}); err == nil {
+		t.Fatal("expected an error for a non-zip")
+	}
+}
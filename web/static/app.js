@@ -1,8 +1,22 @@
+const TUS_THRESHOLD = 25 * 1024 * 1024;
+const TUS_CHUNK = 8 * 1024 * 1024;
+const TUS_MAX_RETRIES = 10;
+
 document.addEventListener("DOMContentLoaded", () => {
   setupPasteUpload();
+  setupResumableUpload();
   setupCopyButtons();
+  setupJobEvents();
+  setupQueryErrors();
+  setupCollectionReorder();
+  setupTabs();
+  setupExport();
 });
 
+function queuedSubmit(event) {
+  return Boolean(event.submitter && event.submitter.hasAttribute("data-queue-upload"));
+}
+
 function setupPasteUpload() {
   const pasteZone = document.getElementById("paste-zone");
   const preview = document.getElementById("paste-preview");
@@ -25,7 +39,7 @@ function setupPasteUpload() {
   });
 
   form.addEventListener("submit", async (event) => {
-    if (!pastedFile) return;
+    if (!pastedFile || queuedSubmit(event)) return;
     event.preventDefault();
     const fd = new FormData(form);
     fd.set("file", pastedFile, pastedFile.name);
@@ -34,7 +48,7 @@ function setupPasteUpload() {
       window.location = resp.url;
       return;
     }
-    if (resp.ok) {
+    if (resp.ok || resp.status === 422) {
       const text = await resp.text();
       document.open();
       document.write(text);
@@ -43,11 +57,102 @@ function setupPasteUpload() {
   });
 }
 
+// Files above the single-request limit go through the tus endpoint in
+// chunks, resuming from the server's offset after network failures.
+function setupResumableUpload() {
+  const form = document.getElementById("upload-form");
+  const input = document.getElementById("file");
+  const status = document.getElementById("upload-progress");
+  if (!form || !input) return;
+
+  form.addEventListener("submit", async (event) => {
+    if (event.defaultPrevented || queuedSubmit(event)) return;
+    const file = input.files && input.files[0];
+    if (!file || file.size <= TUS_THRESHOLD) return;
+    event.preventDefault();
+
+    const fd = new FormData(form);
+    const csrf = fd.get("csrf");
+    const meta = { filename: file.name };
+    ["title", "caption", "credit", "source", "usageNotes", "tags"].forEach((k) => {
+      const v = fd.get(k);
+      if (v) meta[k] = v;
+    });
+    const report = (text) => {
+      if (status) status.textContent = text;
+    };
+    try {
+      report("Starting upload…");
+      const location = await tusCreate(file, meta, csrf);
+      window.location = await tusSend(location, file, csrf, report);
+    } catch (err) {
+      report(`Upload failed: ${err.message}`);
+    }
+  });
+}
+
+function tusHeaders(csrf, extra) {
+  return Object.assign({ "Tus-Resumable": "1.0.0", "X-CSRF-Token": csrf }, extra || {});
+}
+
+function tusMetadata(meta) {
+  return Object.entries(meta)
+    .map(([k, v]) => `${k} ${btoa(unescape(encodeURIComponent(v)))}`)
+    .join(",");
+}
+
+async function tusCreate(file, meta, csrf) {
+  const resp = await fetch(document.body.dataset.base + "/uploads", {
+    method: "POST",
+    headers: tusHeaders(csrf, { "Upload-Length": String(file.size), "Upload-Metadata": tusMetadata(meta) }),
+  });
+  if (resp.status !== 201) throw new Error(await resp.text());
+  return resp.headers.get("Location");
+}
+
+async function tusSend(location, file, csrf, report) {
+  let offset = 0;
+  let failures = 0;
+  for (;;) {
+    let resp = null;
+    try {
+      const end = Math.min(offset + TUS_CHUNK, file.size);
+      resp = await fetch(location, {
+        method: "PATCH",
+        headers: tusHeaders(csrf, { "Upload-Offset": String(offset), "Content-Type": "application/offset+octet-stream" }),
+        body: file.slice(offset, end),
+      });
+    } catch (err) {
+      resp = null;
+    }
+    if (resp && resp.status === 204) {
+      offset = Number(resp.headers.get("Upload-Offset"));
+      failures = 0;
+      const asset = resp.headers.get("X-Asset-Location");
+      if (asset) return asset;
+      report(offset === file.size ? "Processing…" : `Uploading… ${Math.floor((offset * 100) / file.size)}%`);
+      continue;
+    }
+    const retryable = !resp || resp.status === 409 || resp.status === 423 || resp.status >= 500;
+    if (!retryable) throw new Error(await resp.text());
+    if (++failures > TUS_MAX_RETRIES) throw new Error("too many failed attempts");
+    report(`Connection problem, retrying (${failures}/${TUS_MAX_RETRIES})…`);
+    await new Promise((r) => setTimeout(r, Math.min(failures, 10) * 1000));
+    try {
+      const head = await fetch(location, { method: "HEAD", headers: tusHeaders(csrf) });
+      if (head.ok) offset = Number(head.headers.get("Upload-Offset"));
+    } catch (err) {
+      // Keep the last known offset and try again.
+    }
+  }
+}
+
 function setupCopyButtons() {
   document.querySelectorAll("[data-copy]").forEach((btn) => {
     btn.addEventListener("click", async () => {
-      const value = btn.getAttribute("data-copy");
+      let value = btn.getAttribute("data-copy");
       if (!value) return;
+      if (value.startsWith("/")) value = location.origin + value;
       try {
         await navigator.clipboard.writeText(value);
         btn.textContent = "Copied";
@@ -58,3 +163,117 @@ function setupCopyButtons() {
     });
   });
 }
+
+function setupJobEvents() {
+  const root = document.getElementById("jobs-live");
+  if (!root || !window.EventSource) return;
+  const source = new EventSource(document.body.dataset.base + "/jobs/events");
+  source.addEventListener("job", (event) => {
+    const job = JSON.parse(event.data);
+    const card = root.querySelector(`[data-job="${job.id}"]`);
+    if (!card) {
+      window.location.reload();
+      return;
+    }
+    const status = card.querySelector(".job-status");
+    if (status) {
+      status.textContent = job.status;
+      status.className = `badge job-status status-${job.status}`;
+    }
+    const bar = card.querySelector(".job-progress");
+    if (bar) bar.style.width = `${job.percent}%`;
+    const counts = card.querySelector(".job-counts");
+    if (counts) {
+      const done = job.succeeded + job.failed;
+      counts.textContent = `${done} of ${job.total} done` + (job.failed ? `, ${job.failed} failed` : "");
+    }
+    if (job.status === "succeeded" || job.status === "failed") {
+      source.close();
+      window.location.reload();
+    }
+  });
+}
+
+// Marks the search box while the results show a query syntax error.
+function setupQueryErrors() {
+  const input = document.querySelector("#search-form input[name=q]");
+  const results = document.getElementById("results");
+  if (!input || !results) return;
+  const mark = () => input.classList.toggle("invalid", Boolean(results.querySelector(".query-error")));
+  mark();
+  document.body.addEventListener("htmx:afterSwap", (event) => {
+    if (event.detail.target === results) mark();
+  });
+}
+
+// Collection items are reordered by drag and drop; the new order is posted
+// on every drop and the page reloads if the server rejects it.
+function setupCollectionReorder() {
+  const list = document.getElementById("collection-items");
+  if (!list) return;
+  let dragged = null;
+
+  list.addEventListener("dragstart", (event) => {
+    dragged = event.target.closest(".collection-item");
+    if (dragged) dragged.classList.add("dragging");
+  });
+  list.addEventListener("dragover", (event) => {
+    if (!dragged) return;
+    event.preventDefault();
+    const over = event.target.closest(".collection-item");
+    if (!over || over === dragged) return;
+    const box = over.getBoundingClientRect();
+    const after = event.clientY > box.top + box.height / 2;
+    list.insertBefore(dragged, after ? over.nextSibling : over);
+  });
+  list.addEventListener("dragend", async () => {
+    if (!dragged) return;
+    dragged.classList.remove("dragging");
+    dragged = null;
+    const body = new URLSearchParams();
+    list.querySelectorAll(".collection-item").forEach((item) => body.append("ids", item.dataset.id));
+    const resp = await fetch(list.dataset.reorder, {
+      method: "POST",
+      headers: { "X-CSRF-Token": list.dataset.csrf },
+      body,
+    });
+    if (!resp.ok) window.location.reload();
+  });
+}
+
+// Tabs show the panel named by data-tab and hide its siblings. Panels
+// loaded with hx-get are fetched again each time their tab is opened.
+function setupTabs() {
+  document.querySelectorAll(".tabs").forEach((bar) => {
+    bar.addEventListener("click", (event) => {
+      const tab = event.target.closest(".tab");
+      if (!tab) return;
+      bar.querySelectorAll(".tab").forEach((t) => {
+        t.classList.toggle("active", t === tab);
+        const panel = document.getElementById(t.dataset.tab);
+        if (panel) panel.hidden = t !== tab;
+      });
+    });
+  });
+}
+
+// The export form downloads the search currently in the search box, which
+// may have changed since the page loaded.
+function setupExport() {
+  const form = document.getElementById("export-form");
+  if (!form) return;
+  const search = document.querySelector(form.dataset.search);
+  form.addEventListener("submit", () => {
+    form.querySelectorAll("input.from-search").forEach((input) => input.remove());
+    ["q", "sort"].forEach((name) => {
+      const field = search && search.elements[name];
+      if (!field || !field.value) return;
+      const input = document.createElement("input");
+      input.type = "hidden";
+      input.className = "from-search";
+      input.name = name;
+      input.value = field.value;
+      form.appendChild(input);
+    });
+  });
+}